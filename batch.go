@@ -0,0 +1,99 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+// Index is the index (x, y) of an input computed by priv.GetIdx().
+type Index struct {
+	X, Y int
+}
+
+// GetIdxMany computes the index corresponding to each input.
+func (priv *PrivStore) GetIdxMany(inputs []string) ([]Index, error) {
+	idx := make([]Index, len(inputs))
+	for i, input := range inputs {
+		x, y, err := priv.GetIdx(input)
+		if err != nil {
+			return nil, err
+		}
+		idx[i] = Index{x, y}
+	}
+	return idx, nil
+}
+
+// GetShares computes the pubShare corresponding to each index. The i-th share
+// corresponds to idx[i]; if it can't be computed, then the i-th share is nil
+// and the i-th error says why. (See pub.GetShare().)
+//
+// The shares are computed from the same state of the store, even if an update
+// is applied concurrently.
+func (pub *PubStore) GetShares(idx []Index) ([][]byte, []error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	pubShares := make([][]byte, len(idx))
+	errs := make([]error, len(idx))
+	for i := range idx {
+		pubShares[i], errs[i] = pub.getShare(idx[i].X, idx[i].Y)
+	}
+	return pubShares, errs
+}
+
+// GetVerifiableShares is like pub.GetShares(), except that each share is
+// computed by pub.GetVerifiableShare().
+func (pub *PubStore) GetVerifiableShares(idx []Index) ([][]byte, []error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	pubShares := make([][]byte, len(idx))
+	errs := make([]error, len(idx))
+	for i := range idx {
+		pubShares[i], errs[i] = pub.getVerifiableShare(idx[i].X, idx[i].Y)
+	}
+	return pubShares, errs
+}
+
+// GetOutputMany computes the output corresponding to each input from its
+// pubShare. The i-th output corresponds to inputs[i] and pubShares[i]; if it
+// can't be computed, then the i-th output is "" and the i-th error says why.
+// (See priv.GetOutput().) If pubShares[i] is missing or nil, then the i-th
+// error is ItemNotFound, so the result of pub.GetShares() may be passed
+// directly.
+func (priv *PrivStore) GetOutputMany(inputs []string, pubShares [][]byte) ([]string, []error) {
+	outputs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	for i, input := range inputs {
+		if i >= len(pubShares) || pubShares[i] == nil {
+			errs[i] = ItemNotFound
+			continue
+		}
+		outputs[i], errs[i] = priv.GetOutput(input, pubShares[i])
+	}
+	return outputs, errs
+}
+
+// GetMany looks up each input in the public store and returns the results.
+// The i-th output corresponds to inputs[i]; if the input is not in the map,
+// then the i-th error is ItemNotFound.
+func (priv *PrivStore) GetMany(pub *PubStore, inputs []string) ([]string, []error) {
+	idx, err := priv.GetIdxMany(inputs)
+	if err != nil {
+		errs := make([]error, len(inputs))
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([]string, len(inputs)), errs
+	}
+	var pubShares [][]byte
+	var errs []error
+	if priv.commitment != nil {
+		pubShares, errs = pub.GetVerifiableShares(idx)
+	} else {
+		pubShares, errs = pub.GetShares(idx)
+	}
+	outputs, outputErrs := priv.GetOutputMany(inputs, pubShares)
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = outputErrs[i]
+		}
+	}
+	return outputs, errs
+}
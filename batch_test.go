@@ -0,0 +1,71 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import "testing"
+
+func TestGetMany(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	inputs := []string{"not an input"}
+	for in := range goodM {
+		inputs = append(inputs, in)
+	}
+	outputs, errs := priv.GetMany(pub, inputs)
+	AssertIntEqError(t, "len(outputs)", len(outputs), len(inputs))
+	AssertIntEqError(t, "len(errs)", len(errs), len(inputs))
+	if errs[0] != ItemNotFound {
+		t.Errorf("errs[0] = %v, expected %q", errs[0], ItemNotFound)
+	}
+	for i := 1; i < len(inputs); i++ {
+		if errs[i] != nil {
+			t.Errorf("errs[%d] = %v, expected nil", i, errs[i])
+		} else if outputs[i] != goodM[inputs[i]] {
+			t.Errorf("outputs[%d] = %q, expected %q", i, outputs[i], goodM[inputs[i]])
+		}
+	}
+}
+
+func TestGetShares(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	idx, err := priv.GetIdxMany([]string{"hip", "this"})
+	if err != nil {
+		t.Fatalf("priv.GetIdxMany() fails: %s", err)
+	}
+	idx = append(idx, Index{-1, 0})
+	pubShares, errs := pub.GetShares(idx)
+	for i := 0; i < 2; i++ {
+		if errs[i] != nil {
+			t.Errorf("errs[%d] = %v, expected nil", i, errs[i])
+		}
+	}
+	if errs[2] != ErrorIdx || pubShares[2] != nil {
+		t.Errorf("pub.GetShares() returns (%v, %v) for a bad index, expected (nil, %q)", pubShares[2], errs[2], ErrorIdx)
+	}
+
+	// Missing and short shares are not found.
+	outputs, errs := priv.GetOutputMany([]string{"hip", "this", "hip"}, [][]byte{pubShares[0], nil})
+	if errs[0] != nil || outputs[0] != goodM["hip"] {
+		t.Errorf("outputs[0] = (%q, %v), expected (%q, nil)", outputs[0], errs[0], goodM["hip"])
+	}
+	for i := 1; i < 3; i++ {
+		if errs[i] != ItemNotFound {
+			t.Errorf("errs[%d] = %v, expected %q", i, errs[i], ItemNotFound)
+		}
+	}
+	if _, err = priv.GetOutput("hip", []byte{1}); err != ItemNotFound {
+		t.Errorf("priv.GetOutput() returns %v for a short share, expected %q", err, ItemNotFound)
+	}
+}
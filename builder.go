@@ -0,0 +1,258 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The key of the sealed field of pb.Store: field number 4, wire type 2
+// (length-delimited).
+const sealedFieldKey = 4<<3 | 2
+
+// StoreBuilder creates a store whose map is too large to hold in memory. The
+// input/output pairs are added one at a time with b.Add() (or b.AddIter()), and
+// the public store is written to an io.Writer by b.Finish(), which returns the
+// private context.
+//
+// Only the inputs are kept in memory, since the graph is computed from them.
+// Each output is padded and sealed as it is added, and the sealed output is
+// written to a temporary file. The nonce of the sealed output depends on the
+// salt of the table, which isn't chosen until the graph is computed, so the
+// outputs are first sealed under a temporary key, then opened and sealed again
+// by b.Finish(). The outputs are never written to disk in the clear.
+//
+// You should call b.Close() if you don't call b.Finish(), so that the temporary
+// file is removed.
+type StoreBuilder struct {
+	priv *PrivStore
+
+	inputs   [][]byte
+	ctrBytes int
+
+	// The temporary file, and the key the outputs are sealed under before they
+	// are written to it.
+	spill *os.File
+	w     *bufio.Writer
+	aead  cipher.AEAD
+
+	// The first error writing to the temporary file. If set, then the builder
+	// can't be used.
+	err error
+}
+
+// NewStoreBuilder returns a builder of a store for key K. The temporary file is
+// created in dir, or in the default directory for temporary files if dir is
+// empty. The options are the same as for NewStore().
+func NewStoreBuilder(K []byte, dir string, opts ...Option) (*StoreBuilder, error) {
+	priv, err := newPrivStore(K, opts)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(GenerateKey())
+	if err != nil {
+		return nil, err
+	}
+	spill, err := os.CreateTemp(dir, "store-builder-")
+	if err != nil {
+		return nil, err
+	}
+	return &StoreBuilder{
+		priv:     priv,
+		ctrBytes: priv.aead.NonceSize() - SaltBytes,
+		spill:    spill,
+		w:        bufio.NewWriter(spill),
+		aead:     aead,
+	}, nil
+}
+
+// Add adds the input/output pair to the store. As with NewStoreBytes(), the
+// input and output are arbitrary byte strings. It returns ErrorOutputTooLong if
+// the output is too long for the padding, and ErrorMapTooLarge if the store is
+// full. (Duplicate inputs are detected by b.Finish().)
+func (b *StoreBuilder) Add(input, output []byte) error {
+	if b.spill == nil {
+		return ErrorClosed
+	} else if b.err != nil {
+		return b.err
+	} else if len(b.inputs) == maxCtr(b.ctrBytes) {
+		return ErrorMapTooLarge
+	}
+	padded, err := b.priv.pad(output)
+	if err != nil {
+		return err
+	}
+	sealed := b.aead.Seal(nil, b.nonce(len(b.inputs)), padded, nil)
+	var buf [binary.MaxVarintLen64]byte
+	if _, err = b.w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(sealed)))]); err == nil {
+		_, err = b.w.Write(sealed)
+	}
+	if err != nil {
+		b.err = err
+		return err
+	}
+	b.inputs = append(b.inputs, append([]byte(nil), input...))
+	return nil
+}
+
+// AddIter adds the input/output pairs read from next. It returns the first
+// error returned by next other than io.EOF.
+func (b *StoreBuilder) AddIter(next EntryIter) error {
+	for {
+		in, out, err := next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = b.Add(in, out); err != nil {
+			return err
+		}
+	}
+}
+
+// Len returns the number of input/output pairs added so far.
+func (b *StoreBuilder) Len() int {
+	return len(b.inputs)
+}
+
+// Finish computes the store, writes the protobuf representation of the public
+// store (a pb.Store) to w, and returns the private context. It returns
+// ErrorDuplicateInput if an input was added more than once. The sealed outputs
+// are streamed from the temporary file to w, so the public store is never held
+// in memory. The builder is closed afterwards, whether or not it succeeds.
+//
+// The public store may be read by unmarshaling a pb.Store and passing it to
+// NewPubStoreFromProto(), or served by a StoreProvider.
+func (b *StoreBuilder) Finish(w io.Writer) (*PrivStore, error) {
+	if b.spill == nil {
+		return nil, ErrorClosed
+	}
+	defer b.Close()
+	if b.err != nil {
+		return nil, b.err
+	} else if err := b.w.Flush(); err != nil {
+		return nil, err
+	} else if err := checkDistinct(b.inputs); err != nil {
+		return nil, err
+	}
+
+	// Map each input to a counter and construct the graph. (See NewStore().)
+	priv, itemCt := b.priv, len(b.inputs)
+	ctrs := make([][]byte, itemCt)
+	ctrBuf := make([]byte, itemCt*b.ctrBytes)
+	for i := range ctrs {
+		ctrs[i] = ctrBuf[i*b.ctrBytes : (i+1)*b.ctrBytes]
+		binary.LittleEndian.PutUint32(ctrs[i], uint32(i))
+	}
+	pubDict, privDict, g, err := newDictAndGraph(priv.keys.prf, b.inputs, ctrs, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	priv.dict = privDict
+
+	// Write everything but the sealed outputs.
+	pub := &PubStore{
+		dict:        pubDict,
+		g:           g,
+		ctr:         itemCt,
+		padding:     priv.padding,
+		paddedBytes: priv.paddedBytes,
+		schedule:    priv.schedule,
+		kdfHeader:   priv.kdfHeader,
+	}
+	defer pub.Close()
+	if priv.indexed {
+		if pub.index, err = priv.buildIndex(b.inputs); err != nil {
+			priv.Close()
+			return nil, err
+		}
+	}
+	priv.created = time.Now().Unix()
+	priv.mac = paramsMac(priv.keys.commit, priv.GetParams())
+	pub.version, pub.created, pub.mac = priv.version, priv.created, priv.mac
+	data, err := proto.Marshal(pub.GetProto())
+	if err != nil {
+		priv.Close()
+		return nil, err
+	}
+	bw := bufio.NewWriter(w)
+	if _, err = bw.Write(data); err != nil {
+		priv.Close()
+		return nil, err
+	}
+
+	// Re-seal each output and append it to the sealed field. Repeated fields
+	// of a protobuf may be split, so this is the same as setting the field.
+	if _, err = b.spill.Seek(0, io.SeekStart); err != nil {
+		priv.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(b.spill)
+	nonce := priv.dict.salt()
+	var buf [binary.MaxVarintLen64]byte
+	for i := 0; i < itemCt; i++ {
+		padded, err := b.readOutput(r, i)
+		if err != nil {
+			priv.Close()
+			return nil, err
+		}
+		sealed := priv.aead.Seal(nil, append(nonce, ctrs[i]...),
+			padded, priv.ad(string(b.inputs[i])))
+		err = bw.WriteByte(sealedFieldKey)
+		if err == nil {
+			_, err = bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(sealed)))])
+		}
+		if err == nil {
+			_, err = bw.Write(sealed)
+		}
+		if err != nil {
+			priv.Close()
+			return nil, err
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		priv.Close()
+		return nil, err
+	}
+	return priv, nil
+}
+
+// Close removes the temporary file. It is called by b.Finish().
+func (b *StoreBuilder) Close() error {
+	if b.spill == nil {
+		return nil
+	}
+	b.spill.Close()
+	err := os.Remove(b.spill.Name())
+	b.spill, b.w, b.inputs = nil, nil, nil
+	return err
+}
+
+// nonce returns the nonce of the i-th output in the temporary file.
+func (b *StoreBuilder) nonce(i int) []byte {
+	nonce := make([]byte, b.aead.NonceSize())
+	binary.LittleEndian.PutUint64(nonce, uint64(i))
+	return nonce
+}
+
+// readOutput reads the i-th output from the temporary file and opens it.
+func (b *StoreBuilder) readOutput(r *bufio.Reader, i int) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, n)
+	if _, err = io.ReadFull(r, sealed); err != nil {
+		return nil, err
+	}
+	return b.aead.Open(nil, b.nonce(i), sealed, nil)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// buildStore builds a store for M with a StoreBuilder and reads it back.
+func buildStore(t *testing.T, K []byte, M map[string]string, opts ...Option) (*PubStore, *PrivStore) {
+	b, err := NewStoreBuilder(K, t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("NewStoreBuilder() fails: %s", err)
+	}
+	for in, out := range M {
+		if err = b.Add([]byte(in), []byte(out)); err != nil {
+			t.Fatalf("b.Add() fails: %s", err)
+		}
+	}
+	var buf bytes.Buffer
+	priv, err := b.Finish(&buf)
+	if err != nil {
+		t.Fatalf("b.Finish() fails: %s", err)
+	}
+	table := new(pb.Store)
+	if err = proto.Unmarshal(buf.Bytes(), table); err != nil {
+		t.Fatalf("proto.Unmarshal() fails: %s", err)
+	}
+	if err = ValidateStoreProto(table); err != nil {
+		t.Fatalf("ValidateStoreProto() fails: %s", err)
+	}
+	return NewPubStoreFromProto(table), priv
+}
+
+func TestStoreBuilder(t *testing.T) {
+	M := bigM(1000)
+	K := GenerateKey()
+	pub, priv := buildStore(t, K, M, WithIndex(), WithPadding(32), WithVersion(3))
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	if len(pub.sealed) != len(M) {
+		t.Fatalf("store has %d sealed outputs, expected %d", len(pub.sealed), len(M))
+	}
+	for in, out := range M {
+		if output, err := priv.Get(pub, in); err != nil || output != out {
+			t.Errorf("priv.Get(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	if _, err := priv.Get(pub, "input 1000"); err != ItemNotFound {
+		t.Errorf("priv.Get() returns %v, expected %q", err, ItemNotFound)
+	}
+	if keys, err := priv.Keys(pub); err != nil || len(keys) != len(M) {
+		t.Errorf("priv.Keys() returns (%d inputs, %v), expected %d inputs", len(keys), err, len(M))
+	}
+
+	// The parameters are authenticated, and the store may be updated.
+	if priv2, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams(), 3); err != nil {
+		t.Errorf("NewPrivStore() fails: %s", err)
+	} else {
+		priv2.Close()
+	}
+	update, err := priv.Update(pub, "input 0", "updated")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	if output, err := priv.Get(pub, "input 0"); err != nil || output != "updated" {
+		t.Errorf("priv.Get() = (%q, %v) after the update", output, err)
+	}
+}
+
+func TestStoreBuilderBytes(t *testing.T) {
+	b, err := NewStoreBuilder(GenerateKey(), t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreBuilder() fails: %s", err)
+	}
+	i := 0
+	next := func() ([]byte, []byte, error) {
+		if i == len(binaryInputs) {
+			return nil, nil, io.EOF
+		}
+		i++
+		return binaryInputs[i-1], binaryOutputs[i-1], nil
+	}
+	if err = b.AddIter(next); err != nil {
+		t.Fatalf("b.AddIter() fails: %s", err)
+	}
+	var buf bytes.Buffer
+	priv, err := b.Finish(&buf)
+	if err != nil {
+		t.Fatalf("b.Finish() fails: %s", err)
+	}
+	defer priv.Close()
+	table := new(pb.Store)
+	if err = proto.Unmarshal(buf.Bytes(), table); err != nil {
+		t.Fatalf("proto.Unmarshal() fails: %s", err)
+	}
+	pub := NewPubStoreFromProto(table)
+	defer pub.Close()
+	for i, in := range binaryInputs {
+		if output, err := priv.GetBytes(pub, in); err != nil || !bytes.Equal(output, binaryOutputs[i]) {
+			t.Errorf("priv.GetBytes(%x) = (%x, %v), expected (%x, nil)", in, output, err, binaryOutputs[i])
+		}
+	}
+}
+
+func TestStoreBuilderSpill(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewStoreBuilder(GenerateKey(), dir)
+	if err != nil {
+		t.Fatalf("NewStoreBuilder() fails: %s", err)
+	}
+	secret := []byte("this output is never written to disk in the clear")
+	for i := 0; i < 100; i++ {
+		if err = b.Add([]byte(fmt.Sprintf("input %d", i)), secret); err != nil {
+			t.Fatalf("b.Add() fails: %s", err)
+		}
+	}
+	if b.Len() != 100 {
+		t.Errorf("b.Len() = %d, expected 100", b.Len())
+	}
+	b.w.Flush()
+	spill, err := os.ReadFile(b.spill.Name())
+	if err != nil {
+		t.Fatalf("os.ReadFile() fails: %s", err)
+	}
+	if len(spill) == 0 || bytes.Contains(spill, secret) {
+		t.Error("the temporary file doesn't contain the sealed outputs")
+	}
+
+	// A duplicate input is detected, and the temporary file is removed.
+	if err = b.Add([]byte("input 0"), []byte("again")); err != nil {
+		t.Fatalf("b.Add() fails: %s", err)
+	}
+	var buf bytes.Buffer
+	if _, err = b.Finish(&buf); err != ErrorDuplicateInput {
+		t.Errorf("b.Finish() returns %v, expected %q", err, ErrorDuplicateInput)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("the temporary file was not removed: %v", entries)
+	}
+	if err = b.Add([]byte("input"), []byte("output")); err != ErrorClosed {
+		t.Errorf("b.Add() returns %v after b.Finish(), expected %q", err, ErrorClosed)
+	}
+}
+
+// failingWriter accepts n bytes and then fails.
+type failingWriter struct {
+	n int
+}
+
+const errWrite = Error("write fails")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestStoreBuilderWriteError(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewStoreBuilder(GenerateKey(), dir)
+	if err != nil {
+		t.Fatalf("NewStoreBuilder() fails: %s", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err = b.Add([]byte(fmt.Sprintf("input %d", i)), bytes.Repeat([]byte("x"), 100)); err != nil {
+			t.Fatalf("b.Add() fails: %s", err)
+		}
+	}
+
+	// The writer fails while the sealed outputs are being written.
+	if priv, err := b.Finish(&failingWriter{n: 10000}); err != errWrite || priv != nil {
+		t.Errorf("b.Finish() returns (%v, %v), expected (nil, %q)", priv, err, errWrite)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("the temporary file was not removed: %v", entries)
+	}
+}
+
+func TestStoreBuilderPadding(t *testing.T) {
+	b, err := NewStoreBuilder(GenerateKey(), t.TempDir(), WithPadding(8))
+	if err != nil {
+		t.Fatalf("NewStoreBuilder() fails: %s", err)
+	}
+	defer b.Close()
+	if err = b.Add([]byte("input"), []byte("an output too long for the padding")); err != ErrorOutputTooLong {
+		t.Errorf("b.Add() returns %v, expected %q", err, ErrorOutputTooLong)
+	}
+	if b.Len() != 0 {
+		t.Errorf("b.Len() = %d, expected 0", b.Len())
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// Returned by NewStoreBytes() and NewStoreIter() if an input appears more than
+// once.
+const ErrorDuplicateInput = Error("input appears more than once")
+
+// Returned by NewStoreBytes() if the number of inputs and outputs differ.
+const ErrorInputOutputMismatch = Error("number of inputs and outputs differ")
+
+// An EntryIter returns the input/output pairs of a map one at a time. After the
+// last pair, it returns io.EOF. Any other error stops the iteration.
+type EntryIter func() (input, output []byte, err error)
+
+// NewStoreBytes is like NewStore(), except that the map is given by a slice of
+// inputs and a slice of outputs, so that inputs[i] is mapped to outputs[i]. The
+// inputs and outputs are arbitrary byte strings: they may contain any byte,
+// including 0. It returns ErrorDuplicateInput if the inputs are not distinct.
+//
+// This avoids building a Go map of the inputs, which matters for large stores.
+// The slices are not modified or retained.
+func NewStoreBytes(K []byte, inputs, outputs [][]byte, opts ...Option) (*PubStore, *PrivStore, error) {
+	if len(inputs) != len(outputs) {
+		return nil, nil, ErrorInputOutputMismatch
+	}
+	if err := checkDistinct(inputs); err != nil {
+		return nil, nil, err
+	}
+	return newStore(K, inputs, outputs, opts...)
+}
+
+// NewStoreIter is like NewStoreBytes(), except that the input/output pairs are
+// read from next. It returns the first error returned by next other than
+// io.EOF.
+func NewStoreIter(K []byte, next EntryIter, opts ...Option) (*PubStore, *PrivStore, error) {
+	var inputs, outputs [][]byte
+	for {
+		in, out, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, in)
+		outputs = append(outputs, out)
+	}
+	return NewStoreBytes(K, inputs, outputs, opts...)
+}
+
+// GetBytes is like priv.Get(), except that the input and output are byte
+// strings.
+func (priv *PrivStore) GetBytes(pub *PubStore, input []byte) ([]byte, error) {
+	output, err := priv.Get(pub, string(input))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(output), nil
+}
+
+// GetOutputBytes is like priv.GetOutput(), except that the input and output
+// are byte strings.
+func (priv *PrivStore) GetOutputBytes(input, pubShare []byte) ([]byte, error) {
+	output, err := priv.GetOutput(string(input), pubShare)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(output), nil
+}
+
+// checkDistinct returns ErrorDuplicateInput if the inputs are not distinct. It
+// sorts a permutation of the inputs rather than the inputs themselves.
+func checkDistinct(inputs [][]byte) error {
+	perm := make([]int, len(inputs))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.Slice(perm, func(i, j int) bool {
+		return bytes.Compare(inputs[perm[i]], inputs[perm[j]]) < 0
+	})
+	for i := 1; i < len(perm); i++ {
+		if bytes.Equal(inputs[perm[i-1]], inputs[perm[i]]) {
+			return ErrorDuplicateInput
+		}
+	}
+	return nil
+}
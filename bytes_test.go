@@ -0,0 +1,126 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// binaryInputs and binaryOutputs contain embedded zeros, invalid UTF-8, and
+// strings that differ only after a zero byte.
+var binaryInputs = [][]byte{
+	{0},
+	{0, 0},
+	{0, 1},
+	{0, 2},
+	{0xff, 0xfe, 0, 0x80},
+	[]byte("hash\x00suffix"),
+	[]byte("hash\x00other"),
+}
+
+var binaryOutputs = [][]byte{
+	{},
+	{0, 0, 0, 0},
+	{1, 0, 1},
+	{0xc3, 0x28},
+	bytes.Repeat([]byte{0}, 100),
+	[]byte("value\x00with zero"),
+	{0x80},
+}
+
+func TestNewStoreBytes(t *testing.T) {
+	K := GenerateKey()
+	pub, priv, err := NewStoreBytes(K, binaryInputs, binaryOutputs, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStoreBytes() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	for i, in := range binaryInputs {
+		output, err := priv.GetBytes(pub, in)
+		if err != nil {
+			t.Errorf("priv.GetBytes(%x) fails: %s", in, err)
+		} else if !bytes.Equal(output, binaryOutputs[i]) {
+			t.Errorf("priv.GetBytes(%x) = %x, expected %x", in, output, binaryOutputs[i])
+		}
+
+		x, y, err := priv.GetIdx(string(in))
+		if err != nil {
+			t.Fatalf("priv.GetIdx() fails: %s", err)
+		}
+		pubShare, err := pub.GetShare(x, y)
+		if err != nil {
+			t.Fatalf("pub.GetShare() fails: %s", err)
+		}
+		if output, err = priv.GetOutputBytes(in, pubShare); err != nil || !bytes.Equal(output, binaryOutputs[i]) {
+			t.Errorf("priv.GetOutputBytes(%x) = (%x, %v), expected (%x, nil)", in, output, err, binaryOutputs[i])
+		}
+	}
+
+	// A prefix up to a zero byte is a different input.
+	if _, err = priv.GetBytes(pub, []byte("hash")); err != ItemNotFound {
+		t.Errorf("priv.GetBytes(\"hash\") returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// The inputs survive the index and serialization of the store.
+	keys, err := priv.Keys(pub)
+	if err != nil {
+		t.Fatalf("priv.Keys() fails: %s", err)
+	}
+	for i, in := range keys {
+		if !bytes.Equal([]byte(in), binaryInputs[i]) {
+			t.Errorf("key %d = %x, expected %x", i, in, binaryInputs[i])
+		}
+	}
+	pub2 := NewPubStoreFromProto(pub.GetProto())
+	defer pub2.Close()
+	for i, in := range binaryInputs {
+		if output, err := priv.GetBytes(pub2, in); err != nil || !bytes.Equal(output, binaryOutputs[i]) {
+			t.Errorf("priv.GetBytes(pub2, %x) = (%x, %v), expected (%x, nil)", in, output, err, binaryOutputs[i])
+		}
+	}
+}
+
+func TestNewStoreBytesErrors(t *testing.T) {
+	K := GenerateKey()
+	if _, _, err := NewStoreBytes(K, binaryInputs, binaryOutputs[1:]); err != ErrorInputOutputMismatch {
+		t.Errorf("NewStoreBytes() returns %v, expected %q", err, ErrorInputOutputMismatch)
+	}
+	inputs := append([][]byte{[]byte("hash\x00other")}, binaryInputs[1:]...)
+	if _, _, err := NewStoreBytes(K, inputs, binaryOutputs); err != ErrorDuplicateInput {
+		t.Errorf("NewStoreBytes() returns %v, expected %q", err, ErrorDuplicateInput)
+	}
+}
+
+func TestNewStoreIter(t *testing.T) {
+	i := 0
+	next := func() ([]byte, []byte, error) {
+		if i == len(binaryInputs) {
+			return nil, nil, io.EOF
+		}
+		i++
+		return binaryInputs[i-1], binaryOutputs[i-1], nil
+	}
+	pub, priv, err := NewStoreIter(GenerateKey(), next)
+	if err != nil {
+		t.Fatalf("NewStoreIter() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	for i, in := range binaryInputs {
+		if output, err := priv.GetBytes(pub, in); err != nil || !bytes.Equal(output, binaryOutputs[i]) {
+			t.Errorf("priv.GetBytes(%x) = (%x, %v), expected (%x, nil)", in, output, err, binaryOutputs[i])
+		}
+	}
+
+	// Errors other than io.EOF are returned.
+	iterErr := errors.New("iterator fails")
+	next = func() ([]byte, []byte, error) { return nil, nil, iterErr }
+	if _, _, err = NewStoreIter(GenerateKey(), next); err != iterErr {
+		t.Errorf("NewStoreIter() returns %v, expected %v", err, iterErr)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"time"
+
+	"github.com/cjpatton/store"
+	"golang.org/x/net/context"
+)
+
+// Returned by RemoteStore if it is closed while a query is pending.
+const ErrorClosed = store.Error("remote store is closed")
+
+// WithCoverTraffic hides the access pattern of the client's queries from the
+// provider. Every query is sent in a GetShares request with exactly batchSize
+// indices, the rest of which are dummies computed from decoys. (See
+// store.PrivStore.CoverIdx().) The batches are shuffled, so the provider can't
+// tell which indices are real, or how many of them there are.
+//
+// The dummies should look like real queries, so decoys should be a list of
+// inputs in the map, e.g., those returned by RemoteStore.Keys(). If decoys is
+// empty, then the dummies are random rows of the table, which the provider can
+// distinguish from real queries.
+//
+// Note that cover traffic reduces, but does not eliminate, what the provider
+// learns: it still sees when the client makes requests (see WithFixedRate()),
+// and inputs that are looked up more often than the decoys stand out over time.
+func WithCoverTraffic(batchSize int, decoys []string) Option {
+	return func(r *RemoteStore) {
+		r.batchSize = batchSize
+		r.decoys = append([]string(nil), decoys...)
+	}
+}
+
+// WithFixedRate sends one GetShares request every interval, whether or not
+// there are queries to make. Queries are queued until the next request, which
+// carries up to batchSize of them (see WithCoverTraffic()); the rest of the
+// batch consists of dummies. This hides when the client makes queries, at the
+// cost of latency. If cover traffic is not configured, then each batch consists
+// of a single query.
+func WithFixedRate(interval time.Duration) Option {
+	return func(r *RemoteStore) {
+		r.interval = interval
+	}
+}
+
+// query is a lookup waiting to be sent by the background loop.
+type query struct {
+	input string
+	priv  *store.PrivStore // The context used to compute the index.
+
+	output string
+	err    error         // Set if the input is not in the map.
+	fail   error         // Set if the request as a whole fails.
+	done   chan struct{} // Closed once the result is set.
+}
+
+// lookup looks up each input in the store using priv. The queries are hidden
+// among dummies, as configured by WithCoverTraffic() and WithFixedRate().
+func (r *RemoteStore) lookup(ctx context.Context, priv *store.PrivStore, inputs []string) ([]string, []error, error) {
+	if r.interval > 0 {
+		return r.enqueue(ctx, priv, inputs)
+	}
+
+	outputs := make([]string, 0, len(inputs))
+	errs := make([]error, 0, len(inputs))
+	for len(inputs) > 0 {
+		n := len(inputs)
+		if n > r.batchSize {
+			n = r.batchSize
+		}
+		chunk := inputs[:n]
+		inputs = inputs[n:]
+
+		idx, err := priv.GetIdxMany(chunk)
+		if err != nil {
+			return nil, nil, err
+		}
+		batch, pos, err := priv.CoverIdx(idx, r.decoys, r.batchSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		batchShares, batchErrs, err := r.getShares(ctx, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pubShares := make([][]byte, n)
+		chunkErrs := make([]error, n)
+		for i := range chunk {
+			pubShares[i], chunkErrs[i] = batchShares[pos[i]], batchErrs[pos[i]]
+		}
+		outputs = append(outputs, getOutputs(priv, chunk, pubShares, chunkErrs)...)
+		errs = append(errs, chunkErrs...)
+	}
+	return outputs, errs, nil
+}
+
+// enqueue queues the inputs for the background loop and waits for the results.
+func (r *RemoteStore) enqueue(ctx context.Context, priv *store.PrivStore, inputs []string) ([]string, []error, error) {
+	queries := make([]*query, len(inputs))
+	for i := range inputs {
+		queries[i] = &query{input: inputs[i], priv: priv, done: make(chan struct{})}
+	}
+	r.qmu.Lock()
+	if r.closed {
+		r.qmu.Unlock()
+		return nil, nil, ErrorClosed
+	}
+	r.pending = append(r.pending, queries...)
+	r.qmu.Unlock()
+
+	outputs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	for i, q := range queries {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			// The query is still sent with the next batch, but its result
+			// is dropped.
+			return nil, nil, ctx.Err()
+		}
+		if q.fail != nil {
+			return nil, nil, q.fail
+		}
+		outputs[i], errs[i] = q.output, q.err
+	}
+	return outputs, errs, nil
+}
+
+// run sends a batch of queries every r.interval until r.stop is canceled.
+func (r *RemoteStore) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop.Done():
+			r.qmu.Lock()
+			pending := r.pending
+			r.pending = nil
+			r.qmu.Unlock()
+			for _, q := range pending {
+				q.fail = ErrorClosed
+				close(q.done)
+			}
+			return
+		case <-ticker.C:
+			r.sendBatch()
+		}
+	}
+}
+
+// sendBatch sends the next batch of pending queries, padded with dummies. If
+// there are no pending queries, then the batch consists only of dummies. The
+// RPCs are canceled by r.Close().
+func (r *RemoteStore) sendBatch() {
+	r.qmu.Lock()
+	n := len(r.pending)
+	if n > r.batchSize {
+		n = r.batchSize
+	}
+	queries := r.pending[:n:n]
+	r.pending = r.pending[n:]
+	r.qmu.Unlock()
+
+	fail := func(err error) {
+		for _, q := range queries {
+			q.fail = err
+			close(q.done)
+		}
+	}
+
+	ctx := r.stop
+	priv, _, err := r.getPriv(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// The index of each query is computed with the context it was made with,
+	// which may be older than the current one.
+	idx := make([]store.Index, len(queries))
+	for i, q := range queries {
+		if idx[i].X, idx[i].Y, err = q.priv.GetIdx(q.input); err != nil {
+			fail(err)
+			return
+		}
+	}
+	batch, pos, err := priv.CoverIdx(idx, r.decoys, r.batchSize)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pubShares, errs, err := r.getShares(ctx, batch)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for i, q := range queries {
+		if q.err = errs[pos[i]]; q.err == nil {
+			q.output, q.err = q.priv.GetOutput(q.input, pubShares[pos[i]])
+		}
+		close(q.done)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// batchClient records the size of each GetShares request.
+type batchClient struct {
+	pb.StoreProviderClient
+	mu      sync.Mutex
+	sizes   []int
+	getOnes int
+}
+
+func (c *batchClient) GetShare(ctx context.Context, in *pb.ShareRequest, opts ...grpc.CallOption) (*pb.ShareReply, error) {
+	c.mu.Lock()
+	c.getOnes++
+	c.mu.Unlock()
+	return c.StoreProviderClient.GetShare(ctx, in, opts...)
+}
+
+func (c *batchClient) GetShares(ctx context.Context, in *pb.SharesRequest, opts ...grpc.CallOption) (*pb.SharesReply, error) {
+	c.mu.Lock()
+	c.sizes = append(c.sizes, len(in.GetIndex()))
+	c.mu.Unlock()
+	return c.StoreProviderClient.GetShares(ctx, in, opts...)
+}
+
+// batches returns the number of GetShares requests and checks that each has
+// the expected size.
+func (c *batchClient) batches(t *testing.T, batchSize int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.getOnes > 0 {
+		t.Errorf("made %d GetShare requests, expected none", c.getOnes)
+	}
+	for i, n := range c.sizes {
+		if n != batchSize {
+			t.Errorf("batch %d has %d indices, expected %d", i, n, batchSize)
+		}
+	}
+	return len(c.sizes)
+}
+
+// checkOutputs checks the results of r.GetMany(inputs).
+func checkOutputs(t *testing.T, inputs, outputs []string, errs []error) {
+	for i, in := range inputs {
+		if out, ok := testM[in]; ok && (errs[i] != nil || outputs[i] != out) {
+			t.Errorf("output %d = (%q, %v), expected (%q, nil)", i, outputs[i], errs[i], out)
+		} else if !ok && errs[i] != store.ItemNotFound {
+			t.Errorf("output %d has error %v, expected %q", i, errs[i], store.ItemNotFound)
+		}
+	}
+}
+
+func TestCoverTraffic(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	conn := s.dial(t, "alice", K)
+	defer conn.Close()
+	s.put(t, conn, K, testM, 0)
+	ctx := context.Background()
+
+	c := &batchClient{StoreProviderClient: conn.Client()}
+	r := New(c, "alice", K, WithCoverTraffic(4, []string{"hello", "merry"}))
+	defer r.Close()
+
+	if output, err := r.Get(ctx, "hip"); err != nil || output != "hop" {
+		t.Errorf("r.Get() = (%q, %v), expected (%q, nil)", output, err, "hop")
+	}
+	inputs := []string{"hip", "not an input", "merry", "hello", "hip", "hello"}
+	outputs, errs, err := r.GetMany(ctx, inputs)
+	if err != nil {
+		t.Fatalf("r.GetMany() fails: %s", err)
+	}
+	checkOutputs(t, inputs, outputs, errs)
+	if n := c.batches(t, 4); n != 3 {
+		t.Errorf("made %d GetShares requests, expected 3", n)
+	}
+}
+
+func TestFixedRate(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	conn := s.dial(t, "alice", K)
+	defer conn.Close()
+	s.put(t, conn, K, testM, 0)
+	ctx := context.Background()
+
+	c := &batchClient{StoreProviderClient: conn.Client()}
+	r := New(c, "alice", K, WithCoverTraffic(2, []string{"hello"}),
+		WithFixedRate(10*time.Millisecond))
+
+	inputs := []string{"hip", "not an input", "merry"}
+	outputs, errs, err := r.GetMany(ctx, inputs)
+	if err != nil {
+		t.Fatalf("r.GetMany() fails: %s", err)
+	}
+	checkOutputs(t, inputs, outputs, errs)
+	if output, err := r.Get(ctx, "hello"); err != nil || output != "world" {
+		t.Errorf("r.Get() = (%q, %v), expected (%q, nil)", output, err, "world")
+	}
+
+	// Requests are made even if there are no queries.
+	n := c.batches(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	if m := c.batches(t, 2); m <= n {
+		t.Errorf("made %d GetShares requests while idle, expected some", m-n)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("r.Close() fails: %s", err)
+	}
+	if _, err := r.Get(ctx, "hip"); err != ErrorClosed {
+		t.Errorf("r.Get() returns %v after r.Close(), expected %q", err, ErrorClosed)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("second r.Close() fails: %s", err)
+	}
+}
+
+// hungClient never answers GetShares requests.
+type hungClient struct {
+	pb.StoreProviderClient
+}
+
+func (c hungClient) GetShares(ctx context.Context, in *pb.SharesRequest, opts ...grpc.CallOption) (*pb.SharesReply, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// Test that r.Close() cancels a batch that is in flight.
+func TestFixedRateClose(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	conn := s.dial(t, "alice", K)
+	defer conn.Close()
+	s.put(t, conn, K, testM, 0)
+
+	r := New(hungClient{conn.Client()}, "alice", K, WithCoverTraffic(2, []string{"hello"}),
+		WithFixedRate(time.Millisecond))
+	time.Sleep(20 * time.Millisecond) // Wait for a batch to be sent.
+	closed := make(chan error)
+	go func() { closed <- r.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("r.Close() fails: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("r.Close() is blocked by a hung request")
+	}
+}
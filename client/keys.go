@@ -0,0 +1,44 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"golang.org/x/net/context"
+)
+
+// GetKdfHeader fetches the KDF header of the user's store from the
+// StoreProvider. It returns nil if the store has no header. The header is not
+// authenticated, so the client should derive the key with
+// store.KdfPolicy.DeriveKey().
+func GetKdfHeader(ctx context.Context, client pb.StoreProviderClient, userId string) (*pb.KdfHeader, error) {
+	reply, err := client.GetParams(ctx, &pb.ParamsRequest{UserId: userId})
+	if err != nil {
+		return nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, err
+	}
+	return reply.GetKdfHeader(), nil
+}
+
+// DeriveKeyFromProvider derives the key for the password and (optional) salt
+// by making an EvaluateOprf request to the StoreProvider. (See
+// store.BlindPassword().) The result is a key for store.NewPrivStore().
+func DeriveKeyFromProvider(ctx context.Context, client pb.StoreProviderClient, userId string, password, salt []byte) ([]byte, error) {
+	blind, blinded, err := store.BlindPassword(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := client.EvaluateOprf(ctx, &pb.OprfRequest{
+		UserId:         userId,
+		BlindedElement: blinded,
+	})
+	if err != nil {
+		return nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, err
+	}
+	return blind.Finalize(reply.GetEvaluatedElement())
+}
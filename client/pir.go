@@ -0,0 +1,76 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"golang.org/x/net/context"
+)
+
+// GetFromPirProviders looks up input in the store of the user held by two
+// StoreProviders using the two-server PIR protocol. (See
+// store.PrivStore.GetPirQuery().) The providers must not collude, and they must
+// hold the same store.
+func GetFromPirProviders(ctx context.Context, priv *store.PrivStore, client0, client1 pb.StoreProviderClient, userId, input string) (string, error) {
+	query0, query1, err := priv.GetPirQuery(input)
+	if err != nil {
+		return "", err
+	}
+	pubShare0, err := getPirShare(ctx, client0, userId, query0)
+	if err != nil {
+		return "", err
+	}
+	pubShare1, err := getPirShare(ctx, client1, userId, query1)
+	if err != nil {
+		return "", err
+	}
+	return priv.GetPirOutput(input, pubShare0, pubShare1)
+}
+
+// getPirShare makes a GetPirShare request.
+func getPirShare(ctx context.Context, client pb.StoreProviderClient, userId string, query [][]byte) ([][]byte, error) {
+	reply, err := client.GetPirShare(ctx, &pb.PirRequest{
+		UserId: userId,
+		Query:  query,
+	})
+	if err != nil {
+		return nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, err
+	}
+	return reply.GetPubShare(), nil
+}
+
+// GetSimplePirHint downloads the single-server PIR hint for the user's store
+// from the StoreProvider. (See store.NewSimplePirClient().)
+func GetSimplePirHint(ctx context.Context, client pb.StoreProviderClient, userId string) (*pb.SimplePirHint, error) {
+	reply, err := client.GetSimplePirHint(ctx, &pb.SimplePirHintRequest{UserId: userId})
+	if err != nil {
+		return nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, err
+	}
+	return reply.GetHint(), nil
+}
+
+// GetFromSimplePirProvider looks up input in the store of the user held by the
+// StoreProvider using single-server PIR, where c was created from the hint
+// returned by GetSimplePirHint().
+func GetFromSimplePirProvider(ctx context.Context, c *store.SimplePirClient, client pb.StoreProviderClient, userId, input string) (string, error) {
+	q, query, err := c.Query(input)
+	if err != nil {
+		return "", err
+	}
+	reply, err := client.GetSimplePirShare(ctx, &pb.PirRequest{
+		UserId: userId,
+		Query:  query,
+	})
+	if err != nil {
+		return "", err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return "", err
+	}
+	return c.Output(q, reply.GetPubShare())
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"golang.org/x/net/context"
+)
+
+// Returned by PutStore(), DeleteStore(), and PutKeyEnvelope() if the version of
+// the user's store (or envelope) is not the expected version. This means that
+// it was changed by another client since the expected version was read.
+const ErrorVersionMismatch = store.Error("store version mismatch")
+
+// PutStore uploads pub to the StoreProvider as the user's store. The store is
+// replaced only if its current version is expectedVersion. (Use 0 if the user
+// has never had a store, or the version returned by DeleteStore() if its store
+// was deleted.) It returns the new version of the store.
+//
+// If the version doesn't match, then it returns ErrorVersionMismatch along with
+// the current version of the store. In this case, the caller should fetch the
+// current state of the store before trying again.
+func PutStore(ctx context.Context, client pb.StoreProviderClient, userId string, pub *store.PubStore, expectedVersion int64) (int64, error) {
+	table := pub.GetProto()
+	if table == nil {
+		return 0, store.ErrorClosed
+	}
+	reply, err := client.PutStore(ctx, &pb.PutStoreRequest{
+		UserId:          userId,
+		Store:           table,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return reply.GetVersion(), providerError(reply.GetError())
+}
+
+// DeleteStore deletes the user's store from the StoreProvider. The store is
+// deleted only if its current version is expectedVersion; otherwise it returns
+// ErrorVersionMismatch along with the current version of the store. On
+// success, it returns the version of the deleted store. Versions are not
+// reused, so this is the expected version for the next call to PutStore().
+func DeleteStore(ctx context.Context, client pb.StoreProviderClient, userId string, expectedVersion int64) (int64, error) {
+	reply, err := client.DeleteStore(ctx, &pb.DeleteStoreRequest{
+		UserId:          userId,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return reply.GetVersion(), providerError(reply.GetError())
+}
+
+// GetStoreVersion returns the current version of the user's store.
+func GetStoreVersion(ctx context.Context, client pb.StoreProviderClient, userId string) (int64, error) {
+	reply, err := client.GetStoreVersion(ctx, &pb.StoreVersionRequest{UserId: userId})
+	if err != nil {
+		return 0, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return 0, err
+	}
+	return reply.GetVersion(), nil
+}
+
+// GetKeyEnvelope fetches the user's key envelope from the StoreProvider.
+func GetKeyEnvelope(ctx context.Context, client pb.StoreProviderClient, userId string) (*pb.KeyEnvelope, error) {
+	reply, err := client.GetKeyEnvelope(ctx, &pb.KeyEnvelopeRequest{UserId: userId})
+	if err != nil {
+		return nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, err
+	}
+	return reply.GetEnvelope(), nil
+}
+
+// PutKeyEnvelope uploads env to the StoreProvider as the user's key envelope.
+// Like PutStore(), the envelope is replaced only if its current version is
+// expectedVersion; otherwise it returns ErrorVersionMismatch along with the
+// current version. (Use env.GetVersion() if env was fetched with
+// GetKeyEnvelope().) It returns the new version of the envelope.
+func PutKeyEnvelope(ctx context.Context, client pb.StoreProviderClient, userId string, env *pb.KeyEnvelope, expectedVersion int64) (int64, error) {
+	reply, err := client.PutKeyEnvelope(ctx, &pb.PutKeyEnvelopeRequest{
+		UserId:          userId,
+		Envelope:        env,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return reply.GetVersion(), providerError(reply.GetError())
+}
@@ -0,0 +1,568 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+// Package client implements the client side of the StoreProvider RPC specified
+// in store.proto.
+//
+// A RemoteStore looks up inputs in a user's store held by a StoreProvider:
+//
+//	r, err := client.Dial(address, user, K,
+//		client.WithDialOptions(grpc.WithInsecure()),
+//		client.WithTimeout(5*time.Second))
+//	defer r.Close()
+//	output, err := r.Get(ctx, input)
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Returned by RemoteStore if the provider has no store for the user.
+const ErrorBadUser = store.Error("user not found")
+
+// Returned by RemoteStore if the provider rejects the user's credentials.
+const ErrorUnauthenticated = store.Error("unauthenticated")
+
+// The maximum number of indices in a GetShares request. This is the limit
+// enforced by provider.DirProvider; larger requests are split.
+const MaxShares = 4096
+
+// providerError returns the error corresponding to a StoreProviderError.
+func providerError(code pb.StoreProviderError) error {
+	switch code {
+	case pb.StoreProviderError_OK:
+		return nil
+	case pb.StoreProviderError_BAD_USER:
+		return ErrorBadUser
+	case pb.StoreProviderError_INDEX:
+		return store.ErrorIdx
+	case pb.StoreProviderError_ITEM_NOT_FOUND:
+		return store.ItemNotFound
+	case pb.StoreProviderError_UNAUTHENTICATED:
+		return ErrorUnauthenticated
+	case pb.StoreProviderError_VERSION_MISMATCH:
+		return ErrorVersionMismatch
+	}
+	return store.Error(fmt.Sprintf("provider returns error %s", code))
+}
+
+// The minimum time between fetches of the parameters of the store. (See
+// RemoteStore.Get().)
+const minRefresh = time.Second
+
+// An Option configures a RemoteStore.
+type Option func(*RemoteStore)
+
+// WithDialOptions sets the options used by Dial() to connect to the provider,
+// e.g., grpc.WithTransportCredentials() or grpc.WithPerRPCCredentials().
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(r *RemoteStore) {
+		r.dialOpts = append(r.dialOpts, opts...)
+	}
+}
+
+// WithTimeout sets the timeout of each RPC. By default, there is no timeout
+// other than the deadline of the caller's context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *RemoteStore) {
+		r.timeout = timeout
+	}
+}
+
+// WithRetries sets the number of times an RPC is retried if the provider is
+// unavailable. The i-th retry is made after waiting i*backoff. By default,
+// RPCs are not retried.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(r *RemoteStore) {
+		r.retries = retries
+		r.backoff = backoff
+	}
+}
+
+// WithCommitment sets the commitment of the store, as computed by
+// store.PubStore.Commitment(). The provider is asked for verifiable shares,
+// and a share that doesn't match the commitment (e.g., because the provider
+// answers from an older store) results in store.ErrorBadProof.
+func WithCommitment(commitment []byte) Option {
+	return func(r *RemoteStore) {
+		r.commitment = append([]byte(nil), commitment...)
+	}
+}
+
+// WithMinVersion sets the minimum version of the store. (See
+// store.WithVersion().) Parameters of an older store are rejected with
+// store.ErrorStaleStore. Once the RemoteStore has accepted a version of the
+// store, it rejects any older version, so the provider can't roll back the
+// store while r is in use. By default, any version is accepted initially.
+func WithMinVersion(version int64) Option {
+	return func(r *RemoteStore) {
+		r.minVersion = version
+	}
+}
+
+// RemoteStore is a client for a user's store held by a StoreProvider. The
+// parameters of the store are fetched once and cached; they are refreshed if
+// the store appears to have been replaced.
+//
+// The methods of RemoteStore are safe for concurrent use, except Close().
+type RemoteStore struct {
+	client pb.StoreProviderClient
+	conn   *grpc.ClientConn // nil if the client was provided by the caller.
+	user   string
+	key    []byte
+
+	dialOpts   []grpc.DialOption
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	commitment []byte
+
+	// Cover traffic. (See WithCoverTraffic() and WithFixedRate().)
+	batchSize int
+	decoys    []string
+	interval  time.Duration
+	stop      context.Context // Canceled by r.Close().
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+
+	qmu     sync.Mutex // Protects pending and closed.
+	pending []*query
+	closed  bool
+
+	mu         sync.Mutex // Protects the fields below.
+	params     *pb.Params
+	priv       *store.PrivStore
+	fetched    time.Time // When the parameters were last fetched.
+	minVersion int64     // The minimum version of the store. (See WithMinVersion().)
+}
+
+// New creates a RemoteStore for the user's store held by the provider, where K
+// is the store key.
+//
+// You should call r.Close() when you are done with r.
+func New(client pb.StoreProviderClient, user string, K []byte, opts ...Option) *RemoteStore {
+	r := newRemoteStore(client, user, K, opts)
+	r.start()
+	return r
+}
+
+// Dial connects to the provider at address and creates a RemoteStore for the
+// user's store, where K is the store key. The connection is closed by
+// r.Close().
+func Dial(address, user string, K []byte, opts ...Option) (*RemoteStore, error) {
+	r := newRemoteStore(nil, user, K, opts)
+	conn, err := grpc.Dial(address, r.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+	r.client = pb.NewStoreProviderClient(conn)
+	r.start()
+	return r, nil
+}
+
+// newRemoteStore creates a RemoteStore with the given options. The background
+// loop, if any, is not started until r.start() is called.
+func newRemoteStore(client pb.StoreProviderClient, user string, K []byte, opts []Option) *RemoteStore {
+	r := &RemoteStore{
+		client: client,
+		user:   user,
+		key:    append([]byte(nil), K...),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// start starts the background loop if queries are sent at a fixed rate. (See
+// WithFixedRate().)
+func (r *RemoteStore) start() {
+	if r.interval > 0 {
+		if r.batchSize == 0 {
+			r.batchSize = 1
+		}
+		r.stop, r.cancel = context.WithCancel(context.Background())
+		r.wg.Add(1)
+		go r.run()
+	}
+}
+
+// Client returns the underlying StoreProvider client. This is useful for
+// making requests not covered by RemoteStore, e.g., PutStore.
+func (r *RemoteStore) Client() pb.StoreProviderClient {
+	return r.client
+}
+
+// Close releases the private context of the store and closes the connection
+// to the provider, if it was opened by Dial(). If background queries are
+// enabled, then they are stopped. It is safe to call Close() more than once.
+func (r *RemoteStore) Close() error {
+	r.qmu.Lock()
+	if r.closed {
+		r.qmu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.qmu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.priv != nil {
+		r.priv.Close()
+		r.priv = nil
+	}
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+	return nil
+}
+
+// call makes an RPC, retrying it if the provider is unavailable.
+func (r *RemoteStore) call(ctx context.Context, rpc func(ctx context.Context) error) error {
+	var err error
+	for i := 0; i <= r.retries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(i) * r.backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, r.timeout)
+		}
+		err = rpc(callCtx)
+		cancel()
+		if !retryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// retryable returns true if err indicates the RPC may succeed if it is retried.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// Params returns the parameters of the store. They are fetched from the
+// provider the first time this is called.
+func (r *RemoteStore) Params(ctx context.Context) (*pb.Params, error) {
+	_, params, err := r.getPriv(ctx)
+	return params, err
+}
+
+// getPriv returns the private context of the store and the parameters it was
+// created from. They are created the first time this is called.
+func (r *RemoteStore) getPriv(ctx context.Context) (*store.PrivStore, *pb.Params, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.priv == nil {
+		if err := r.refresh(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	return r.priv, r.params, nil
+}
+
+// refresh fetches the parameters of the store and, if they changed, creates a
+// new private context. The caller must hold r.mu.
+func (r *RemoteStore) refresh(ctx context.Context) error {
+	var reply *pb.ParamsReply
+	err := r.call(ctx, func(ctx context.Context) (err error) {
+		reply, err = r.client.GetParams(ctx, &pb.ParamsRequest{UserId: r.user})
+		return err
+	})
+	if err != nil {
+		return err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return err
+	}
+	r.fetched = time.Now()
+	if r.priv != nil && proto.Equal(r.params, reply.GetParams()) {
+		return nil
+	}
+	priv, err := store.NewPrivStore(r.key, reply.GetParams(), r.minVersion)
+	if err != nil {
+		return err
+	}
+	if v := priv.GetParams().GetVersion(); v > r.minVersion {
+		r.minVersion = v
+	}
+	if r.commitment != nil {
+		priv.SetCommitment(r.commitment)
+	}
+	// The old context may still be in use by other requests, so it is left to
+	// the garbage collector rather than closed.
+	r.params, r.priv = reply.GetParams(), priv
+	return nil
+}
+
+// refreshIfStale refetches the parameters of the store if they are the ones
+// used by old, unless they were fetched less than minRefresh ago. It returns
+// true if the parameters are not the old ones, in which case the lookup should
+// be retried.
+func (r *RemoteStore) refreshIfStale(ctx context.Context, old *pb.Params) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.params == old && time.Since(r.fetched) >= minRefresh {
+		if err := r.refresh(ctx); err != nil {
+			return false, err
+		}
+	}
+	return r.params != old, nil
+}
+
+// Get looks up input in the store and returns the output. It returns
+// store.ItemNotFound if the input is not in the map.
+func (r *RemoteStore) Get(ctx context.Context, input string) (string, error) {
+	priv, params, err := r.getPriv(ctx)
+	if err != nil {
+		return "", err
+	}
+	output, err := r.getOne(ctx, priv, input)
+	if err != store.ItemNotFound && err != store.ErrorIdx {
+		return output, err
+	}
+
+	// The store may have been replaced since the parameters were fetched. If
+	// so, then retry the lookup with the new parameters.
+	if stale, refreshErr := r.refreshIfStale(ctx, params); refreshErr != nil {
+		return "", refreshErr
+	} else if !stale {
+		return "", err
+	}
+	if priv, _, err = r.getPriv(ctx); err != nil {
+		return "", err
+	}
+	return r.getOne(ctx, priv, input)
+}
+
+// getOne looks up input in the store using the private context priv. If cover
+// traffic is enabled, then the query is sent in a batch with dummy queries.
+func (r *RemoteStore) getOne(ctx context.Context, priv *store.PrivStore, input string) (string, error) {
+	if r.batchSize == 0 {
+		return r.get(ctx, priv, input)
+	}
+	outputs, errs, err := r.lookup(ctx, priv, []string{input})
+	if err != nil {
+		return "", err
+	}
+	return outputs[0], errs[0]
+}
+
+// get looks up input in the store using the private context priv.
+func (r *RemoteStore) get(ctx context.Context, priv *store.PrivStore, input string) (string, error) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return "", err
+	}
+	var reply *pb.ShareReply
+	err = r.call(ctx, func(ctx context.Context) (err error) {
+		reply, err = r.client.GetShare(ctx, &pb.ShareRequest{
+			UserId:     r.user,
+			X:          int32(x),
+			Y:          int32(y),
+			Verifiable: r.commitment != nil,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return "", err
+	}
+	return priv.GetOutput(input, reply.GetPubShare())
+}
+
+// List calls fn for each input in the store, fetching the index of the inputs
+// from the provider one page at a time. (See store.WithIndex().) If fn returns
+// an error, then List stops and returns it. It returns store.ItemNotFound if
+// the store has no index and store.ErrorBadIndex if a page is not authentic or
+// the store is replaced while it is being listed.
+func (r *RemoteStore) List(ctx context.Context, fn func(input string) error) error {
+	priv, params, err := r.getPriv(ctx)
+	if err != nil {
+		return err
+	}
+	started, err := r.list(ctx, priv, fn)
+	if (err != store.ErrorBadIndex && err != store.ItemNotFound) || started {
+		return err
+	}
+
+	// The store may have been replaced since the parameters were fetched. If
+	// so, and no input was listed yet, then retry with the new parameters.
+	if stale, refreshErr := r.refreshIfStale(ctx, params); refreshErr != nil {
+		return refreshErr
+	} else if !stale {
+		return err
+	}
+	if priv, _, err = r.getPriv(ctx); err != nil {
+		return err
+	}
+	_, err = r.list(ctx, priv, fn)
+	return err
+}
+
+// list lists the inputs using the private context priv. It returns true if the
+// first page was opened, i.e., if fn may have been called.
+func (r *RemoteStore) list(ctx context.Context, priv *store.PrivStore, fn func(input string) error) (bool, error) {
+	pageCt := 1
+	for page := 0; page < pageCt; page++ {
+		var reply *pb.IndexPageReply
+		err := r.call(ctx, func(ctx context.Context) (err error) {
+			reply, err = r.client.GetIndexPage(ctx, &pb.IndexPageRequest{
+				UserId: r.user,
+				Page:   int32(page),
+			})
+			return err
+		})
+		if err != nil {
+			return page > 0, err
+		} else if err = providerError(reply.GetError()); err != nil {
+			return page > 0, err
+		}
+		if page == 0 {
+			pageCt = int(reply.GetPageCt())
+		} else if int(reply.GetPageCt()) != pageCt {
+			return true, store.ErrorBadIndex
+		}
+		inputs, err := priv.OpenIndexPage(page, reply.GetPage())
+		if err != nil {
+			return page > 0, err
+		}
+		for _, input := range inputs {
+			if err = fn(input); err != nil {
+				return true, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// Keys returns the inputs in the store. (See List().)
+func (r *RemoteStore) Keys(ctx context.Context) ([]string, error) {
+	var inputs []string
+	err := r.List(ctx, func(input string) error {
+		inputs = append(inputs, input)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+// GetMany looks up each input in the store with GetShares requests of at most
+// MaxShares indices each, or, if cover traffic is enabled, with as many batches
+// as needed. (See
+// WithCoverTraffic().) The i-th output corresponds to inputs[i]; if the input
+// is not in the map, then the i-th error is store.ItemNotFound. The last return
+// value is set if the request as a whole fails.
+func (r *RemoteStore) GetMany(ctx context.Context, inputs []string) ([]string, []error, error) {
+	priv, _, err := r.getPriv(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.batchSize > 0 {
+		return r.lookup(ctx, priv, inputs)
+	}
+	idx, err := priv.GetIdxMany(inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubShares, errs, err := r.getShares(ctx, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs := getOutputs(priv, inputs, pubShares, errs)
+	return outputs, errs, nil
+}
+
+// getShares requests the shares for the indices, making one GetShares request
+// for every MaxShares of them. The i-th share corresponds to idx[i]; if it is
+// not available, then the i-th error says why.
+func (r *RemoteStore) getShares(ctx context.Context, idx []store.Index) ([][]byte, []error, error) {
+	pubShares := make([][]byte, 0, len(idx))
+	errs := make([]error, 0, len(idx))
+	for len(idx) > 0 {
+		n := len(idx)
+		if n > MaxShares {
+			n = MaxShares
+		}
+		chunkShares, chunkErrs, err := r.getSharesChunk(ctx, idx[:n])
+		if err != nil {
+			return nil, nil, err
+		}
+		pubShares = append(pubShares, chunkShares...)
+		errs = append(errs, chunkErrs...)
+		idx = idx[n:]
+	}
+	return pubShares, errs, nil
+}
+
+// getSharesChunk makes a GetShares request for at most MaxShares indices.
+func (r *RemoteStore) getSharesChunk(ctx context.Context, idx []store.Index) ([][]byte, []error, error) {
+	in := &pb.SharesRequest{
+		UserId:     r.user,
+		Index:      make([]*pb.SharesRequest_Index, len(idx)),
+		Verifiable: r.commitment != nil,
+	}
+	for i := range idx {
+		in.Index[i] = &pb.SharesRequest_Index{X: int32(idx[i].X), Y: int32(idx[i].Y)}
+	}
+	var reply *pb.SharesReply
+	err := r.call(ctx, func(ctx context.Context) (err error) {
+		reply, err = r.client.GetShares(ctx, in)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	} else if err = providerError(reply.GetError()); err != nil {
+		return nil, nil, err
+	} else if len(reply.GetShare()) != len(idx) {
+		return nil, nil, store.Error("GetShares returns the wrong number of shares")
+	}
+
+	pubShares := make([][]byte, len(idx))
+	errs := make([]error, len(idx))
+	for i, share := range reply.GetShare() {
+		if errs[i] = providerError(share.GetError()); errs[i] == nil {
+			pubShares[i] = share.GetPubShare()
+		}
+	}
+	return pubShares, errs, nil
+}
+
+// getOutputs computes the output for each input from its share. If the i-th
+// error is set, then the i-th output is not computed; otherwise the i-th error
+// is set to the error returned by priv.GetOutput().
+func getOutputs(priv *store.PrivStore, inputs []string, pubShares [][]byte, errs []error) []string {
+	outputs, outputErrs := priv.GetOutputMany(inputs, pubShares)
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = outputErrs[i]
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"github.com/cjpatton/store/provider"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testM = map[string]string{
+	"hello": "world",
+	"hip":   "hop",
+	"merry": "christmas",
+}
+
+// testServer serves a provider.DirProvider over an in-memory connection.
+type testServer struct {
+	dir    string
+	p      *provider.DirProvider
+	server *grpc.Server
+	lis    *bufconn.Listener
+}
+
+func newTestServer(t *testing.T) *testServer {
+	dir, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		dir:    dir,
+		p:      provider.NewDirProvider(dir, 0, time.Second, nil, nil),
+		server: grpc.NewServer(),
+		lis:    bufconn.Listen(1 << 20),
+	}
+	s.p.AllowInsecureWrites = true
+	pb.RegisterStoreProviderServer(s.server, s.p)
+	go s.server.Serve(s.lis)
+	return s
+}
+
+func (s *testServer) close() {
+	s.server.Stop()
+	s.p.Close()
+	os.RemoveAll(s.dir)
+}
+
+// dial creates a RemoteStore connected to the server.
+func (s *testServer) dial(t *testing.T, user string, K []byte, opts ...Option) *RemoteStore {
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return s.lis.Dial()
+	}
+	opts = append(opts, WithDialOptions(grpc.WithInsecure(), grpc.WithContextDialer(dialer)))
+	r, err := Dial("bufnet", user, K, opts...)
+	if err != nil {
+		t.Fatalf("Dial() fails: %s", err)
+	}
+	return r
+}
+
+// put creates a store for M and uploads it as the user's store. It returns the
+// commitment of the store.
+func (s *testServer) put(t *testing.T, r *RemoteStore, K []byte, M map[string]string, expectedVersion int64, opts ...store.Option) []byte {
+	pub, priv, err := store.NewStore(K, M, opts...)
+	if err != nil {
+		t.Fatalf("store.NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if _, err = PutStore(context.Background(), r.Client(), "alice", pub, expectedVersion); err != nil {
+		t.Fatalf("PutStore() fails: %s", err)
+	}
+	return pub.Commitment()
+}
+
+func TestRemoteStore(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	r := s.dial(t, "alice", K, WithTimeout(time.Second))
+	defer r.Close()
+	ctx := context.Background()
+
+	if _, err := r.Get(ctx, "hip"); err != ErrorBadUser {
+		t.Errorf("r.Get() returns %v for a missing store, expected %q", err, ErrorBadUser)
+	}
+
+	s.put(t, r, K, testM, 0)
+	for in, out := range testM {
+		if output, err := r.Get(ctx, in); err != nil || output != out {
+			t.Errorf("r.Get(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	if _, err := r.Get(ctx, "not an input"); err != store.ItemNotFound {
+		t.Errorf("r.Get() returns %v, expected %q", err, store.ItemNotFound)
+	}
+
+	inputs := []string{"hip", "not an input", "merry"}
+	outputs, errs, err := r.GetMany(ctx, inputs)
+	if err != nil {
+		t.Fatalf("r.GetMany() fails: %s", err)
+	}
+	for i, in := range inputs {
+		if out, ok := testM[in]; ok && (errs[i] != nil || outputs[i] != out) {
+			t.Errorf("output %d = (%q, %v), expected (%q, nil)", i, outputs[i], errs[i], out)
+		} else if !ok && errs[i] != store.ItemNotFound {
+			t.Errorf("output %d has error %v, expected %q", i, errs[i], store.ItemNotFound)
+		}
+	}
+
+	// The parameters are refreshed when the store is replaced.
+	params, err := r.Params(ctx)
+	if err != nil {
+		t.Fatalf("r.Params() fails: %s", err)
+	}
+	s.put(t, r, K, map[string]string{"hip": "hooray"}, 1)
+	r.mu.Lock()
+	r.fetched = time.Time{}
+	r.mu.Unlock()
+	if output, err := r.Get(ctx, "hip"); err != nil || output != "hooray" {
+		t.Errorf("r.Get(\"hip\") = (%q, %v), expected (\"hooray\", nil)", output, err)
+	}
+	if newParams, _ := r.Params(ctx); newParams == params {
+		t.Error("r.Params() returns the old parameters")
+	}
+}
+
+// flakyClient fails the first few GetParams requests.
+type flakyClient struct {
+	pb.StoreProviderClient
+	failures int
+}
+
+func (c *flakyClient) GetParams(ctx context.Context, in *pb.ParamsRequest, opts ...grpc.CallOption) (*pb.ParamsReply, error) {
+	if c.failures > 0 {
+		c.failures--
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return c.StoreProviderClient.GetParams(ctx, in, opts...)
+}
+
+// Test that GetMany splits requests that are larger than the provider allows.
+func TestRemoteStoreGetManyLarge(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	var requests int
+	count := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/pb.StoreProvider/GetShares" {
+			requests++
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	r := s.dial(t, "alice", K, WithDialOptions(grpc.WithUnaryInterceptor(count)))
+	defer r.Close()
+	ctx := context.Background()
+
+	M := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		M[fmt.Sprintf("input %d", i)] = fmt.Sprintf("output %d", i)
+	}
+	s.put(t, r, K, M, 0)
+
+	inputs := make([]string, MaxShares+1000)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("input %d", i%200)
+	}
+	outputs, errs, err := r.GetMany(ctx, inputs)
+	if err != nil {
+		t.Fatalf("r.GetMany() fails: %s", err)
+	}
+	if len(outputs) != len(inputs) || len(errs) != len(inputs) {
+		t.Fatalf("r.GetMany() returns %d outputs and %d errors, expected %d", len(outputs), len(errs), len(inputs))
+	}
+	for i, in := range inputs {
+		if out, ok := M[in]; ok && (errs[i] != nil || outputs[i] != out) {
+			t.Errorf("output %d = (%q, %v), expected (%q, nil)", i, outputs[i], errs[i], out)
+		} else if !ok && errs[i] != store.ItemNotFound {
+			t.Errorf("output %d has error %v, expected %q", i, errs[i], store.ItemNotFound)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("r.GetMany() makes %d GetShares requests, expected 2", requests)
+	}
+}
+
+func TestRemoteStoreRetries(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	conn := s.dial(t, "alice", K)
+	defer conn.Close()
+	s.put(t, conn, K, testM, 0)
+
+	c := &flakyClient{conn.Client(), 2}
+	r := New(c, "alice", K, WithRetries(2, time.Millisecond))
+	defer r.Close()
+	if output, err := r.Get(context.Background(), "hip"); err != nil || output != "hop" {
+		t.Errorf("r.Get(\"hip\") = (%q, %v), expected (\"hop\", nil)", output, err)
+	}
+
+	c = &flakyClient{conn.Client(), 2}
+	r = New(c, "alice", K, WithRetries(1, time.Millisecond))
+	defer r.Close()
+	if _, err := r.Get(context.Background(), "hip"); status.Code(err) != codes.Unavailable {
+		t.Errorf("r.Get() returns %v, expected Unavailable", err)
+	}
+}
+
+func TestRemoteStoreCommitment(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	conn := s.dial(t, "alice", K)
+	defer conn.Close()
+	commitment := s.put(t, conn, K, testM, 0)
+	ctx := context.Background()
+
+	r := New(conn.Client(), "alice", K, WithCommitment(commitment))
+	defer r.Close()
+	for in, out := range testM {
+		if output, err := r.Get(ctx, in); err != nil || output != out {
+			t.Errorf("r.Get(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	inputs := []string{"hip", "not an input"}
+	outputs, errs, err := r.GetMany(ctx, inputs)
+	if err != nil {
+		t.Fatalf("r.GetMany() fails: %s", err)
+	}
+	if errs[0] != nil || outputs[0] != "hop" || errs[1] != store.ItemNotFound {
+		t.Errorf("r.GetMany() = (%q, %v)", outputs, errs)
+	}
+
+	// The provider replaces the store with one the client didn't commit to.
+	s.put(t, conn, K, testM, 1)
+	r = New(conn.Client(), "alice", K, WithCommitment(commitment))
+	defer r.Close()
+	if _, err := r.Get(ctx, "hip"); err != store.ErrorBadProof {
+		t.Errorf("r.Get() returns %v, expected %q", err, store.ErrorBadProof)
+	}
+}
+
+func TestRemoteStoreMinVersion(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	r := s.dial(t, "alice", K)
+	defer r.Close()
+	ctx := context.Background()
+
+	s.put(t, r, K, testM, 0, store.WithVersion(2))
+	if output, err := r.Get(ctx, "hip"); err != nil || output != "hop" {
+		t.Errorf("r.Get(\"hip\") = (%q, %v), expected (\"hop\", nil)", output, err)
+	}
+
+	// The provider rolls back to an older store. r has accepted version 2, so
+	// it rejects the older store.
+	s.put(t, r, K, map[string]string{"hip": "hooray"}, 1, store.WithVersion(1))
+	r.mu.Lock()
+	r.fetched = time.Time{}
+	r.mu.Unlock()
+	if _, err := r.Get(ctx, "hip"); err != store.ErrorStaleStore {
+		t.Errorf("r.Get() returns %v after a rollback, expected %q", err, store.ErrorStaleStore)
+	}
+
+	// So does a new client that requires version 2.
+	r2 := New(r.Client(), "alice", K, WithMinVersion(2))
+	defer r2.Close()
+	if _, err := r2.Get(ctx, "hip"); err != store.ErrorStaleStore {
+		t.Errorf("r2.Get() returns %v, expected %q", err, store.ErrorStaleStore)
+	}
+	r3 := New(r.Client(), "alice", K, WithMinVersion(1))
+	defer r3.Close()
+	if output, err := r3.Get(ctx, "hip"); err != nil || output != "hooray" {
+		t.Errorf("r3.Get(\"hip\") = (%q, %v), expected (\"hooray\", nil)", output, err)
+	}
+}
+
+func TestRemoteStoreKeys(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+	K := store.GenerateKey()
+	r := s.dial(t, "alice", K)
+	defer r.Close()
+	ctx := context.Background()
+
+	s.put(t, r, K, testM, 0)
+	if _, err := r.Keys(ctx); err != store.ItemNotFound {
+		t.Errorf("r.Keys() returns %v without an index, expected %q", err, store.ItemNotFound)
+	}
+
+	M := make(map[string]string)
+	for i := 0; i < store.IndexPageSize+1; i++ {
+		M[fmt.Sprintf("input\n%d", i)] = "output"
+	}
+	s.put(t, r, K, M, 1, store.WithIndex())
+	r.mu.Lock()
+	r.fetched = time.Time{}
+	r.mu.Unlock()
+	keys, err := r.Keys(ctx)
+	if err != nil {
+		t.Fatalf("r.Keys() fails: %s", err)
+	}
+	if len(keys) != len(M) {
+		t.Errorf("len(r.Keys()) = %d, expected %d", len(keys), len(M))
+	}
+	for _, in := range keys {
+		if _, ok := M[in]; !ok {
+			t.Errorf("r.Keys() lists %q, which is not in the map", in)
+		}
+	}
+}
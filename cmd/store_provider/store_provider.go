@@ -0,0 +1,86 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+// store_provider serves the StoreProvider RPC specified in store.proto for
+// every user whose store is in a data directory. The public store of each user
+// is read from <user>.pub and the (optional) OPRF key from <user>.oprf. (See
+// provider.DirProvider.) Stores are loaded on demand and reloaded when their
+// files change, so stores may be added, replaced, or removed without
+// restarting the server. To replace a store, write the new file and rename it
+// to <user>.pub, or use the PutStore RPC. The RPCs that change the stores are
+// refused unless -auth is given, or -insecure_writes is set to let anyone
+// replace or delete any store.
+//
+// Usage: store_provider -data dir [-addr :50051] [-tls_cert cert.pem -tls_key key.pem] [-auth creds.json | -insecure_writes]
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"time"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/cjpatton/store/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+)
+
+var (
+	dataDir        = flag.String("data", "", "directory containing the stores")
+	addr           = flag.String("addr", ":50051", "address to listen on")
+	tlsCert        = flag.String("tls_cert", "", "TLS certificate file")
+	tlsKey         = flag.String("tls_key", "", "TLS private key file")
+	authConfig     = flag.String("auth", "", "credentials config file")
+	budget         = flag.Int64("mem_budget", 1<<30, "bytes of memory available for the stores (0 for no limit)")
+	oprfInterval   = flag.Duration("oprf_interval", time.Second, "minimum time between OPRF evaluations for a user")
+	insecureWrites = flag.Bool("insecure_writes", false, "allow unauthenticated requests to change the stores")
+)
+
+func main() {
+	flag.Parse()
+	if *dataDir == "" || flag.NArg() != 0 {
+		flag.Usage()
+		log.Fatal("error: -data is required")
+	}
+
+	opts := []grpc.ServerOption{}
+	if *tlsCert != "" || *tlsKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal("failed to load TLS credentials: ", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	} else {
+		log.Println("warning: serving without TLS")
+	}
+
+	var creds *provider.Credentials
+	if *authConfig != "" {
+		var err error
+		if creds, err = provider.LoadCredentials(*authConfig); err != nil {
+			log.Fatal("failed to load credentials: ", err)
+		}
+		opts = append(opts, grpc.UnaryInterceptor(creds.UnaryInterceptor))
+	} else if *insecureWrites {
+		log.Println("warning: serving unauthenticated writes")
+	}
+
+	storeProvider := provider.NewDirProvider(*dataDir, *budget, *oprfInterval, creds, log.Printf)
+	storeProvider.AllowInsecureWrites = *insecureWrites
+	defer storeProvider.Close()
+
+	// Begin serving.
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	log.Println("Serving stores in", *dataDir, "on", *addr)
+	s := grpc.NewServer(opts...)
+	pb.RegisterStoreProviderServer(s, storeProvider)
+	reflection.Register(s)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Returned by ProtoCodec.Decode() if New is not set.
+const ErrorNoNew = Error("ProtoCodec.New is not set")
+
+// A Codec encodes values of type T as byte strings and decodes them. It is used
+// by TypedStore to encode its inputs and outputs.
+//
+// A codec used for the inputs must be deterministic, i.e., it must encode equal
+// values the same way, since the encoding is what is looked up. StringCodec and
+// BytesCodec are. JSONCodec sorts the keys of maps, but a float may have more
+// than one value that is equal to it (e.g., 0 and -0, which are encoded
+// differently), and a json.RawMessage is copied as is. ProtoCodec and GobCodec
+// in general are not deterministic.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// StringCodec encodes a string as its bytes.
+type StringCodec struct{}
+
+func (StringCodec) Encode(value string) ([]byte, error) { return []byte(value), nil }
+
+func (StringCodec) Decode(data []byte) (string, error) { return string(data), nil }
+
+// BytesCodec encodes a byte string as itself.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(value []byte) ([]byte, error) { return value, nil }
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) { return data, nil }
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(value T) ([]byte, error) { return json.Marshal(value) }
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// GobCodec encodes values with encoding/gob. Each value is encoded on its own,
+// so the encoding includes the description of the type.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// ProtoCodec encodes protocol buffers. New returns an empty message to decode
+// into, e.g., func() *pb.Params { return new(pb.Params) }. Decode returns
+// ErrorNoNew if New is not set.
+type ProtoCodec[T proto.Message] struct {
+	New func() T
+}
+
+func (c ProtoCodec[T]) Encode(value T) ([]byte, error) { return proto.Marshal(value) }
+
+func (c ProtoCodec[T]) Decode(data []byte) (T, error) {
+	if c.New == nil {
+		var value T
+		return value, ErrorNoNew
+	}
+	value := c.New()
+	err := proto.Unmarshal(data, value)
+	return value, err
+}
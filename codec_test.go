@@ -0,0 +1,71 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+type testRecord struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+var record = testRecord{"dog", 3, []string{"good", "boy"}}
+
+// roundTrip encodes and decodes the value with the codec.
+func roundTrip[T any](t *testing.T, c Codec[T], value T) T {
+	data, err := c.Encode(value)
+	if err != nil {
+		t.Fatalf("%T.Encode() fails: %s", c, err)
+	}
+	decoded, err := c.Decode(data)
+	if err != nil {
+		t.Fatalf("%T.Decode() fails: %s", c, err)
+	}
+	return decoded
+}
+
+func TestCodecs(t *testing.T) {
+	if got := roundTrip[string](t, StringCodec{}, "a\x00b"); got != "a\x00b" {
+		t.Errorf("StringCodec: got %q", got)
+	}
+	if got := roundTrip[[]byte](t, BytesCodec{}, []byte{0, 1, 0xff}); !bytes.Equal(got, []byte{0, 1, 0xff}) {
+		t.Errorf("BytesCodec: got %x", got)
+	}
+	if got := roundTrip[testRecord](t, JSONCodec[testRecord]{}, record); !reflect.DeepEqual(got, record) {
+		t.Errorf("JSONCodec: got %v, expected %v", got, record)
+	}
+	if got := roundTrip[testRecord](t, GobCodec[testRecord]{}, record); !reflect.DeepEqual(got, record) {
+		t.Errorf("GobCodec: got %v, expected %v", got, record)
+	}
+	msg := &pb.KdfHeader{Kdf: pb.PasswordKdf_SCRYPT, Salt: []byte("salt"), LogN: 10}
+	c := ProtoCodec[*pb.KdfHeader]{New: func() *pb.KdfHeader { return new(pb.KdfHeader) }}
+	if got := roundTrip[*pb.KdfHeader](t, c, msg); !proto.Equal(got, msg) {
+		t.Errorf("ProtoCodec: got %v, expected %v", got, msg)
+	}
+}
+
+func TestCodecDecodeErrors(t *testing.T) {
+	garbage := []byte{0xff, 0x00, 0x7b}
+	if _, err := (JSONCodec[testRecord]{}).Decode(garbage); err == nil {
+		t.Error("JSONCodec.Decode() succeeds for garbage")
+	}
+	if _, err := (GobCodec[testRecord]{}).Decode(garbage); err == nil {
+		t.Error("GobCodec.Decode() succeeds for garbage")
+	}
+	c := ProtoCodec[*pb.KdfHeader]{New: func() *pb.KdfHeader { return new(pb.KdfHeader) }}
+	if _, err := c.Decode(garbage); err == nil {
+		t.Error("ProtoCodec.Decode() succeeds for garbage")
+	}
+	if _, err := (ProtoCodec[*pb.KdfHeader]{}).Decode(nil); err != ErrorNoNew {
+		t.Errorf("ProtoCodec.Decode() returns %v without New, expected %q", err, ErrorNoNew)
+	}
+}
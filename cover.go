@@ -0,0 +1,103 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// Returned by priv.CoverIdx() if the number of indices exceeds the batch size.
+const ErrorBatchTooLarge = Error("too many queries for the batch")
+
+// randInt returns an integer chosen uniformly at random from [0, n).
+func randInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// RandomIdx returns a dummy index, i.e., the index of a query whose only
+// purpose is to hide the real ones. If decoys is not empty, then the dummy is
+// the index of an input chosen uniformly at random from decoys. Otherwise, the
+// rows of the dummy are chosen uniformly at random.
+//
+// Note that random rows almost never correspond to an input in the map, and the
+// server can tell, since it knows which pairs of rows do. For dummies to be
+// indistinguishable from real queries, decoys should be inputs in the map,
+// e.g., those returned by priv.Keys() if the store has an index of its inputs.
+func (priv *PrivStore) RandomIdx(decoys []string) (Index, error) {
+	if len(decoys) > 0 {
+		i, err := randInt(len(decoys))
+		if err != nil {
+			return Index{}, err
+		}
+		x, y, err := priv.GetIdx(decoys[i])
+		return Index{x, y}, err
+	}
+	params := priv.dict.GetParams()
+	if params == nil {
+		return Index{}, ErrorClosed
+	}
+	tableLen := int(params.GetTableLen())
+	x, err := randInt(tableLen)
+	if err != nil {
+		return Index{}, err
+	}
+	y, err := randInt(tableLen)
+	if err != nil {
+		return Index{}, err
+	}
+	return Index{x, y}, nil
+}
+
+// CoverIdx hides the indices among dummy indices computed by
+// priv.RandomIdx(decoys). It returns a batch of exactly batchSize indices in
+// random order and the position of each of the real indices in the batch, so
+// that batch[pos[i]] == idx[i]. It returns ErrorBatchTooLarge if
+// len(idx) > batchSize.
+//
+// Sending every query in a batch of the same size (and at a fixed rate) hides
+// which of the queries are real. However, if an input is looked up more often
+// than the decoys are, then the server may still notice that its index is
+// queried more frequently than the others.
+func (priv *PrivStore) CoverIdx(idx []Index, decoys []string, batchSize int) ([]Index, []int, error) {
+	if len(idx) > batchSize {
+		return nil, nil, ErrorBatchTooLarge
+	}
+	batch := make([]Index, batchSize)
+	copy(batch, idx)
+	for i := len(idx); i < batchSize; i++ {
+		dummy, err := priv.RandomIdx(decoys)
+		if err != nil {
+			return nil, nil, err
+		}
+		batch[i] = dummy
+	}
+
+	// Shuffle the batch, keeping track of where the real indices go.
+	perm := make([]int, batchSize)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := batchSize - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	shuffled := make([]Index, batchSize)
+	pos := make([]int, len(idx))
+	for i := range perm {
+		// The i-th index of the batch goes to position perm[i].
+		shuffled[perm[i]] = batch[i]
+		if i < len(idx) {
+			pos[i] = perm[i]
+		}
+	}
+	return shuffled, pos, nil
+}
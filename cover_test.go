@@ -0,0 +1,69 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import "testing"
+
+func TestCoverIdx(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	inputs := []string{"hip", "this"}
+	idx, err := priv.GetIdxMany(inputs)
+	if err != nil {
+		t.Fatalf("priv.GetIdxMany() fails: %s", err)
+	}
+	decoys := []string{"is", "pretty\x00cool"}
+	decoyIdx, err := priv.GetIdxMany(decoys)
+	if err != nil {
+		t.Fatalf("priv.GetIdxMany() fails: %s", err)
+	}
+
+	batch, pos, err := priv.CoverIdx(idx, decoys, 10)
+	if err != nil {
+		t.Fatalf("priv.CoverIdx() fails: %s", err)
+	}
+	AssertIntEqError(t, "len(batch)", len(batch), 10)
+	AssertIntEqError(t, "len(pos)", len(pos), len(idx))
+	isReal := make(map[int]bool)
+	for i := range idx {
+		if batch[pos[i]] != idx[i] {
+			t.Errorf("batch[pos[%d]] = %v, expected %v", i, batch[pos[i]], idx[i])
+		}
+		isReal[pos[i]] = true
+	}
+	for i := range batch {
+		if !isReal[i] && batch[i] != decoyIdx[0] && batch[i] != decoyIdx[1] {
+			t.Errorf("batch[%d] = %v is not the index of a decoy", i, batch[i])
+		}
+	}
+
+	// Every query in the batch is answered.
+	pubShares, _ := pub.GetShares(batch)
+	outputs, errs := priv.GetOutputMany(inputs, [][]byte{pubShares[pos[0]], pubShares[pos[1]]})
+	for i, in := range inputs {
+		if errs[i] != nil || outputs[i] != goodM[in] {
+			t.Errorf("outputs[%d] = (%q, %v), expected (%q, nil)", i, outputs[i], errs[i], goodM[in])
+		}
+	}
+
+	// Without decoys, the dummies are random rows.
+	tableLen := int(priv.GetParams().GetTableLen())
+	if batch, _, err = priv.CoverIdx(nil, nil, 100); err != nil {
+		t.Fatalf("priv.CoverIdx() fails: %s", err)
+	}
+	for i := range batch {
+		if batch[i].X < 0 || batch[i].X >= tableLen || batch[i].Y < 0 || batch[i].Y >= tableLen {
+			t.Errorf("batch[%d] = %v is out of range", i, batch[i])
+		}
+	}
+
+	if _, _, err = priv.CoverIdx(idx, nil, 1); err != ErrorBatchTooLarge {
+		t.Errorf("priv.CoverIdx() returns %v, expected %q", err, ErrorBatchTooLarge)
+	}
+}
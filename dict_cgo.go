@@ -0,0 +1,658 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package store
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+/*
+// The next line gets things going on Mac:
+#cgo CPPFLAGS: -I/usr/local/opt/openssl/include
+#cgo LDFLAGS: -lstructsec -lcrypto
+#include <structsec/const.h>
+#include <structsec/dict.h>
+#include "string.h"
+
+char **new_str_list(int len) {
+	return calloc(sizeof(char *), len);
+}
+
+int *new_int_list(int len) {
+	return calloc(sizeof(int), len);
+}
+
+void set_str_list(char **list, int idx, char *val) {
+	list[idx] = val;
+}
+
+void set_int_list(int *list, int idx, int val) {
+	list[idx] = val;
+}
+
+int get_int_list(int *list, int idx) {
+	return list[idx];
+}
+
+char *get_str_list(char **list, int idx) {
+	return list[idx];
+}
+
+void free_str_list(char **list, int len) {
+	int i;
+	for (i = 0; i < len; i++) {
+		if (list[i] != NULL) {
+			free(list[i]);
+		}
+	}
+	free(list);
+}
+
+void free_int_list(int *list) {
+	free(list);
+}
+
+char *get_row_ptr(char *table, int row, int row_bytes) {
+	return &table[row * row_bytes];
+}
+
+node_t *get_node(graph_t *graph, int idx) {
+	return &graph->node[idx];
+}
+
+int get_edge(graph_t *graph, int i, int j) {
+	return graph->node[i].adj_edge[j];
+}
+*/
+import "C"
+
+// The constants defined in dict.go must match the C implementation.
+const (
+	_ = uint(MaxRowBytes-C.HASH_BYTES) + uint(C.HASH_BYTES-MaxRowBytes)
+	_ = uint(DictKeyBytes-C.HMAC_KEY_BYTES) + uint(C.HMAC_KEY_BYTES-DictKeyBytes)
+)
+
+// cError propagates an error from the internal C code.
+func cError(fn string, errNo C.int) Error {
+	return Error(fmt.Sprintf("%s returns error %d", fn, errNo))
+}
+
+// The public representation of the map.
+//
+// The methods of PubDict are safe for concurrent use.
+type PubDict struct {
+	// Held for reading while dict is in use and for writing when it is
+	// freed by Close().
+	closeMu sync.RWMutex
+	dict    *C.dict_t
+}
+
+// The private state required for evaluation queries.
+//
+// The methods of PrivDict are safe for concurrent use. The tinyprf context is
+// not thread safe, and neither is the salt, since the tweak is written to it.
+// Hence, each concurrent query is given its own context and salt from a pool.
+type PrivDict struct {
+	// Held for reading while the key, the salt, and the pool are in use and
+	// for writing when they are freed by Close().
+	closeMu sync.RWMutex
+
+	params     C.dict_params_t
+	cK         *C.char
+	cZeroShare *C.char
+
+	mu   sync.Mutex // Protects pool.
+	pool []*tinyCtx
+}
+
+// A tinyprf context and a copy of the parameters with its own salt.
+type tinyCtx struct {
+	tiny   *C.tiny_ctx
+	params C.dict_params_t
+}
+
+// NewPubDictFromProto creates a new *PubDict from a *pb.Dict.
+//
+// You should destroy this with pub.Close().
+func NewPubDictFromProto(table *pb.Dict) *PubDict {
+	pub := new(PubDict)
+	pub.dict = (*C.dict_t)(C.malloc(C.sizeof_dict_t))
+	runtime.SetFinalizer(pub, (*PubDict).Close)
+
+	// Allocate memory for salt + 1 tweak byte and set the parameters.
+	pub.dict.params.salt = (*C.char)(C.malloc(C.size_t(len(table.GetParams().Salt) + 1)))
+	setCParamsFromParams(&pub.dict.params, table.GetParams())
+
+	// Allocate memory for table + 1 zero row and copy the table.
+	tableLen := C.int(table.GetParams().GetTableLen())
+	rowBytes := C.int(table.GetParams().GetRowBytes())
+	realTableLen := C.int(len(table.Table)) / rowBytes
+	cBuf := C.CString(string(table.Table))
+	defer C.free(unsafe.Pointer(cBuf))
+	pub.dict.table = (*C.char)(C.malloc(C.size_t(tableLen * rowBytes)))
+	C.memset(unsafe.Pointer(pub.dict.table), 0, C.size_t(tableLen*rowBytes))
+	for i := 0; i < int(realTableLen); i++ {
+		src := C.get_row_ptr(cBuf, C.int(i), rowBytes)
+		dst := C.get_row_ptr(pub.dict.table, C.int(table.Idx[i]), rowBytes)
+		C.memcpy(unsafe.Pointer(dst), unsafe.Pointer(src), C.size_t(rowBytes))
+	}
+
+	return pub
+}
+
+// GetShare returns the bitwise-XOR of the x-th and y-th rows of the table.
+func (pub *PubDict) GetShare(x, y int) ([]byte, error) {
+	defer runtime.KeepAlive(pub)
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	if pub.dict == nil {
+		return nil, ErrorClosed
+	}
+	if x < 0 || x >= int(pub.dict.params.table_length) ||
+		y < 0 || y >= int(pub.dict.params.table_length) {
+		return nil, ErrorIdx
+	}
+	xRow := getRow(pub.dict.table, C.int(x), pub.dict.params.row_bytes)
+	yRow := getRow(pub.dict.table, C.int(y), pub.dict.params.row_bytes)
+	for i := 0; i < len(xRow); i++ {
+		xRow[i] ^= yRow[i]
+	}
+	return xRow, nil
+}
+
+// getTable returns a copy of the uncompressed table.
+func (pub *PubDict) getTable() []byte {
+	defer runtime.KeepAlive(pub)
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return cBytesToBytes(pub.dict.table,
+		pub.dict.params.table_length*pub.dict.params.row_bytes)
+}
+
+// rowBytes returns the length of each row of the table.
+func (pub *PubDict) rowBytes() int {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return int(pub.dict.params.row_bytes)
+}
+
+// getRow returns a copy of the x-th row of the table.
+func (pub *PubDict) getRow(x int) []byte {
+	defer runtime.KeepAlive(pub)
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return getRow(pub.dict.table, C.int(x), pub.dict.params.row_bytes)
+}
+
+// getParams returns the public parameters of the data structure.
+func (pub *PubDict) getParams() *pb.Params {
+	defer runtime.KeepAlive(pub)
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return cParamsToParams(&pub.dict.params)
+}
+
+// GetProto returns a *pb.Dict representation of the dictionary. It returns nil
+// if pub is closed.
+func (pub *PubDict) GetProto() *pb.Dict {
+	defer runtime.KeepAlive(pub)
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	if pub.dict == nil {
+		return nil
+	}
+	cdict := C.dict_compress(pub.dict)
+	defer C.cdict_free(cdict)
+	rowBytes := int(pub.dict.params.row_bytes)
+	tableLen := int(cdict.compressed_table_length)
+	tableIdx := make([]int32, tableLen)
+	for i := 0; i < tableLen; i++ {
+		tableIdx[i] = int32(C.get_int_list(cdict.idx, C.int(i)))
+	}
+	return &pb.Dict{
+		Params: cParamsToParams(&pub.dict.params),
+		Table:  C.GoBytes(unsafe.Pointer(cdict.table), C.int(tableLen*rowBytes)),
+		Idx:    tableIdx,
+	}
+}
+
+// Close deallocates memory associated with the underlying C implementation of
+// the data structure. It is safe to call Close() more than once and
+// concurrently with the other methods of pub; it waits for them to finish.
+// After pub is closed, its methods return ErrorClosed.
+//
+// If pub is not closed, then the memory is released when pub is garbage
+// collected.
+func (pub *PubDict) Close() error {
+	pub.closeMu.Lock()
+	defer pub.closeMu.Unlock()
+	if pub.dict != nil {
+		C.dict_free(pub.dict)
+		pub.dict = nil
+		runtime.SetFinalizer(pub, nil)
+	}
+	return nil
+}
+
+// Free is equivalent to pub.Close().
+//
+// Deprecated: Use pub.Close() instead.
+func (pub *PubDict) Free() {
+	pub.Close()
+}
+
+// closed returns true if pub was closed.
+func (pub *PubDict) closed() bool {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return pub.dict == nil
+}
+
+// NewPrivDict creates a new *PrivDict from a key and parameters.
+//
+// You should destroy this with priv.Close().
+func NewPrivDict(K []byte, params *pb.Params) (*PrivDict, error) {
+	priv := new(PrivDict)
+
+	// Check that K is the right length.
+	if len(K) != DictKeyBytes {
+		return nil, Error(fmt.Sprintf("len(K) = %d, expected %d", len(K), DictKeyBytes))
+	}
+
+	// Allocate memory for the key, salt, and a 0-byte string used by
+	// GetOutput().
+	priv.cK = (*C.char)(C.CBytes(K))
+	priv.params.salt = (*C.char)(C.malloc(C.size_t(len(params.Salt) + 1)))
+	priv.cZeroShare = (*C.char)(C.malloc(C.size_t(params.GetRowBytes())))
+	C.memset(unsafe.Pointer(priv.cZeroShare), 0, C.size_t(params.GetRowBytes()))
+	runtime.SetFinalizer(priv, (*PrivDict).Close)
+
+	// Set parameters.
+	setCParamsFromParams(&priv.params, params)
+
+	// Create the first tinyprf context. This checks that the parameters and
+	// key are valid.
+	ctx, err := priv.getCtx()
+	if err != nil {
+		priv.Close()
+		return nil, err
+	}
+	priv.putCtx(ctx)
+
+	return priv, nil
+}
+
+// getCtx removes a context from the pool and returns it. If the pool is empty,
+// then a new context is created.
+func (priv *PrivDict) getCtx() (*tinyCtx, error) {
+	priv.mu.Lock()
+	if n := len(priv.pool); n > 0 {
+		ctx := priv.pool[n-1]
+		priv.pool = priv.pool[:n-1]
+		priv.mu.Unlock()
+		return ctx, nil
+	}
+	priv.mu.Unlock()
+
+	ctx := new(tinyCtx)
+	ctx.tiny = C.tinyprf_new(priv.params.table_length)
+	if ctx.tiny == nil {
+		return nil, Error("tableLen < 2")
+	}
+	errNo := C.tinyprf_init(ctx.tiny, priv.cK)
+	if errNo != C.OK {
+		C.tinyprf_free(ctx.tiny)
+		return nil, cError("tinyprf_init", errNo)
+	}
+	ctx.params = priv.params
+	ctx.params.salt = (*C.char)(C.malloc(C.size_t(priv.params.salt_bytes + 1)))
+	C.memcpy(unsafe.Pointer(ctx.params.salt),
+		unsafe.Pointer(priv.params.salt),
+		C.size_t(priv.params.salt_bytes))
+	return ctx, nil
+}
+
+// putCtx returns a context to the pool.
+func (priv *PrivDict) putCtx(ctx *tinyCtx) {
+	priv.mu.Lock()
+	priv.pool = append(priv.pool, ctx)
+	priv.mu.Unlock()
+}
+
+// free deallocates memory associated with the context.
+func (ctx *tinyCtx) free() {
+	C.tinyprf_free(ctx.tiny)
+	C.free(unsafe.Pointer(ctx.params.salt))
+}
+
+// Get queries input on the structure (pub, priv). The result is M[input] =
+// output, where M is the map represented by (pub, priv).
+func (priv *PrivDict) Get(pub *PubDict, input string) (string, error) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return "", err
+	}
+	pubShare, err := pub.GetShare(x, y)
+	if err != nil {
+		return "", err
+	}
+	return priv.GetOutput(input, pubShare)
+}
+
+// GetIdx computes the two indices of the table associated with input and
+// returns them.
+func (priv *PrivDict) GetIdx(input string) (int, int, error) {
+	defer runtime.KeepAlive(priv)
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.cK == nil {
+		return 0, 0, ErrorClosed
+	}
+	ctx, err := priv.getCtx()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer priv.putCtx(ctx)
+
+	// C.CString() copies every byte of the input, including any zeros, and
+	// the length is passed explicitly, so the input may be any byte string.
+	cInput := C.CString(input)
+	defer C.free(unsafe.Pointer(cInput))
+	var x, y C.int
+	errNo := C.dict_compute_rows(
+		ctx.params, ctx.tiny, cInput, C.int(len(input)), &x, &y)
+	if errNo != C.OK {
+		return 0, 0, cError("dict_compute_rows", errNo)
+	}
+	return int(x), int(y), nil
+}
+
+// GetOutput computes the output associated with the input and the table rows.
+func (priv *PrivDict) GetOutput(input string, pubShare []byte) (string, error) {
+	defer runtime.KeepAlive(priv)
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.cK == nil {
+		return "", ErrorClosed
+	}
+	if len(pubShare) < priv.rowBytes() {
+		return "", ItemNotFound
+	}
+	ctx, err := priv.getCtx()
+	if err != nil {
+		return "", err
+	}
+	defer priv.putCtx(ctx)
+
+	cInput := C.CString(input)
+	cOutput := C.CString(string(make([]byte, priv.params.max_value_bytes)))
+	defer C.free(unsafe.Pointer(cInput))
+	defer C.free(unsafe.Pointer(cOutput))
+	cOutputBytes := C.int(0)
+
+	cPubShare := C.CString(string(pubShare))
+	defer C.free(unsafe.Pointer(cPubShare))
+
+	errNo := C.dict_compute_value(ctx.params, ctx.tiny, cInput,
+		C.int(len(input)), cPubShare, priv.cZeroShare, cOutput, &cOutputBytes)
+
+	if errNo == C.ERR_DICT_BAD_KEY {
+		return "", ItemNotFound
+	} else if errNo != C.OK {
+		return "", cError("dict_compute_value", errNo)
+	}
+	return C.GoStringN(cOutput, cOutputBytes), nil
+}
+
+// GetParams returns the public parameters of the data structure. It returns nil
+// if priv is closed.
+func (priv *PrivDict) GetParams() *pb.Params {
+	defer runtime.KeepAlive(priv)
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.cK == nil {
+		return nil
+	}
+	return cParamsToParams(&priv.params)
+}
+
+// salt returns a copy of the salt, or nil if priv is closed.
+func (priv *PrivDict) salt() []byte {
+	defer runtime.KeepAlive(priv)
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.cK == nil {
+		return nil
+	}
+	return cBytesToBytes(priv.params.salt, priv.params.salt_bytes)
+}
+
+// rowBytes returns the length of each row of the table.
+func (priv *PrivDict) rowBytes() int {
+	return int(priv.params.row_bytes)
+}
+
+// Close deallocates memory associated with the C implementation of the
+// underlying data structure and erases the key. It is safe to call Close()
+// more than once and concurrently with the other methods of priv; it waits for
+// them to finish. After priv is closed, its methods return ErrorClosed.
+//
+// If priv is not closed, then the memory is released when priv is garbage
+// collected.
+func (priv *PrivDict) Close() error {
+	priv.closeMu.Lock()
+	defer priv.closeMu.Unlock()
+	if priv.cK != nil {
+		for _, ctx := range priv.pool {
+			ctx.free()
+		}
+		priv.pool = nil
+		C.memset(unsafe.Pointer(priv.cK), 0, C.size_t(DictKeyBytes))
+		C.free(unsafe.Pointer(priv.cK))
+		C.free(unsafe.Pointer(priv.params.salt))
+		C.free(unsafe.Pointer(priv.cZeroShare))
+		priv.cK = nil
+		priv.params.salt = nil
+		priv.cZeroShare = nil
+		runtime.SetFinalizer(priv, nil)
+	}
+	return nil
+}
+
+// Free is equivalent to priv.Close().
+//
+// Deprecated: Use priv.Close() instead.
+func (priv *PrivDict) Free() {
+	priv.Close()
+}
+
+// closed returns true if priv was closed.
+func (priv *PrivDict) closed() bool {
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	return priv.cK == nil
+}
+
+// Storage of map[string]string for processing with the C code.
+type cMap struct {
+	itemCt, maxOutputBytes  C.int
+	inputs, outputs         **C.char
+	inputBytes, outputBytes *C.int
+}
+
+// newCMap constructs a new *cMap from a list of inputs and outputs.
+//
+// This must be freed with cM.free().
+func newCMap(inputs, outputs [][]byte) (cM *cMap) {
+	cM = new(cMap)
+	cM.itemCt = C.int(len(inputs))
+	cM.inputs = C.new_str_list(cM.itemCt)
+	cM.inputBytes = C.new_int_list(cM.itemCt)
+	cM.outputs = C.new_str_list(cM.itemCt)
+	cM.outputBytes = C.new_int_list(cM.itemCt)
+	cM.maxOutputBytes = C.int(0)
+	for i := C.int(0); i < cM.itemCt; i++ {
+		if C.int(len(outputs[i])) > cM.maxOutputBytes {
+			cM.maxOutputBytes = C.int(len(outputs[i]))
+		}
+		C.set_str_list(cM.inputs, i, (*C.char)(C.CBytes(inputs[i])))
+		C.set_int_list(cM.inputBytes, i, C.int(len(inputs[i])))
+		C.set_str_list(cM.outputs, i, (*C.char)(C.CBytes(outputs[i])))
+		C.set_int_list(cM.outputBytes, i, C.int(len(outputs[i])))
+	}
+	return cM
+}
+
+// free() frees memory allocated to cM.
+func (cM *cMap) free() {
+	C.free_str_list(cM.inputs, cM.itemCt)
+	C.free_int_list(cM.inputBytes)
+	C.free_str_list(cM.outputs, cM.itemCt)
+	C.free_int_list(cM.outputBytes)
+}
+
+// cBytesToString maps a *C.char to a []byte.
+func cBytesToString(str *C.char, bytes C.int) string {
+	return C.GoStringN(str, bytes)
+}
+
+// cBytesToString maps a *C.char to a []byte.
+func cBytesToBytes(str *C.char, bytes C.int) []byte {
+	return C.GoBytes(unsafe.Pointer(str), bytes)
+}
+
+// cParamsToParams creates *Params from a *C.dict_params_t, making a
+// deep copy of the salt.
+//
+// Called by pub.GetParams() and priv.GetParams().
+func cParamsToParams(cParams *C.dict_params_t) *pb.Params {
+	var pad bool
+	if cParams.f_pad == C.int(1) {
+		pad = true
+	} else {
+		pad = false
+	}
+	return &pb.Params{
+		TableLen:       *proto.Int32(int32(cParams.table_length)),
+		MaxOutputBytes: *proto.Int32(int32(cParams.max_value_bytes)),
+		RowBytes:       *proto.Int32(int32(cParams.row_bytes)),
+		TagBytes:       *proto.Int32(int32(cParams.tag_bytes)),
+		Salt:           C.GoBytes(unsafe.Pointer(cParams.salt), cParams.salt_bytes),
+		Pad:            pad,
+	}
+}
+
+// setCParamsFromDictparams copies parameters to a *C.dict_params_t.
+//
+// Must call C.free(cParams.salt)
+func setCParamsFromParams(cParams *C.dict_params_t, params *pb.Params) {
+	cParams.table_length = C.int(params.GetTableLen())
+	cParams.max_value_bytes = C.int(params.GetMaxOutputBytes())
+	cParams.row_bytes = C.int(params.GetRowBytes())
+	cParams.tag_bytes = C.int(params.GetTagBytes())
+	cParams.salt_bytes = C.int(len(params.Salt))
+	cBuf := C.CBytes(params.Salt)
+	defer C.free(cBuf)
+	C.memcpy(unsafe.Pointer(cParams.salt), cBuf, C.size_t(cParams.salt_bytes))
+	if params.GetPad() {
+		cParams.f_pad = C.int(1)
+	} else {
+		cParams.f_pad = C.int(0)
+	}
+}
+
+// getRow returns a []byte corresponding to row in the table.
+func getRow(table *C.char, idx, rowBytes C.int) []byte {
+	rowPtr := C.get_row_ptr(table, idx, rowBytes)
+	return C.GoBytes(unsafe.Pointer(rowPtr), rowBytes)
+}
+
+// newDictAndGraph constructs a new dictionary mapping each input to the
+// corresponding output and returns the generated graph.
+func newDictAndGraph(K []byte, inputs, outputs [][]byte, tagBytes int, pad bool) (*PubDict, *PrivDict, graph, error) {
+	pub := new(PubDict)
+
+	cM := newCMap(inputs, outputs)
+	defer cM.free()
+
+	// Allocate a new dictionary object.
+	tableLen := C.dict_compute_table_length(cM.itemCt)
+	var cPad C.int
+	if pad {
+		cPad = C.int(1)
+	} else {
+		cPad = C.int(0)
+	}
+	pub.dict = C.dict_new(
+		tableLen,
+		cM.maxOutputBytes,
+		C.int(tagBytes),
+		C.int(SaltBytes),
+		cPad)
+	if pub.dict == nil {
+		return nil, nil, nil, Error(fmt.Sprintf("maxOutputBytes > %d", MaxOutputBytes))
+	}
+	runtime.SetFinalizer(pub, (*PubDict).Close)
+
+	params := cParamsToParams(&pub.dict.params)
+
+	// Create priv.
+	//
+	// NOTE(cjpatton) dict.salt is not set, and so priv.params.salt is not set.
+	// It's necessary to set it after calling C.dict_create().
+	priv, err := NewPrivDict(K, params)
+	if err != nil {
+		pub.Close()
+		return nil, nil, nil, err
+	}
+
+	// Create the dictionary. priv.pool contains just the context created by
+	// NewPrivDict().
+	ctx, err := priv.getCtx()
+	if err != nil {
+		pub.Close()
+		priv.Close()
+		return nil, nil, nil, err
+	}
+	var errNo C.int
+	cGraph := C.dict_create_and_output_graph(
+		pub.dict, ctx.tiny, cM.inputs, cM.inputBytes, cM.outputs, cM.outputBytes, cM.itemCt, &errNo)
+	if errNo != C.OK {
+		priv.putCtx(ctx)
+		pub.Close()
+		priv.Close()
+		return nil, nil, nil, cError("dict_create_and_output_graph", errNo)
+	}
+	defer C.graph_free(cGraph)
+
+	// Copy salt to priv.params and the context.
+	C.memcpy(unsafe.Pointer(priv.params.salt),
+		unsafe.Pointer(pub.dict.params.salt),
+		C.size_t(priv.params.salt_bytes))
+	C.memcpy(unsafe.Pointer(ctx.params.salt),
+		unsafe.Pointer(pub.dict.params.salt),
+		C.size_t(priv.params.salt_bytes))
+	priv.putCtx(ctx)
+
+	// Save adjcency list.
+	graph := make([][]int32, int32(cGraph.node_ct))
+	for i := C.int(0); i < cGraph.node_ct; i++ {
+		cAdjCt := C.get_node(cGraph, i).adj_ct
+		graph[i] = make([]int32, int32(cAdjCt))
+		for j := C.int(0); j < cAdjCt; j++ {
+			graph[i][j] = int32(C.get_edge(cGraph, i, j))
+		}
+	}
+
+	return pub, priv, graph, nil
+}
@@ -0,0 +1,584 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+//go:build purego || !cgo
+// +build purego !cgo
+
+// This file is a native Go implementation of the dictionary. It is compatible
+// with the C implementation in c/dict.c: both read and write the same pb.Dict.
+// Build with "-tags purego" (or with CGO_ENABLED=0) to drop the dependency on
+// libstructsec.
+
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// These match the constants defined in c/const.h and c/dict.h.
+const (
+	hashBytes    = sha512.Size
+	hashBits     = 8 * hashBytes
+	maxOutDegree = 32
+	nodeCtFactor = 2.09
+	padByte      = 0x70
+
+	errBad            = -2
+	errGraphDegree    = -7
+	errGraphCycle     = -8
+	errDictBadKey     = -11
+	errDictBadPadding = -12
+	errDictTooMany    = -13
+)
+
+// dictError propagates an error from the dictionary. The error numbers and
+// messages are the same as the C implementation.
+func dictError(fn string, errNo int) Error {
+	return Error(fmt.Sprintf("%s returns error %d", fn, errNo))
+}
+
+// The public representation of the map.
+//
+// The methods of PubDict are safe for concurrent use.
+type PubDict struct {
+	params *pb.Params
+
+	// Held for reading while table is in use and for writing when it is
+	// released by Close().
+	closeMu sync.RWMutex
+	table   []byte
+}
+
+// The private state required for evaluation queries.
+//
+// The methods of PrivDict are safe for concurrent use. The HMAC state is not
+// thread safe, and so each concurrent query gets its own from a pool.
+type PrivDict struct {
+	params *pb.Params
+
+	// Held for reading while the key is in use and for writing when it is
+	// erased by Close().
+	closeMu   sync.RWMutex
+	key       []byte
+	prf       sync.Pool // Of hash.Hash
+	chunkBits int
+	chunks    int
+}
+
+// NewPubDictFromProto creates a new *PubDict from a *pb.Dict.
+//
+// You should destroy this with pub.Close().
+func NewPubDictFromProto(table *pb.Dict) *PubDict {
+	pub := new(PubDict)
+	pub.params = copyParams(table.GetParams())
+
+	// Expand the compressed table.
+	rowBytes := int(pub.params.GetRowBytes())
+	pub.table = make([]byte, int(pub.params.GetTableLen())*rowBytes)
+	for i := 0; i < len(table.Table)/rowBytes; i++ {
+		x := int(table.Idx[i])
+		copy(pub.table[x*rowBytes:(x+1)*rowBytes], table.Table[i*rowBytes:(i+1)*rowBytes])
+	}
+
+	return pub
+}
+
+// GetShare returns the bitwise-XOR of the x-th and y-th rows of the table.
+func (pub *PubDict) GetShare(x, y int) ([]byte, error) {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	if pub.table == nil {
+		return nil, ErrorClosed
+	}
+	if x < 0 || x >= int(pub.params.GetTableLen()) ||
+		y < 0 || y >= int(pub.params.GetTableLen()) {
+		return nil, ErrorIdx
+	}
+	return xorRows(pub.table, x, y, pub.rowBytes()), nil
+}
+
+// getTable returns a copy of the uncompressed table.
+func (pub *PubDict) getTable() []byte {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return append([]byte(nil), pub.table...)
+}
+
+// rowBytes returns the length of each row of the table.
+func (pub *PubDict) rowBytes() int {
+	return int(pub.params.GetRowBytes())
+}
+
+// getRow returns a copy of the x-th row of the table.
+func (pub *PubDict) getRow(x int) []byte {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	rowBytes := pub.rowBytes()
+	return append([]byte(nil), pub.table[x*rowBytes:(x+1)*rowBytes]...)
+}
+
+// getParams returns the public parameters of the data structure.
+func (pub *PubDict) getParams() *pb.Params {
+	return copyParams(pub.params)
+}
+
+// GetProto returns a *pb.Dict representation of the dictionary. It returns nil
+// if pub is closed.
+//
+// Rows that are all zeros are omitted from the table.
+func (pub *PubDict) GetProto() *pb.Dict {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	if pub.table == nil {
+		return nil
+	}
+	rowBytes := pub.rowBytes()
+	table := make([]byte, 0, len(pub.table))
+	tableIdx := make([]int32, 0, pub.params.GetTableLen())
+	for x := 0; x < int(pub.params.GetTableLen()); x++ {
+		row := pub.table[x*rowBytes : (x+1)*rowBytes]
+		if !isZero(row) {
+			table = append(table, row...)
+			tableIdx = append(tableIdx, int32(x))
+		}
+	}
+	return &pb.Dict{
+		Params: copyParams(pub.params),
+		Table:  table,
+		Idx:    tableIdx,
+	}
+}
+
+// Close releases the table. It is safe to call Close() more than once and
+// concurrently with the other methods of pub; it waits for them to finish.
+// After pub is closed, its methods return ErrorClosed.
+func (pub *PubDict) Close() error {
+	pub.closeMu.Lock()
+	defer pub.closeMu.Unlock()
+	pub.table = nil
+	return nil
+}
+
+// Free is equivalent to pub.Close().
+//
+// Deprecated: Use pub.Close() instead.
+func (pub *PubDict) Free() {
+	pub.Close()
+}
+
+// closed returns true if pub was closed.
+func (pub *PubDict) closed() bool {
+	pub.closeMu.RLock()
+	defer pub.closeMu.RUnlock()
+	return pub.table == nil
+}
+
+// NewPrivDict creates a new *PrivDict from a key and parameters.
+//
+// You should destroy this with priv.Close().
+func NewPrivDict(K []byte, params *pb.Params) (*PrivDict, error) {
+	priv := new(PrivDict)
+
+	// Check that K is the right length.
+	if len(K) != DictKeyBytes {
+		return nil, Error(fmt.Sprintf("len(K) = %d, expected %d", len(K), DictKeyBytes))
+	}
+
+	// Compute the tinyprf parameters.
+	radix := int(params.GetTableLen())
+	priv.chunkBits = ceilLog2(radix)
+	if radix < 1 || priv.chunkBits > hashBits || priv.chunkBits < 1 {
+		return nil, Error("tableLen < 2")
+	}
+	priv.chunks = hashBits / priv.chunkBits
+
+	priv.key = append([]byte(nil), K...)
+	priv.prf.New = func() interface{} {
+		return hmac.New(sha512.New, priv.key)
+	}
+	priv.params = copyParams(params)
+	return priv, nil
+}
+
+// Get queries input on the structure (pub, priv). The result is M[input] =
+// output, where M is the map represented by (pub, priv).
+func (priv *PrivDict) Get(pub *PubDict, input string) (string, error) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return "", err
+	}
+	pubShare, err := pub.GetShare(x, y)
+	if err != nil {
+		return "", err
+	}
+	return priv.GetOutput(input, pubShare)
+}
+
+// GetIdx computes the two indices of the table associated with input and
+// returns them.
+func (priv *PrivDict) GetIdx(input string) (int, int, error) {
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.key == nil {
+		return 0, 0, ErrorClosed
+	}
+	x, y := priv.computeRows([]byte(input))
+	if x < 0 {
+		return 0, 0, dictError("dict_compute_rows", x)
+	} else if y < 0 {
+		return 0, 0, dictError("dict_compute_rows", y)
+	}
+	return x, y, nil
+}
+
+// GetOutput computes the output associated with the input and the table rows.
+func (priv *PrivDict) GetOutput(input string, pubShare []byte) (string, error) {
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.key == nil {
+		return "", ErrorClosed
+	}
+	if len(pubShare) < priv.rowBytes() {
+		return "", ItemNotFound
+	}
+	output, errNo := priv.computeValue(input, pubShare)
+	if errNo == errDictBadKey {
+		return "", ItemNotFound
+	} else if errNo != 0 {
+		return "", dictError("dict_compute_value", errNo)
+	}
+	return string(output), nil
+}
+
+// GetParams returns the public parameters of the data structure. It returns nil
+// if priv is closed.
+func (priv *PrivDict) GetParams() *pb.Params {
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	if priv.key == nil {
+		return nil
+	}
+	return copyParams(priv.params)
+}
+
+// salt returns a copy of the salt.
+func (priv *PrivDict) salt() []byte {
+	return append([]byte(nil), priv.params.GetSalt()...)
+}
+
+// rowBytes returns the length of each row of the table.
+func (priv *PrivDict) rowBytes() int {
+	return int(priv.params.GetRowBytes())
+}
+
+// Close discards the key. It is safe to call Close() more than once and
+// concurrently with the other methods of priv; it waits for them to finish.
+// After priv is closed, its methods return ErrorClosed.
+func (priv *PrivDict) Close() error {
+	priv.closeMu.Lock()
+	defer priv.closeMu.Unlock()
+	for i := range priv.key {
+		priv.key[i] = 0
+	}
+	priv.key = nil
+	priv.prf.New = nil
+	return nil
+}
+
+// Free is equivalent to priv.Close().
+//
+// Deprecated: Use priv.Close() instead.
+func (priv *PrivDict) Free() {
+	priv.Close()
+}
+
+// closed returns true if priv was closed.
+func (priv *PrivDict) closed() bool {
+	priv.closeMu.RLock()
+	defer priv.closeMu.RUnlock()
+	return priv.key == nil
+}
+
+// prfEval computes HMAC-SHA512 of salt || tweak || input. This corresponds to
+// prf() in c/tiny.c.
+func (priv *PrivDict) prfEval(input []byte, tweak byte) []byte {
+	prf := priv.prf.Get().(hash.Hash)
+	defer priv.prf.Put(prf)
+	prf.Reset()
+	prf.Write(priv.params.GetSalt())
+	prf.Write([]byte{tweak})
+	prf.Write(input)
+	return prf.Sum(nil)
+}
+
+// tinyPrf maps input to an integer in [0, tableLen). This corresponds to
+// tinyprf() in c/tiny.c.
+//
+// The digest is split into chunks of chunkBits bits. The output is the last
+// chunk that is less than the table length, or errBad if there is none.
+func (priv *PrivDict) tinyPrf(input []byte, tweak byte) int {
+	digest := priv.prfEval(input, tweak)
+	radix := int(priv.params.GetTableLen())
+	z := errBad
+	for i := 0; i < priv.chunks; i++ {
+		if y := getChunk(digest, priv.chunkBits, i); y < radix {
+			z = y
+		}
+	}
+	return z
+}
+
+// computeRows maps the input to a pair of rows. This corresponds to
+// dict_compute_rows() in c/dict.c.
+func (priv *PrivDict) computeRows(input []byte) (int, int) {
+	return priv.tinyPrf(input, 1), priv.tinyPrf(input, 2)
+}
+
+// computeValue computes the output from the input and the bitwise-XOR of its
+// rows. This corresponds to dict_compute_value() in c/dict.c.
+func (priv *PrivDict) computeValue(input string, share []byte) ([]byte, int) {
+	rowBytes := priv.rowBytes()
+	maxOutputBytes := int(priv.params.GetMaxOutputBytes())
+	buf := priv.prfEval([]byte(input), 3)[:rowBytes]
+	for j := 0; j < rowBytes; j++ {
+		buf[j] ^= share[j]
+	}
+
+	// Check the tag.
+	start := maxOutputBytes
+	if priv.params.GetPad() {
+		start++
+	}
+	if !isZero(buf[start:]) {
+		return nil, errDictBadKey
+	}
+
+	// Remove the padding.
+	lastByte := maxOutputBytes
+	if priv.params.GetPad() {
+		for lastByte > 0 && buf[lastByte] == 0 {
+			lastByte--
+		}
+		if buf[lastByte] != padByte {
+			return nil, errDictBadPadding
+		}
+	}
+	return buf[:lastByte], 0
+}
+
+// newDictAndGraph constructs a new dictionary mapping each input to the
+// corresponding output and returns the generated graph. This corresponds to
+// dict_create_and_output_graph() in c/dict.c.
+func newDictAndGraph(K []byte, inputs, outputs [][]byte, tagBytes int, pad bool) (*PubDict, *PrivDict, graph, error) {
+	itemCt := len(inputs)
+	maxOutputBytes := 0
+	for i := 0; i < itemCt; i++ {
+		if len(outputs[i]) > maxOutputBytes {
+			maxOutputBytes = len(outputs[i])
+		}
+	}
+
+	// Compute the parameters.
+	tableLen := int((float64(itemCt) * nodeCtFactor) * 10)
+	if tableLen%10 == 0 {
+		tableLen /= 10
+	} else {
+		tableLen = tableLen/10 + 1
+	}
+	rowBytes := tagBytes + maxOutputBytes
+	if pad {
+		rowBytes++
+	}
+	if rowBytes > MaxRowBytes {
+		return nil, nil, nil, Error(fmt.Sprintf("maxOutputBytes > %d", MaxOutputBytes))
+	}
+	params := &pb.Params{
+		TableLen:       int32(tableLen),
+		MaxOutputBytes: int32(maxOutputBytes),
+		RowBytes:       int32(rowBytes),
+		TagBytes:       int32(tagBytes),
+		Salt:           make([]byte, SaltBytes),
+		Pad:            pad,
+	}
+
+	priv, err := NewPrivDict(K, params)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if itemCt >= tableLen {
+		priv.Close()
+		return nil, nil, nil, dictError("dict_create_and_output_graph", errDictTooMany)
+	}
+
+	// Generate a random, simple, and acyclic graph. Each edge corresponds to
+	// an input and its end points are the rows of the input.
+	var g graph
+	var ends [][2]int
+	for {
+		if _, err := rand.Read(priv.params.Salt); err != nil {
+			priv.Close()
+			return nil, nil, nil, err
+		}
+		var errNo int
+		g, ends, errNo = priv.generateGraph(inputs)
+		if errNo == 0 {
+			break
+		} else if errNo != errGraphCycle && errNo != errGraphDegree {
+			priv.Close()
+			return nil, nil, nil, dictError("dict_create_and_output_graph", errNo)
+		}
+	}
+
+	// Compute the table. The rows of each tree are assigned so that the
+	// bitwise-XOR of the rows of each edge is the pad for the input plus the
+	// output.
+	pub := &PubDict{
+		params: copyParams(priv.params),
+		table:  make([]byte, tableLen*rowBytes),
+	}
+	visited := make([]bool, tableLen)
+	for root := 0; root < tableLen; root++ {
+		if visited[root] {
+			continue
+		}
+
+		// Set the row of each non-root node y to pad || output, where the
+		// output corresponds to the edge from y to its parent. The row of the
+		// root is the bitwise-XOR of these.
+		visited[root] = true
+		parent := map[int]int{}
+		nodes := []int{root}
+		for i := 0; i < len(nodes); i++ {
+			x := nodes[i]
+			for _, e := range g[x] {
+				y := ends[e][0]
+				if y == x {
+					y = ends[e][1]
+				}
+				if visited[y] {
+					continue
+				}
+				visited[y] = true
+				parent[y] = x
+				nodes = append(nodes, y)
+
+				row := priv.prfEval(inputs[e], 3)[:rowBytes]
+				for j := 0; j < len(outputs[e]); j++ {
+					row[j] ^= outputs[e][j]
+				}
+				if pad {
+					row[len(outputs[e])] ^= padByte
+				}
+				copy(pub.table[y*rowBytes:(y+1)*rowBytes], row)
+				xorRow(pub.table, root, row, rowBytes)
+			}
+		}
+
+		// Add the row of each node's parent to its row. Nodes are visited
+		// in breadth-first order, so the parent's row is final.
+		for _, y := range nodes[1:] {
+			xorRow(pub.table, y, pub.table[parent[y]*rowBytes:(parent[y]+1)*rowBytes], rowBytes)
+		}
+	}
+
+	return pub, priv, g, nil
+}
+
+// generateGraph computes the graph for the inputs and the end points of each
+// edge. It returns errGraphCycle if the graph is not simple and acyclic and
+// errGraphDegree if some node has too many edges.
+func (priv *PrivDict) generateGraph(inputs [][]byte) (graph, [][2]int, int) {
+	tableLen := int(priv.params.GetTableLen())
+	g := make(graph, tableLen)
+	ends := make([][2]int, len(inputs))
+
+	// Each tree is tracked by a representative node. An edge between two nodes
+	// of the same tree creates a cycle (or a multi-edge or self-loop).
+	rep := make([]int, tableLen)
+	for i := range rep {
+		rep[i] = i
+	}
+	find := func(x int) int {
+		for rep[x] != x {
+			rep[x] = rep[rep[x]]
+			x = rep[x]
+		}
+		return x
+	}
+
+	for e, input := range inputs {
+		x, y := priv.computeRows(input)
+		if x < 0 {
+			return nil, nil, x
+		} else if y < 0 {
+			return nil, nil, y
+		}
+		if len(g[x]) == maxOutDegree || len(g[y]) == maxOutDegree {
+			return nil, nil, errGraphDegree
+		}
+		rx, ry := find(x), find(y)
+		if rx == ry {
+			return nil, nil, errGraphCycle
+		}
+		rep[rx] = ry
+		g[x] = append(g[x], int32(e))
+		g[y] = append(g[y], int32(e))
+		ends[e] = [2]int{x, y}
+	}
+	return g, ends, 0
+}
+
+// copyParams returns a deep copy of params.
+func copyParams(params *pb.Params) *pb.Params {
+	return &pb.Params{
+		TableLen:       params.GetTableLen(),
+		MaxOutputBytes: params.GetMaxOutputBytes(),
+		RowBytes:       params.GetRowBytes(),
+		TagBytes:       params.GetTagBytes(),
+		Salt:           append([]byte(nil), params.GetSalt()...),
+		Pad:            params.GetPad(),
+	}
+}
+
+// ceilLog2 returns the ceiling of log2(radix). This corresponds to ceillog2()
+// in c/bits.c.
+func ceilLog2(radix int) int {
+	y, n := radix, 0
+	for (y >> 1) > 0 {
+		y >>= 1
+		n++
+	}
+	if (1 << uint(n)) != radix {
+		n++
+	}
+	return n
+}
+
+// getChunk returns the i-th chunk of k bits of the string. Bits are read from
+// least to most significant within each byte. This corresponds to get_chunk()
+// in c/bits.c.
+func getChunk(bytes []byte, k, i int) int {
+	chunk := 0
+	for j := 0; j < k; j++ {
+		idx := i*k + j
+		chunk = (chunk << 1) | int((bytes[idx>>3]>>uint(idx&7))&1)
+	}
+	return chunk
+}
+
+// isZero returns true if every byte of the string is 0.
+func isZero(bytes []byte) bool {
+	for _, b := range bytes {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -3,8 +3,14 @@
 package store
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"sync"
 	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
 )
 
 var goodK = []byte("1234123412341234")
@@ -80,14 +86,12 @@ func TestNewDict(t *testing.T) {
 	defer priv.Free()
 
 	// Check that the parameters are the same.
-	AssertInt32EqError(t, "priv.params.table_length", int32(priv.params.table_length), int32(pub.dict.params.table_length))
-	AssertInt32EqError(t, "priv.params.max_value_bytes", int32(priv.params.max_value_bytes), int32(pub.dict.params.max_value_bytes))
-	AssertInt32EqError(t, "priv.params.tag_bytes", int32(priv.params.tag_bytes), int32(pub.dict.params.tag_bytes))
-	AssertInt32EqError(t, "priv.params.row_bytes", int32(priv.params.row_bytes), int32(pub.dict.params.row_bytes))
-	AssertInt32EqError(t, "priv.params.salt_bytes", int32(priv.params.salt_bytes), int32(pub.dict.params.salt_bytes))
-	AssertStringEqError(t, "priv.params.salt",
-		cBytesToString(priv.params.salt, priv.params.salt_bytes),
-		cBytesToString(pub.dict.params.salt, pub.dict.params.salt_bytes))
+	privParams, pubParams := priv.GetParams(), pub.GetProto().GetParams()
+	AssertInt32EqError(t, "priv.GetParams().TableLen", privParams.GetTableLen(), pubParams.GetTableLen())
+	AssertInt32EqError(t, "priv.GetParams().MaxOutputBytes", privParams.GetMaxOutputBytes(), pubParams.GetMaxOutputBytes())
+	AssertInt32EqError(t, "priv.GetParams().TagBytes", privParams.GetTagBytes(), pubParams.GetTagBytes())
+	AssertInt32EqError(t, "priv.GetParams().RowBytes", privParams.GetRowBytes(), pubParams.GetRowBytes())
+	AssertStringEqError(t, "priv.GetParams().Salt", string(privParams.GetSalt()), string(pubParams.GetSalt()))
 
 	pub1, priv1, err := NewDict(goodK, oneM)
 	if err != nil {
@@ -204,3 +208,168 @@ func TestNewPubDictFromProto(t *testing.T) {
 		AssertStringEqError(t, fmt.Sprintf("priv.Get(pub2, %q)", in), out2, val)
 	}
 }
+
+// Test that the dictionary can be read by both implementations. The file
+// testdata/dict.pub was generated by the C implementation from goodK and goodM.
+func TestDictFromFile(t *testing.T) {
+	dictString, err := ioutil.ReadFile("testdata/dict.pub")
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile() fails: %s", err)
+	}
+	dict := new(pb.Dict)
+	if err = proto.Unmarshal(dictString, dict); err != nil {
+		t.Fatalf("proto.Unmarshal() fails: %s", err)
+	}
+
+	pub := NewPubDictFromProto(dict)
+	defer pub.Close()
+	priv, err := NewPrivDict(goodK, dict.GetParams())
+	if err != nil {
+		t.Fatalf("NewPrivDict() fails: %s", err)
+	}
+	defer priv.Close()
+
+	for in, val := range goodM {
+		out, err := priv.Get(pub, in)
+		if err != nil {
+			t.Fatalf("priv.Get(pub, %q) fails: %s", in, err)
+		}
+		AssertStringEqError(t, fmt.Sprintf("priv.Get(pub, %q)", in), out, val)
+	}
+
+	// Check that the table is written back in the same format.
+	if !bytes.Equal(pub.GetProto().GetTable(), dict.GetTable()) {
+		t.Error("pub.GetProto().GetTable() does not match testdata/dict.pub")
+	}
+}
+
+// Test that pub.Close() and priv.Close() may be called more than once, and that
+// closed structures return ErrorClosed.
+func TestDictClose(t *testing.T) {
+	pub, priv, err := NewDict(goodK, goodM)
+	if err != nil {
+		t.Fatalf("NewDict(goodK, goodM) fails: %s", err)
+	}
+	x, y, err := priv.GetIdx("hip")
+	if err != nil {
+		t.Fatalf("priv.GetIdx(\"hip\") fails: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = pub.Close(); err != nil {
+			t.Errorf("pub.Close() fails: %s", err)
+		}
+	}
+	if _, err = pub.GetShare(x, y); err != ErrorClosed {
+		t.Errorf("pub.GetShare() returns %v, expected %q", err, ErrorClosed)
+	}
+	if _, err = priv.Get(pub, "hip"); err != ErrorClosed {
+		t.Errorf("priv.Get(pub, \"hip\") returns %v, expected %q", err, ErrorClosed)
+	}
+	if pub.GetProto() != nil {
+		t.Error("pub.GetProto() != nil, expected nil")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = priv.Close(); err != nil {
+			t.Errorf("priv.Close() fails: %s", err)
+		}
+	}
+	if _, _, err = priv.GetIdx("hip"); err != ErrorClosed {
+		t.Errorf("priv.GetIdx(\"hip\") returns %v, expected %q", err, ErrorClosed)
+	}
+	if _, err = priv.GetOutput("hip", make([]byte, MaxRowBytes)); err != ErrorClosed {
+		t.Errorf("priv.GetOutput() returns %v, expected %q", err, ErrorClosed)
+	}
+	if priv.GetParams() != nil {
+		t.Error("priv.GetParams() != nil, expected nil")
+	}
+}
+
+// Test that queries may be evaluated concurrently. Run with "go test -race".
+func TestDictConcurrent(t *testing.T) {
+	pub, priv, err := NewDict(goodK, goodM)
+	if err != nil {
+		t.Fatalf("NewDict(goodK, goodM) fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for in, val := range goodM {
+					out, err := priv.Get(pub, in)
+					if err != nil {
+						t.Errorf("priv.Get(pub, %q) fails: %s", in, err)
+					} else if out != val {
+						t.Errorf("priv.Get(pub, %q) = %q, expected %q", in, out, val)
+					}
+
+					x, y, err := priv.GetIdx(in)
+					if err != nil {
+						t.Errorf("priv.GetIdx(%q) fails: %s", in, err)
+						continue
+					}
+					pubShare, err := pub.GetShare(x, y)
+					if err != nil {
+						t.Errorf("pub.GetShare(%d, %d) fails: %s", x, y, err)
+						continue
+					}
+					out, err = priv.GetOutput(in, pubShare)
+					if err != nil {
+						t.Errorf("priv.GetOutput(%q) fails: %s", in, err)
+					} else if out != val {
+						t.Errorf("priv.GetOutput(%q) = %q, expected %q", in, out, val)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Test that pub.Close() and priv.Close() may be called while queries are
+// being evaluated. Each query either succeeds or returns ErrorClosed. Run with
+// "go test -race".
+func TestDictCloseConcurrent(t *testing.T) {
+	pub, priv, err := NewDict(goodK, goodM)
+	if err != nil {
+		t.Fatalf("NewDict(goodK, goodM) fails: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for in, val := range goodM {
+					out, err := priv.Get(pub, in)
+					if err != nil && err != ErrorClosed {
+						t.Errorf("priv.Get(pub, %q) fails: %s", in, err)
+					} else if err == nil && out != val {
+						t.Errorf("priv.Get(pub, %q) = %q, expected %q", in, out, val)
+					}
+				}
+			}
+		}()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pub.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		priv.Close()
+	}()
+	wg.Wait()
+
+	if _, err = priv.Get(pub, "hip"); err != ErrorClosed {
+		t.Errorf("priv.Get(pub, \"hip\") returns %v, expected %q", err, ErrorClosed)
+	}
+}
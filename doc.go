@@ -33,10 +33,161 @@ data structure is designed so that no information about input or output (except
 for the length of the output) is leaked to any party not in possession of the
 client's secret key.
 
+Many inputs may be looked up at once with priv.GetIdxMany(), pub.GetShares(),
+and priv.GetOutputMany(), or simply priv.GetMany().
+
+The inputs and outputs are arbitrary byte strings. NewStoreBytes() and
+NewStoreIter() take the map as slices of []byte or as an iterator, and
+priv.GetBytes() and priv.GetOutputBytes() return the output as a []byte.
+
+TypedStore maps inputs of type K to outputs of type V, encoding them with a
+Codec for each. Use errors.Is(err, ErrorDecode) to tell an output that can't be
+decoded from one that is not found.
+
+StoreBuilder creates a store whose map is too large to hold in memory. Pairs are
+added one at a time, the sealed outputs are spilled to a temporary file, and
+b.Finish() writes the public store to an io.Writer.
+
+The server learns which rows are requested, and so may learn how often each
+input is looked up. To hide this, priv.CoverIdx() pads a batch of indices to a
+fixed size with dummy queries for decoy inputs and shuffles it. (Package client
+can also send these batches at a fixed rate.) This reduces, but does not
+eliminate, what the server learns about the access pattern.
+
+Even so, the server learns which rows of the table (and which sealed output)
+are read. If the store is held by two servers that don't collude, then the
+client can hide the index entirely with two-server PIR ("private information
+retrieval"). For this purpose, the record of row x consists of the row and the
+sealed outputs of the inputs whose index includes x. The client sends each
+server a random subset of the rows, which differ only in x, and each server
+responds with the XOR of the records in its subset. The XOR of the responses is
+the record of x:
+
+		query0, query1, err := priv.GetPirQuery(input)
+		pubShare0, err := pub.GetPirShare(query0) // Run by the first server
+		pubShare1, err := pub.GetPirShare(query1) // Run by the second server
+		output, err := priv.GetPirOutput(input, pubShare0, pubShare1)
+
+(The query also fetches the record of y in the same way.) The records must be of
+the same length, so the outputs must be padded with WithPadding(). Each server
+reads its entire copy of the store to answer a query.
+
+If there is only one server, then SimplePirServer and SimplePirClient implement
+single-server PIR for the same records, based on the hardness of LWE. The server
+preprocesses the store once, and the client downloads the resulting hint.
+
+The length of the output can be hidden as well by padding each output before it
+is sealed. For example,
+
+		pub, priv, err := store.NewStore(K, M, store.WithPadding(64))
+
+pads every output to 64 bytes, and WithBucketPadding() pads each output to the
+next power of two. The padding parameters are part of the public parameters, so
+NewPrivStore() picks them up automatically.
+
+PubStore and PrivStore are safe for concurrent use, so a server may answer many
+queries at once (and apply updates while doing so). The only exception is
+priv.Close(), which must not be called while priv is in use.
+
+The client may also change the map without rebuilding the store. For example,
+
+		update, err := priv.Update(pub, input, output)
+
+computes an update that the server applies with pub.ApplyUpdate(update), and
+priv.ApplyUpdate(update) returns the private context of the new store. See
+priv.Insert() and priv.Delete() for inserting and deleting inputs. An update
+carries only the sealed output it writes and the rows of the trees of the graph
+that contain the input, each of which is re-randomized, so the server doesn't
+learn which rows encode the input. If the store has an index of its inputs (see
+WithIndex()), then each update also seals other outputs again, so the server
+doesn't learn which output changed, either.
+
+The output is authenticated, but a server could answer from an old version of
+the store. To detect this, the client keeps the commitment of the store, a hash
+of its parameters, table, and sealed outputs:
+
+		priv.SetCommitment(pub.Commitment())
+
+and the server computes each share with pub.GetVerifiableShare(), which includes
+a Merkle proof for the rows and the sealed output. If the share doesn't match the
+commitment, then priv.GetOutput() returns ErrorBadProof. The proof also covers
+the edges incident to each row, so ItemNotFound means that the input is
+provably not in the map, and not that the server withheld it. The commitment
+changes every time the store is updated, so the client sets the new one after
+each update.
+
+The keys used by the store are derived from K with HKDF-SHA256 according to a
+versioned key schedule, which is recorded in the parameters. By default, the
+outputs are sealed with AES-128-GCM; WithKeySchedule() selects AES-256-GCM.
+
+The client may also require a minimum version of the store, so that a server
+can't replay an old one. The version is set with WithVersion() when the store
+is created, and it is authenticated, along with the rest of the parameters,
+under a key derived from K:
+
+		priv, err := store.NewPrivStore(K, params, minVersion)
+
+returns ErrorStaleStore if the store is older than minVersion.
+
+To rotate K without the map, the store must be created with WithIndex(), which
+adds a sealed, paginated index of the inputs. Then
+
+		newPub, newPriv, err := priv.Rekey(pub, newK)
+
+reads the inputs from the index, looks up their outputs, and builds a new store
+under newK whose version is one more than the old one's. The old store remains
+valid until the server replaces it; WithProgress() reports progress for large
+stores. The index is also read by priv.Keys() and priv.List(), which list the
+inputs of the store; the StoreProvider serves its pages to remote clients with
+the GetIndexPage RPC.
+
+If K is derived from a password, then anyone who obtains pub can mount an
+offline dictionary attack on the password. DeriveKeyFromHeader() makes the
+attack more expensive by using a memory-hard KDF, Argon2id or scrypt, whose
+parameters are recorded in the store with WithKdfHeader():
+
+		header, err := store.NewKdfHeader(pb.PasswordKdf_ARGON2ID)
+		K, err := store.DeriveKeyFromHeader(password, header)
+		pub, priv, err := store.NewStore(K, M, store.WithKdfHeader(header))
+
+The attack can be prevented altogether by having the server hold an OPRF ("oblivious pseudorandom function")
+key:
+
+		oprfKey := store.GenerateOprfKey()
+		server, err := store.NewOprfServer(oprfKey)
+		K, err := server.DeriveKey(password, nil)
+
+To compute K, the client blinds the password and has the server evaluate the
+OPRF on the result:
+
+		blind, blinded, err := store.BlindPassword(password, nil)
+		evaluated, err := server.Evaluate(blinded) // Run by the server
+		K, err := blind.Finalize(evaluated)
+
+The server learns nothing about the password, but each guess of the password
+requires an interaction with the server, which may rate-limit its evaluations.
+client.DeriveKeyFromProvider() does this via the StoreProvider RPC.
+
+Alternatively, K may be random and wrapped under each password or device key
+that should be able to open the store. The wrapped keys are kept in a key
+envelope, which the StoreProvider stores alongside pub:
+
+		env := new(pb.KeyEnvelope)
+		err := store.WrapKeyWithPassword(env, "password", K, password, header)
+		err = store.WrapKey(env, "laptop", K, deviceKey)
+		version, err := client.PutKeyEnvelope(ctx, conn, user, env, 0)
+
+		K, err := store.UnwrapKeyWithPassword(env, "password", password)
+
+Changing the password re-wraps K under the same id, and RemoveWrappedKey()
+revokes a device; neither requires rebuilding the store.
+
 At the core of data structure is a Bloomier filter, a variant of a technique of
 Charles and Chellapilla for representing functions. (See "Bloomier Filters: A
-second look", appearing at ESA 2008.) It is implemented in C, but this package
-provides Go bindings. You can use it similarly to Store:
+second look", appearing at ESA 2008.) It is implemented in C, and this package
+provides Go bindings. A native Go implementation is used instead if the package
+is built with "-tags purego" or with cgo disabled; the two read and write the
+same tables. You can use it similarly to Store:
 
 		pub, priv, err := store.NewDict(K, M)
 		x, y, err := priv.GetIdx(input)
@@ -113,13 +113,13 @@ func ExampleNewPrivStore() {
 	defer pub.Free()
 	defer priv.Free()
 
-	privFromKeyAndPrivParams, err := NewPrivStore(K, priv.GetParams())
+	privFromKeyAndPrivParams, err := NewPrivStore(K, priv.GetParams(), 0)
 	if err != nil {
 		fmt.Println("NewPrivStore() error:", err)
 	}
 	defer privFromKeyAndPrivParams.Free()
 
-	privFromKeyAndPubParams, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams())
+	privFromKeyAndPubParams, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams(), 0)
 	if err != nil {
 		fmt.Println("NewPrivStore() error:", err)
 	}
@@ -0,0 +1,192 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// Returned by UnwrapKey() and UnwrapKeyWithPassword() if the key-encryption key
+// or password is wrong, or if the wrapped key was modified.
+const ErrorUnwrap = Error("cannot unwrap key")
+
+// Returned by UnwrapKey(), UnwrapKeyWithPassword(), and RemoveWrappedKey() if
+// the envelope has no key with the given id.
+const ErrorNoWrappedKey = Error("no wrapped key with this id")
+
+// Returned by ValidateKeyEnvelope() if the envelope is malformed.
+const ErrorBadEnvelope = Error("bad key envelope")
+
+// The maximum number of keys in an envelope accepted by ValidateKeyEnvelope().
+const MaxWrappedKeys = 64
+
+// The lengths of the nonce and tag of AES-GCM.
+const (
+	envelopeNonceBytes = 12
+	envelopeTagBytes   = 16
+)
+
+// The prefix of the associated data of each wrapped key.
+var envelopeLabel = []byte("store key envelope")
+
+// WrapKey wraps the data key under the key-encryption key kek, which must be
+// KeyBytes long (e.g., generated by GenerateKey()), and adds it to the envelope.
+// The data key is the store key, so it must be at least KeyBytes long.
+// If the envelope already has a key with the same id, then it is replaced.
+func WrapKey(env *pb.KeyEnvelope, id string, dataKey, kek []byte) error {
+	return wrapKey(env, id, dataKey, kek, nil)
+}
+
+// WrapKeyWithPassword is like WrapKey(), except that the key-encryption key is
+// derived from the password using the KDF header. (See NewKdfHeader().) The
+// header is stored with the wrapped key, so UnwrapKeyWithPassword() needs only
+// the password.
+func WrapKeyWithPassword(env *pb.KeyEnvelope, id string, dataKey, password []byte, header *pb.KdfHeader) error {
+	kek, err := DeriveKeyFromHeader(password, header)
+	if err != nil {
+		return err
+	}
+	return wrapKey(env, id, dataKey, kek, header)
+}
+
+// UnwrapKey unwraps the key with the given id using the key-encryption key.
+func UnwrapKey(env *pb.KeyEnvelope, id string, kek []byte) ([]byte, error) {
+	wrapped := findWrappedKey(env, id)
+	if wrapped == nil {
+		return nil, ErrorNoWrappedKey
+	}
+	return unwrapKey(wrapped, kek)
+}
+
+// UnwrapKeyWithPassword unwraps the key with the given id using the password.
+func UnwrapKeyWithPassword(env *pb.KeyEnvelope, id string, password []byte) ([]byte, error) {
+	wrapped := findWrappedKey(env, id)
+	if wrapped == nil {
+		return nil, ErrorNoWrappedKey
+	} else if wrapped.GetKdfHeader() == nil {
+		return nil, ErrorUnwrap
+	}
+	kek, err := DeriveKeyFromHeader(password, wrapped.GetKdfHeader())
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKey(wrapped, kek)
+}
+
+// RemoveWrappedKey removes the key with the given id from the envelope, e.g.,
+// when a device is lost.
+func RemoveWrappedKey(env *pb.KeyEnvelope, id string) error {
+	for i, wrapped := range env.GetKey() {
+		if wrapped.GetId() == id {
+			env.Key = append(env.Key[:i], env.Key[i+1:]...)
+			return nil
+		}
+	}
+	return ErrorNoWrappedKey
+}
+
+// ValidateKeyEnvelope checks that the envelope is well-formed: each key has a
+// distinct, non-empty id, a nonce and ciphertext of the right length, and, if
+// it is wrapped with a password, a valid KDF header. It doesn't (and can't)
+// check that the keys unwrap. The StoreProvider calls this before accepting an
+// envelope.
+func ValidateKeyEnvelope(env *pb.KeyEnvelope) error {
+	if len(env.GetKey()) > MaxWrappedKeys {
+		return ErrorBadEnvelope
+	}
+	ids := make(map[string]bool)
+	for _, wrapped := range env.GetKey() {
+		id := wrapped.GetId()
+		if id == "" || ids[id] ||
+			len(wrapped.GetNonce()) != envelopeNonceBytes ||
+			len(wrapped.GetCiphertext()) < KeyBytes+envelopeTagBytes {
+			return ErrorBadEnvelope
+		}
+		if wrapped.GetKdfHeader() != nil && checkKdfHeader(wrapped.GetKdfHeader()) != nil {
+			return ErrorBadEnvelope
+		}
+		ids[id] = true
+	}
+	return nil
+}
+
+// findWrappedKey returns the key with the given id, or nil if there is none.
+func findWrappedKey(env *pb.KeyEnvelope, id string) *pb.WrappedKey {
+	for _, wrapped := range env.GetKey() {
+		if wrapped.GetId() == id {
+			return wrapped
+		}
+	}
+	return nil
+}
+
+// envelopeAead returns the AEAD (AES-256-GCM) keyed by kek.
+func envelopeAead(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != KeyBytes {
+		return nil, ErrorKeyLength
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// envelopeAd returns the associated data of the wrapped key with the given id,
+// so that a wrapped key can't be moved to a different id.
+func envelopeAd(id string) []byte {
+	ad := append([]byte(nil), envelopeLabel...)
+	ad = append(ad, 0)
+	return append(ad, id...)
+}
+
+// wrapKey wraps dataKey under kek and adds it to env. header is the KDF header
+// used to derive kek, or nil.
+func wrapKey(env *pb.KeyEnvelope, id string, dataKey, kek []byte, header *pb.KdfHeader) error {
+	if len(dataKey) < KeyBytes {
+		return ErrorKeyLength
+	}
+	aead, err := envelopeAead(kek)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	wrapped := &pb.WrappedKey{
+		Id:         id,
+		KdfHeader:  header,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, dataKey, envelopeAd(id)),
+	}
+	for i := range env.GetKey() {
+		if env.Key[i].GetId() == id {
+			env.Key[i] = wrapped
+			return nil
+		}
+	}
+	env.Key = append(env.Key, wrapped)
+	return nil
+}
+
+// unwrapKey unwraps the key using kek.
+func unwrapKey(wrapped *pb.WrappedKey, kek []byte) ([]byte, error) {
+	aead, err := envelopeAead(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped.GetNonce()) != aead.NonceSize() {
+		return nil, ErrorUnwrap
+	}
+	dataKey, err := aead.Open(nil, wrapped.GetNonce(), wrapped.GetCiphertext(), envelopeAd(wrapped.GetId()))
+	if err != nil {
+		return nil, ErrorUnwrap
+	}
+	return dataKey, nil
+}
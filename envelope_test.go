@@ -0,0 +1,129 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestKeyEnvelope(t *testing.T) {
+	K := GenerateKey()
+	pub, priv, err := NewStore(K, goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	header := cheapKdfHeaders()[0]
+	deviceKey := GenerateKey()
+	env := new(pb.KeyEnvelope)
+	if err = WrapKeyWithPassword(env, "password", K, []byte("hunter2"), header); err != nil {
+		t.Fatalf("WrapKeyWithPassword() fails: %s", err)
+	}
+	if err = WrapKey(env, "laptop", K, deviceKey); err != nil {
+		t.Fatalf("WrapKey() fails: %s", err)
+	}
+	if err = ValidateKeyEnvelope(env); err != nil {
+		t.Fatalf("ValidateKeyEnvelope() fails: %s", err)
+	}
+
+	// The store opens with the key unwrapped either way.
+	for _, unwrap := range []func() ([]byte, error){
+		func() ([]byte, error) { return UnwrapKeyWithPassword(env, "password", []byte("hunter2")) },
+		func() ([]byte, error) { return UnwrapKey(env, "laptop", deviceKey) },
+	} {
+		dataKey, err := unwrap()
+		if err != nil {
+			t.Fatalf("unwrap fails: %s", err)
+		}
+		if !bytes.Equal(dataKey, K) {
+			t.Fatalf("unwrapped key = %x, expected %x", dataKey, K)
+		}
+		priv2, err := NewPrivStore(dataKey, pub.GetProto().GetDict().GetParams(), 0)
+		if err != nil {
+			t.Fatalf("NewPrivStore() fails: %s", err)
+		}
+		checkStore(t, pub, priv2, goodM)
+		priv2.Close()
+	}
+
+	// Changing the password re-wraps the same data key; the old password no
+	// longer works, and the store doesn't change.
+	if err = WrapKeyWithPassword(env, "password", K, []byte("correct horse"), header); err != nil {
+		t.Fatalf("WrapKeyWithPassword() fails: %s", err)
+	}
+	if len(env.GetKey()) != 2 {
+		t.Errorf("len(env.GetKey()) = %d, expected 2", len(env.GetKey()))
+	}
+	if _, err = UnwrapKeyWithPassword(env, "password", []byte("hunter2")); err != ErrorUnwrap {
+		t.Errorf("UnwrapKeyWithPassword() returns %v for the old password, expected %q", err, ErrorUnwrap)
+	}
+	if dataKey, err := UnwrapKeyWithPassword(env, "password", []byte("correct horse")); err != nil || !bytes.Equal(dataKey, K) {
+		t.Errorf("UnwrapKeyWithPassword() = (%x, %v), expected (%x, nil)", dataKey, err, K)
+	}
+
+	// Wrong keys and missing ids.
+	if _, err = UnwrapKey(env, "laptop", GenerateKey()); err != ErrorUnwrap {
+		t.Errorf("UnwrapKey() returns %v for the wrong key, expected %q", err, ErrorUnwrap)
+	}
+	if _, err = UnwrapKeyWithPassword(env, "laptop", []byte("hunter2")); err != ErrorUnwrap {
+		t.Errorf("UnwrapKeyWithPassword() returns %v for a device key, expected %q", err, ErrorUnwrap)
+	}
+	if _, err = UnwrapKey(env, "phone", deviceKey); err != ErrorNoWrappedKey {
+		t.Errorf("UnwrapKey() returns %v for a missing id, expected %q", err, ErrorNoWrappedKey)
+	}
+	if err = WrapKey(env, "phone", K, deviceKey[:16]); err != ErrorKeyLength {
+		t.Errorf("WrapKey() returns %v for a short key, expected %q", err, ErrorKeyLength)
+	}
+
+	// A wrapped key is bound to its id.
+	env.Key[1].Id = "phone"
+	if _, err = UnwrapKey(env, "phone", deviceKey); err != ErrorUnwrap {
+		t.Errorf("UnwrapKey() returns %v for a renamed key, expected %q", err, ErrorUnwrap)
+	}
+	env.Key[1].Id = "laptop"
+
+	// Revoking a device.
+	if err = RemoveWrappedKey(env, "laptop"); err != nil {
+		t.Errorf("RemoveWrappedKey() fails: %s", err)
+	}
+	if _, err = UnwrapKey(env, "laptop", deviceKey); err != ErrorNoWrappedKey {
+		t.Errorf("UnwrapKey() returns %v after removal, expected %q", err, ErrorNoWrappedKey)
+	}
+	if err = RemoveWrappedKey(env, "laptop"); err != ErrorNoWrappedKey {
+		t.Errorf("RemoveWrappedKey() returns %v twice, expected %q", err, ErrorNoWrappedKey)
+	}
+}
+
+func TestValidateKeyEnvelope(t *testing.T) {
+	K := GenerateKey()
+	env := new(pb.KeyEnvelope)
+	if err := WrapKey(env, "laptop", K, GenerateKey()); err != nil {
+		t.Fatalf("WrapKey() fails: %s", err)
+	}
+	if err := WrapKeyWithPassword(env, "password", K, []byte("hunter2"), cheapKdfHeaders()[1]); err != nil {
+		t.Fatalf("WrapKeyWithPassword() fails: %s", err)
+	}
+	for _, tc := range []struct {
+		desc   string
+		tamper func(env *pb.KeyEnvelope)
+	}{
+		{"empty id", func(env *pb.KeyEnvelope) { env.Key[0].Id = "" }},
+		{"duplicate id", func(env *pb.KeyEnvelope) { env.Key[1].Id = env.Key[0].Id }},
+		{"short nonce", func(env *pb.KeyEnvelope) { env.Key[0].Nonce = env.Key[0].Nonce[1:] }},
+		{"short ciphertext", func(env *pb.KeyEnvelope) { env.Key[0].Ciphertext = env.Key[0].Ciphertext[:8] }},
+		{"bad header", func(env *pb.KeyEnvelope) { env.Key[1].KdfHeader.Salt = nil }},
+	} {
+		bad := proto.Clone(env).(*pb.KeyEnvelope)
+		tc.tamper(bad)
+		if err := ValidateKeyEnvelope(bad); err != ErrorBadEnvelope {
+			t.Errorf("%s: ValidateKeyEnvelope() returns %v, expected %q", tc.desc, err, ErrorBadEnvelope)
+		}
+	}
+}
@@ -3,18 +3,33 @@
 
 // hadee_client is a toy client that makes RPC requests to hadee_server. The
 // first request gets the parameters, then it prompts the user for actual
-// requests.
+// requests. If -oprf is set, then the key is derived from the password with the
+// help of the server (see hadee_gen). If -commitment is set, then the server's
+// responses are verified against the commitment in the given file (see
+// hadee_gen), so that a misbehaving server can't pass off an input as missing.
+// If -min-version is set, then stores older than the given version are
+// rejected. If -ls is set, then the client lists the inputs of the store
+// instead of prompting for requests.
+// If the server requires authentication,
+// then the user's bearer token is read from the HADEE_TOKEN environment
+// variable.
 //
-// Usage: hadee_client user
+// Usage: hadee_client [-oprf] [-commitment file] [-min-version n] [-ls] user
 package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/client"
 	"github.com/cjpatton/store/pb"
+	"github.com/cjpatton/store/provider"
 	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -22,19 +37,32 @@ import (
 
 const (
 	address  = "localhost:50051"
+	timeout  = 5 * time.Second
 	greeting = "---- Hadee ----------------------------------------------------\n" +
 		"Welcome to Hadee, your super secret source of dog jokes."
 )
 
+var useOprf = flag.Bool("oprf", false, "derive the key with the server's help")
+var commitmentFile = flag.String("commitment", "", "verify responses against the commitment in this file")
+var minVersion = flag.Int64("min-version", 0, "reject stores older than this version")
+var list = flag.Bool("ls", false, "list the inputs of the store and exit")
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("usage: hadee_client user")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Println("usage: hadee_client [-oprf] [-commitment file] [-min-version n] [-ls] user")
 		return
 	}
-	user := os.Args[1]
+	user := flag.Arg(0)
 
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if token := os.Getenv("HADEE_TOKEN"); token != "" {
+		// This is a toy, so the token is sent in the clear.
+		opts = append(opts, grpc.WithPerRPCCredentials(
+			provider.BearerToken{Token: token, AllowInsecure: true}))
+	}
+	conn, err := grpc.Dial(address, opts...)
 	if err != nil {
 		fmt.Printf("did not connect: %v", err)
 		return
@@ -50,6 +78,9 @@ func main() {
 	} else if paramsReply.GetError() == pb.StoreProviderError_BAD_USER {
 		fmt.Printf("ParamsRequest fails: user %q not found\n", user)
 		return
+	} else if paramsReply.GetError() == pb.StoreProviderError_UNAUTHENTICATED {
+		fmt.Println("ParamsRequest fails: unauthenticated (is HADEE_TOKEN set?)")
+		return
 	} else if paramsReply.GetError() != pb.StoreProviderError_OK {
 		fmt.Println("ParamsRequest fails:", paramsReply.GetError())
 		return
@@ -63,17 +94,61 @@ func main() {
 		fmt.Println("terminal.ReadPassword() fails:", err)
 		return
 	}
-	key := store.DeriveKeyFromPassword(password, nil)
-	priv, err := store.NewPrivStore(key, paramsReply.GetParams())
-	if err != nil {
-		fmt.Println("store.NewPrivStore() fails:", err)
+	var key []byte
+	if *useOprf {
+		key, err = client.DeriveKeyFromProvider(context.Background(), c, user, password, nil)
+		if err != nil {
+			fmt.Println("client.DeriveKeyFromProvider() fails:", err)
+			return
+		}
+	} else if header := paramsReply.GetKdfHeader(); header != nil {
+		// The store records how the key is derived from the password.
+		if key, err = store.DeriveKeyFromHeader(password, header); err != nil {
+			fmt.Println("store.DeriveKeyFromHeader() fails:", err)
+			return
+		}
+	} else {
+		key = store.DeriveKeyFromPassword(password, nil)
+	}
+	clientOpts := []client.Option{
+		client.WithTimeout(timeout),
+		client.WithMinVersion(*minVersion),
+	}
+	if *commitmentFile != "" {
+		commitment, err := ioutil.ReadFile(*commitmentFile)
+		if err != nil {
+			fmt.Println("ioutil.ReadFile() fails:", err)
+			return
+		}
+		clientOpts = append(clientOpts, client.WithCommitment(commitment))
+	}
+	r := client.New(c, user, key, clientOpts...)
+	defer r.Close()
+
+	if *list {
+		// The inputs are listed from the store's index (see hadee_gen).
+		keys, err := r.Keys(context.Background())
+		if err == store.ItemNotFound {
+			fmt.Println("\nThe store has no index of its inputs.")
+			return
+		} else if err == store.ErrorBadIndex {
+			fmt.Println("\nThe server's index isn't authenticated. (Wrong master password?)")
+			return
+		} else if err != nil {
+			fmt.Println("\nr.Keys() fails:", err)
+			return
+		}
+		fmt.Println()
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Println(key)
+		}
 		return
 	}
-	defer priv.Free()
 
 	bio := bufio.NewReader(os.Stdin)
-	fmt.Println("\nEnter an input and we'll give you the output. Type \"ls\" to")
-	fmt.Println("see the list of inputs. Type \"quit\" to leave.")
+	fmt.Println("\nEnter an input and we'll give you the output. Type \"quit\" to")
+	fmt.Println("leave. (Run with -ls to see the list of inputs.)")
 	fmt.Println("---------------------------------------------------------------")
 
 	for {
@@ -89,34 +164,17 @@ func main() {
 			break
 		}
 
-		x, y, err := priv.GetIdx(in)
-		if err != nil {
-			fmt.Println("priv.GetIdx(in) fails:", err)
-			return
-		}
-		shareReply, err := c.GetShare(context.Background(),
-			&pb.ShareRequest{
-				UserId: user,
-				X:      int32(x),
-				Y:      int32(y),
-			},
-		)
-		if err != nil {
-			fmt.Println("ShareRequest fails:", err)
-			return
-		} else if shareReply.GetError() == pb.StoreProviderError_ITEM_NOT_FOUND {
-			fmt.Println("Server says item not found. (Wrong master password?)")
-			continue
-		} else if shareReply.GetError() != pb.StoreProviderError_OK {
-			fmt.Println("ShareRequest fails:", shareReply.GetError())
-			return
-		}
-
-		out, err := priv.GetOutput(in, shareReply.GetPubShare())
+		out, err := r.Get(context.Background(), in)
 		if err == store.ItemNotFound {
 			fmt.Println("Item not found. (Wrong master password?)")
+		} else if err == store.ErrorStaleStore {
+			fmt.Println("The server's store is older than the minimum version.")
+		} else if err == store.ErrorBadParams {
+			fmt.Println("The server's parameters aren't authenticated. (Wrong master password?)")
+		} else if err == store.ErrorBadProof {
+			fmt.Println("The server's response doesn't match the commitment.")
 		} else if err != nil {
-			fmt.Println("priv.GetValue() fails:", err)
+			fmt.Println("r.Get() fails:", err)
 			return
 		} else {
 			fmt.Println(out)
@@ -2,15 +2,30 @@
 // All rights reserved.
 
 // hadee_gen generates a sample store from a password. It outputs a file called
-// store.pub.
+// store.pub and the store's commitment to a file called store.commitment,
+// which hadee_client uses to verify the server's responses. If -oprf is set,
+// then it also generates an OPRF key for hadee_server, which is used to harden
+// the password, and outputs it to a file called store.oprf. The version of the
+// store is set by -version; each time the store is regenerated, the version
+// should be increased, so that hadee_client can reject older stores (see its
+// -min-version flag). The store has an index of its inputs, which hadee_client
+// lists.
+//
+// Unless -oprf is set, the key is derived from the password with the KDF chosen
+// by -kdf (argon2id, scrypt, or pbkdf2_sha256). The KDF's parameters and salt are
+// stored in store.pub, so hadee_client needs only the password.
+//
+// Usage: hadee_gen [-oprf] [-kdf name] [-version n]
 package main
 
 import (
-	"fmt"
+	"flag"
 	"io/ioutil"
 	"log"
+	"strings"
 
 	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -34,33 +49,53 @@ var M = map[string]string{
 	"When I heard ...":                               ww,
 }
 
+var useOprf = flag.Bool("oprf", false, "harden the password with an OPRF key")
+var version = flag.Int64("version", 1, "the version of the store")
+var kdfName = flag.String("kdf", "argon2id", "the password KDF: argon2id, scrypt, or pbkdf2_sha256")
+
 func main() {
+	flag.Parse()
 	log.Println("Please enter your super secret password:")
 	password, err := terminal.ReadPassword(0)
 	if err != nil {
 		log.Fatalln("terminal.ReadPassword() fails:", err)
 	}
 
-	ls := make([]string, 0)
-	for in, out := range M {
-		if in == "ls" {
-			log.Printf("warning: overwriting item (%q, %q) in input", in, out)
+	var K []byte
+	opts := []store.Option{store.WithVersion(*version), store.WithIndex()}
+	if *useOprf {
+		oprfKey := store.GenerateOprfKey()
+		oprf, err := store.NewOprfServer(oprfKey)
+		if err != nil {
+			log.Fatalln("store.NewOprfServer() fails:", err)
 		}
-		ls = append(ls, in)
-	}
-	lsStr := ""
-	for i := 0; i < len(ls); i++ {
-		lsStr += fmt.Sprintf("%s\n", ls[i])
+		if K, err = oprf.DeriveKey(password, nil); err != nil {
+			log.Fatalln("oprf.DeriveKey() fails:", err)
+		}
+		if err := ioutil.WriteFile("store.oprf", oprfKey, 0600); err != nil {
+			log.Fatalln("Writing OPRF key fails:", err)
+		}
+		log.Println("Wrote store.oprf.")
+	} else {
+		kdf, ok := pb.PasswordKdf_value[strings.ToUpper(*kdfName)]
+		if !ok {
+			log.Fatalf("unknown KDF %q", *kdfName)
+		}
+		header, err := store.NewKdfHeader(pb.PasswordKdf(kdf))
+		if err != nil {
+			log.Fatalln("store.NewKdfHeader() fails:", err)
+		}
+		if K, err = store.DeriveKeyFromHeader(password, header); err != nil {
+			log.Fatalln("store.DeriveKeyFromHeader() fails:", err)
+		}
+		opts = append(opts, store.WithKdfHeader(header))
 	}
-	M["ls"] = lsStr
-
-	K := store.DeriveKeyFromPassword(password, nil)
-	pub, priv, err := store.NewStore(K, M)
+	pub, priv, err := store.NewStore(K, M, opts...)
 	if err != nil {
 		log.Fatalln("store.New() fails:", err)
 	}
-	priv.Free()
-	defer pub.Free()
+	priv.Close()
+	defer pub.Close()
 
 	log.Println("The store:")
 	log.Println("\n", pub.String())
@@ -74,5 +109,9 @@ func main() {
 		log.Fatalln("Writing table fails:", err)
 	}
 
-	log.Println("Wrote store.pub.")
+	if err := ioutil.WriteFile("store.commitment", pub.Commitment(), 0644); err != nil {
+		log.Fatalln("Writing commitment fails:", err)
+	}
+
+	log.Println("Wrote store.pub and store.commitment.")
 }
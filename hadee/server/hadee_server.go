@@ -3,61 +3,104 @@
 
 // hadee_serv is a toy server implementing the StoreProvider RPC specified in
 // store.proto. It services requests for only one user, whose identity and table
-// are specified via the command line.
+// are specified via the command line. If an OPRF key file is specified (see
+// hadee_gen), then the server also evaluates the OPRF for the user, allowing
+// at most one evaluation per oprfInterval. If a credentials config file is
+// specified (see provider.LoadCredentials()), then each request must carry the
+// user's bearer token.
 //
-// Usage: hadee_serv user store.pub
+// Usage: hadee_serv [-auth creds.json] user store.pub [store.oprf]
 package main
 
 import (
+	"flag"
 	"io/ioutil"
 	"log"
 	"net"
-	"os"
+	"sync"
+	"time"
 
 	"github.com/cjpatton/store"
 	"github.com/cjpatton/store/pb"
+	"github.com/cjpatton/store/provider"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	port = ":50051"
+
+	// The minimum time between OPRF evaluations for a user. This limits the
+	// rate at which an attacker can guess the user's password.
+	oprfInterval = time.Second
+)
+
+var (
+	authConfig = flag.String("auth", "", "credentials config file")
 )
 
 // HadeeStoreProvider implements the StoreProvider RPC.
 type HadeeStoreProvider struct {
-	pubs   map[string](*store.PubStore)
-	params map[string](*pb.Params)
+	pubs      map[string](*store.PubStore)
+	params    map[string](*pb.Params)
+	kdfHeader map[string](*pb.KdfHeader)
+	oprf      map[string](*store.OprfServer)
+	creds     *provider.Credentials // If nil, then requests are not authenticated.
+
+	mu       sync.Mutex           // Protects lastOprf.
+	lastOprf map[string]time.Time // The time of the last OPRF evaluation.
 }
 
-// NewHadeeStoreProvider creates a new HadeeStoreProvider.
-//
-// NOTE Must be dstroyed with s.CleanUp().
-func NewHadeeStoreProvider(user string, table *pb.Store) *HadeeStoreProvider {
+// NewHadeeStoreProvider creates a new HadeeStoreProvider. If oprf is not nil,
+// then the provider evaluates the OPRF for the user. If creds is not nil, then
+// each request must be authenticated as the user it names.
+func NewHadeeStoreProvider(user string, table *pb.Store, oprf *store.OprfServer, creds *provider.Credentials) *HadeeStoreProvider {
 	s := new(HadeeStoreProvider)
+	s.creds = creds
 	s.pubs = make(map[string](*store.PubStore))
 	s.params = make(map[string](*pb.Params))
+	s.kdfHeader = make(map[string](*pb.KdfHeader))
+	s.oprf = make(map[string](*store.OprfServer))
+	s.lastOprf = make(map[string]time.Time)
 	s.pubs[user] = store.NewPubStoreFromProto(table)
 	s.params[user] = table.GetDict().GetParams()
+	s.kdfHeader[user] = table.GetKdfHeader()
+	if oprf != nil {
+		s.oprf[user] = oprf
+	}
 	return s
 }
 
-// CleanUp frees memory allocated to each pub in pubs. This is necessary because
-// the underlying data structure is implemented in C.
+// CleanUp closes each pub in pubs. This releases the memory allocated to the
+// underlying data structure without waiting for the garbage collector.
 func (s *HadeeStoreProvider) CleanUp() {
 	for _, pub := range s.pubs {
 		if pub != nil {
-			pub.Free()
+			pub.Close()
 		}
 	}
 }
 
+// authorized returns true if the request may access user's store.
+func (s *HadeeStoreProvider) authorized(ctx context.Context, user string) bool {
+	return s.creds == nil || provider.Authorized(ctx, user)
+}
+
 func (s *HadeeStoreProvider) GetShare(ctx context.Context, in *pb.ShareRequest) (*pb.ShareReply, error) {
 	log.Println("GetShare")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.ShareReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
 	if pub, ok := s.pubs[in.GetUserId()]; ok {
-		if pubShare, err := pub.GetShare(int(in.GetX()), int(in.GetY())); err == nil {
+		getShare := pub.GetShare
+		if in.GetVerifiable() {
+			getShare = pub.GetVerifiableShare
+		}
+		if pubShare, err := getShare(int(in.GetX()), int(in.GetY())); err == nil {
 			return &pb.ShareReply{Error: pb.StoreProviderError_OK, PubShare: pubShare}, nil
 		} else if err == store.ErrorIdx {
 			return &pb.ShareReply{Error: pb.StoreProviderError_INDEX}, nil
@@ -70,21 +113,164 @@ func (s *HadeeStoreProvider) GetShare(ctx context.Context, in *pb.ShareRequest)
 	return &pb.ShareReply{Error: pb.StoreProviderError_BAD_USER}, nil
 }
 
+func (s *HadeeStoreProvider) GetShares(ctx context.Context, in *pb.SharesRequest) (*pb.SharesReply, error) {
+	log.Println("GetShares")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.SharesReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if len(in.GetIndex()) > provider.MaxShares {
+		return &pb.SharesReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+	pub, ok := s.pubs[in.GetUserId()]
+	if !ok {
+		return &pb.SharesReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	idx := make([]store.Index, len(in.GetIndex()))
+	for i, index := range in.GetIndex() {
+		idx[i] = store.Index{X: int(index.GetX()), Y: int(index.GetY())}
+	}
+	getShares := pub.GetShares
+	if in.GetVerifiable() {
+		getShares = pub.GetVerifiableShares
+	}
+	pubShares, errs := getShares(idx)
+	reply := &pb.SharesReply{Share: make([]*pb.SharesReply_Share, len(idx))}
+	for i := range idx {
+		reply.Share[i] = &pb.SharesReply_Share{PubShare: pubShares[i]}
+		if errs[i] == store.ErrorIdx {
+			reply.Share[i].Error = pb.StoreProviderError_INDEX
+		} else if errs[i] == store.ItemNotFound {
+			reply.Share[i].Error = pb.StoreProviderError_ITEM_NOT_FOUND
+		} else if errs[i] != nil {
+			return nil, errs[i] // Unexpected error!
+		}
+	}
+	return reply, nil
+}
+
+func (s *HadeeStoreProvider) GetPirShare(ctx context.Context, in *pb.PirRequest) (*pb.PirReply, error) {
+	log.Println("GetPirShare")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.PirReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if len(in.GetQuery()) > provider.MaxShares {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+	pub, ok := s.pubs[in.GetUserId()]
+	if !ok {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	pubShares, err := pub.GetPirShare(in.GetQuery())
+	if err == store.ErrorPirQuery || err == store.ErrorPirSealed {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.PirReply{PubShare: pubShares}, nil
+}
+
+func (s *HadeeStoreProvider) GetSimplePirHint(ctx context.Context, in *pb.SimplePirHintRequest) (*pb.SimplePirHintReply, error) {
+	log.Println("GetSimplePirHint")
+	return nil, status.Error(codes.Unimplemented, "single-server PIR is not supported")
+}
+
+func (s *HadeeStoreProvider) GetSimplePirShare(ctx context.Context, in *pb.PirRequest) (*pb.PirReply, error) {
+	log.Println("GetSimplePirShare")
+	return nil, status.Error(codes.Unimplemented, "single-server PIR is not supported")
+}
+
 func (s *HadeeStoreProvider) GetParams(ctx context.Context, in *pb.ParamsRequest) (*pb.ParamsReply, error) {
 	log.Println("GetParams")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.ParamsReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
 	if params, ok := s.params[in.GetUserId()]; ok {
-		return &pb.ParamsReply{Error: pb.StoreProviderError_OK, Params: params}, nil
+		return &pb.ParamsReply{
+			Error:     pb.StoreProviderError_OK,
+			Params:    params,
+			KdfHeader: s.kdfHeader[in.GetUserId()],
+		}, nil
 	}
 	return &pb.ParamsReply{Error: pb.StoreProviderError_BAD_USER}, nil
 }
 
-func main() {
+func (s *HadeeStoreProvider) GetIndexPage(ctx context.Context, in *pb.IndexPageRequest) (*pb.IndexPageReply, error) {
+	log.Println("GetIndexPage")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	pub, ok := s.pubs[in.GetUserId()]
+	if !ok {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	page, pageCt, err := pub.GetIndexPage(int(in.GetPage()))
+	if err == store.ErrorNoIndex {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_ITEM_NOT_FOUND}, nil
+	} else if err == store.ErrorIdx {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_INDEX, PageCt: int32(pageCt)}, nil
+	} else if err != nil {
+		return nil, err // Unexpected error!
+	}
+	return &pb.IndexPageReply{Error: pb.StoreProviderError_OK, Page: page, PageCt: int32(pageCt)}, nil
+}
+
+func (s *HadeeStoreProvider) EvaluateOprf(ctx context.Context, in *pb.OprfRequest) (*pb.OprfReply, error) {
+	log.Println("EvaluateOprf")
+	if !s.authorized(ctx, in.GetUserId()) {
+		return &pb.OprfReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	oprf, ok := s.oprf[in.GetUserId()]
+	if !ok {
+		return &pb.OprfReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	if last, ok := s.lastOprf[in.GetUserId()]; ok && now.Sub(last) < oprfInterval {
+		s.mu.Unlock()
+		return &pb.OprfReply{Error: pb.StoreProviderError_RATE_LIMITED}, nil
+	}
+	s.lastOprf[in.GetUserId()] = now
+	s.mu.Unlock()
+
+	evaluated, err := oprf.Evaluate(in.GetBlindedElement())
+	if err == store.ErrorOprfInput {
+		return &pb.OprfReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err // Unexpected error!
+	}
+	return &pb.OprfReply{Error: pb.StoreProviderError_OK, EvaluatedElement: evaluated}, nil
+}
+
+// The toy server doesn't support provisioning stores remotely. See
+// provider.DirProvider.
+func (s *HadeeStoreProvider) PutStore(ctx context.Context, in *pb.PutStoreRequest) (*pb.PutStoreReply, error) {
+	return nil, status.Error(codes.Unimplemented, "PutStore is not supported")
+}
+
+func (s *HadeeStoreProvider) DeleteStore(ctx context.Context, in *pb.DeleteStoreRequest) (*pb.DeleteStoreReply, error) {
+	return nil, status.Error(codes.Unimplemented, "DeleteStore is not supported")
+}
+
+func (s *HadeeStoreProvider) GetStoreVersion(ctx context.Context, in *pb.StoreVersionRequest) (*pb.StoreVersionReply, error) {
+	return nil, status.Error(codes.Unimplemented, "GetStoreVersion is not supported")
+}
+
+func (s *HadeeStoreProvider) GetKeyEnvelope(ctx context.Context, in *pb.KeyEnvelopeRequest) (*pb.KeyEnvelopeReply, error) {
+	return nil, status.Error(codes.Unimplemented, "GetKeyEnvelope is not supported")
+}
+
+func (s *HadeeStoreProvider) PutKeyEnvelope(ctx context.Context, in *pb.PutKeyEnvelopeRequest) (*pb.PutKeyEnvelopeReply, error) {
+	return nil, status.Error(codes.Unimplemented, "PutKeyEnvelope is not supported")
+}
 
-	if len(os.Args) != 3 {
-		log.Fatal("error: usage: hadee_server user store.pub")
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 && flag.NArg() != 3 {
+		log.Fatal("error: usage: hadee_server [-auth creds.json] user store.pub [store.oprf]")
 	}
-	user := os.Args[1]
-	tableString, err := ioutil.ReadFile(os.Args[2])
+	user := flag.Arg(0)
+	tableString, err := ioutil.ReadFile(flag.Arg(1))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -93,15 +279,35 @@ func main() {
 		log.Fatal("failed to parse protobuf: ", err)
 	}
 
+	var oprf *store.OprfServer
+	if flag.NArg() == 3 {
+		oprfKey, err := ioutil.ReadFile(flag.Arg(2))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if oprf, err = store.NewOprfServer(oprfKey); err != nil {
+			log.Fatal("failed to parse OPRF key: ", err)
+		}
+	}
+
+	var creds *provider.Credentials
+	opts := []grpc.ServerOption{}
+	if *authConfig != "" {
+		if creds, err = provider.LoadCredentials(*authConfig); err != nil {
+			log.Fatal("failed to load credentials: ", err)
+		}
+		opts = append(opts, grpc.UnaryInterceptor(creds.UnaryInterceptor))
+	}
+
 	// Begin serving.
-	storeProvider := NewHadeeStoreProvider(user, table)
+	storeProvider := NewHadeeStoreProvider(user, table, oprf, creds)
 	defer storeProvider.CleanUp()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	log.Println("Opened TCP socket on", port)
-	s := grpc.NewServer()
+	s := grpc.NewServer(opts...)
 	pb.RegisterStoreProviderServer(s, storeProvider)
 	reflection.Register(s)
 	if err := s.Serve(lis); err != nil {
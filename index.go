@@ -0,0 +1,381 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sort"
+)
+
+// Returned by priv.Keys(), priv.List(), pub.GetIndexPage(), and priv.Rekey()
+// if the store has no index of its inputs. (See WithIndex().)
+const ErrorNoIndex = Error("store has no index of inputs")
+
+// Returned by priv.Keys(), priv.List(), priv.OpenIndexPage(), and priv.Rekey()
+// if a page of the index can't be opened or the index doesn't match the store.
+const ErrorBadIndex = Error("index of inputs is not authenticated")
+
+// The number of inputs listed by each page of the index. (See WithIndex().)
+const IndexPageSize = 256
+
+// The number of sealed outputs written by each update of a store with an index.
+// (See WithIndex().)
+const UpdateCover = 8
+
+// The prefix of the associated data of each page of the index.
+var indexAdPrefix = []byte("store index")
+
+// WithIndex adds an index of the inputs to the store. The index is a sequence
+// of pages, each listing the inputs of IndexPageSize consecutive edges of the
+// graph, and each sealed under a key derived from the store key. The server
+// learns only the number of pages. The index is kept up to date by
+// priv.Insert(), priv.Update(), and priv.Delete(). It is read by priv.Keys()
+// and priv.List(), and it is what allows priv.Rekey() to rebuild the store
+// without the map.
+//
+// The index also hides which input an update changes. Each update of a store
+// with an index writes UpdateCover sealed outputs (or all of them, if there
+// are fewer): the one that changes, and others chosen at random, whose inputs
+// are read from the index and whose outputs are sealed again under fresh
+// counters. Their trees are re-randomized, too, and their pages of the index
+// are sealed again, so the server can't tell which of them changed.
+//
+// The inputs are encoded with their lengths, so any string, including one with
+// newlines, may be an input, and no input is reserved.
+func WithIndex() Option {
+	return func(priv *PrivStore) {
+		priv.indexed = true
+	}
+}
+
+// An indexEntry is the entry of an edge in the index. The entry of a deleted
+// input is kept, so that deleting an input doesn't change the length of the
+// page.
+type indexEntry struct {
+	input string
+	live  bool
+}
+
+// indexPages returns the number of pages needed to index edgeCt edges. The
+// index of an empty store has one (empty) page, so that it is distinguished
+// from a store without an index.
+func indexPages(edgeCt int) int {
+	if edgeCt == 0 {
+		return 1
+	}
+	return (edgeCt + IndexPageSize - 1) / IndexPageSize
+}
+
+// indexAd returns the associated data of the page, which binds the page to its
+// position and to the store. (The salt is fresh for each store.)
+func (priv *PrivStore) indexAd(page int) []byte {
+	ad := append([]byte(nil), indexAdPrefix...)
+	ad = append(ad, priv.dict.salt()...)
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(page))
+	return append(ad, buf[:]...)
+}
+
+// sealIndexPage encodes and seals the page. Each entry is encoded as a flag
+// byte (1 if the input is live and 0 if it was deleted), the length of the
+// input as a varint, and the input. The sealed page is the nonce followed by
+// the ciphertext.
+func (priv *PrivStore) sealIndexPage(page int, entries []indexEntry) ([]byte, error) {
+	var data []byte
+	var buf [binary.MaxVarintLen64]byte
+	for _, entry := range entries {
+		var flag byte
+		if entry.live {
+			flag = 1
+		}
+		data = append(data, flag)
+		data = append(data, buf[:binary.PutUvarint(buf[:], uint64(len(entry.input)))]...)
+		data = append(data, entry.input...)
+	}
+	nonce := make([]byte, priv.indexAead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return priv.indexAead.Seal(nonce, nonce, data, priv.indexAd(page)), nil
+}
+
+// openIndexPage opens and decodes the page. It returns ErrorBadIndex if the
+// page is not authentic.
+func (priv *PrivStore) openIndexPage(page int, sealed []byte) ([]indexEntry, error) {
+	nonceBytes := priv.indexAead.NonceSize()
+	if len(sealed) < nonceBytes {
+		return nil, ErrorBadIndex
+	}
+	data, err := priv.indexAead.Open(nil, sealed[:nonceBytes], sealed[nonceBytes:], priv.indexAd(page))
+	if err != nil {
+		return nil, ErrorBadIndex
+	}
+	var entries []indexEntry
+	for len(data) > 0 {
+		if data[0] > 1 || len(entries) == IndexPageSize {
+			return nil, ErrorBadIndex
+		}
+		n, k := binary.Uvarint(data[1:])
+		if k <= 0 || n > uint64(len(data)-1-k) {
+			return nil, ErrorBadIndex
+		}
+		entries = append(entries, indexEntry{
+			input: string(data[1+k : 1+k+int(n)]),
+			live:  data[0] == 1,
+		})
+		data = data[1+k+int(n):]
+	}
+	return entries, nil
+}
+
+// buildIndex returns the sealed pages of the index of the inputs, where the
+// i-th input corresponds to the i-th edge.
+func (priv *PrivStore) buildIndex(inputs [][]byte) ([][]byte, error) {
+	index := make([][]byte, indexPages(len(inputs)))
+	for page := range index {
+		start := page * IndexPageSize
+		end := start + IndexPageSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		entries := make([]indexEntry, 0, end-start)
+		for _, in := range inputs[start:end] {
+			entries = append(entries, indexEntry{string(in), true})
+		}
+		var err error
+		if index[page], err = priv.sealIndexPage(page, entries); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// GetIndexPage returns the sealed page of the index and the number of pages. It
+// returns ErrorNoIndex if the store has no index and ErrorIdx if there is no
+// such page. This is used by the StoreProvider to serve the index to clients,
+// which open each page with priv.OpenIndexPage().
+func (pub *PubStore) GetIndexPage(page int) ([]byte, int, error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return nil, 0, ErrorClosed
+	} else if len(pub.index) == 0 {
+		return nil, 0, ErrorNoIndex
+	} else if page < 0 || page >= len(pub.index) {
+		return nil, len(pub.index), ErrorIdx
+	}
+	return pub.index[page], len(pub.index), nil
+}
+
+// OpenIndexPage opens the sealed page of the index and returns the inputs it
+// lists, omitting inputs that were deleted. It returns ErrorBadIndex if the
+// page is not authentic.
+func (priv *PrivStore) OpenIndexPage(page int, sealed []byte) ([]string, error) {
+	entries, err := priv.openIndexPage(page, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return liveInputs(entries), nil
+}
+
+// List calls fn for each input in the store, in the order they were inserted,
+// reading the index one page at a time. If fn returns an error, then List stops
+// and returns it. The inputs are read from the index, which is sealed, so the
+// server can't add inputs to the list; it can only withhold pages, in which
+// case List returns ErrorBadIndex.
+func (priv *PrivStore) List(pub *PubStore, fn func(input string) error) error {
+	return priv.forEachPage(pub, func(total int, entries []indexEntry) error {
+		for _, input := range liveInputs(entries) {
+			if err := fn(input); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Keys returns the inputs in the store in the order they were inserted. (See
+// priv.List().)
+func (priv *PrivStore) Keys(pub *PubStore) ([]string, error) {
+	var inputs []string
+	err := priv.List(pub, func(input string) error {
+		inputs = append(inputs, input)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+// forEachPage opens each page of the index of pub and calls fn with the number
+// of edges of pub and the entries of the page. It stops if fn returns an error.
+func (priv *PrivStore) forEachPage(pub *PubStore, fn func(total int, entries []indexEntry) error) error {
+	// Copy the index so that pub isn't locked while fn is called.
+	pub.mu.RLock()
+	if pub.dict.closed() {
+		pub.mu.RUnlock()
+		return ErrorClosed
+	}
+	index := append([][]byte(nil), pub.index...)
+	total := len(pub.sealed)
+	pub.mu.RUnlock()
+	if len(index) == 0 {
+		return ErrorNoIndex
+	} else if len(index) != indexPages(total) {
+		return ErrorBadIndex
+	}
+
+	done := 0
+	for page := range index {
+		entries, err := priv.openIndexPage(page, index[page])
+		if err != nil {
+			return err
+		}
+		// Every page but the last is full.
+		if len(entries) != IndexPageSize && page < len(index)-1 {
+			return ErrorBadIndex
+		}
+		done += len(entries)
+		if err = fn(total, entries); err != nil {
+			return err
+		}
+	}
+	if done != total {
+		return ErrorBadIndex
+	}
+	return nil
+}
+
+// liveInputs returns the inputs of the entries that were not deleted.
+func liveInputs(entries []indexEntry) []string {
+	inputs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.live {
+			inputs = append(inputs, entry.input)
+		}
+	}
+	return inputs
+}
+
+// updateIndex sets the entry of each edge e in entries to entries[e] and returns
+// the numbers and the new contents of the pages containing them, in order. If e
+// is the next edge of the graph, then its entry is appended. The caller must
+// hold pub.mu.
+func (priv *PrivStore) updateIndex(pub *PubStore, entries map[int]indexEntry) ([]int32, [][]byte, error) {
+	pages := make(map[int][]indexEntry)
+	var order []int32
+	for e := range entries {
+		page := e / IndexPageSize
+		if _, ok := pages[page]; ok {
+			continue
+		}
+		var pageEntries []indexEntry
+		if page < len(pub.index) {
+			var err error
+			if pageEntries, err = priv.openIndexPage(page, pub.index[page]); err != nil {
+				return nil, nil, err
+			}
+		} else if page > len(pub.index) {
+			return nil, nil, ErrorBadIndex
+		}
+		pages[page] = pageEntries
+		order = append(order, int32(page))
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	// Set the entries in order, so that an appended entry follows the rest.
+	edges := make([]int, 0, len(entries))
+	for e := range entries {
+		edges = append(edges, e)
+	}
+	sort.Ints(edges)
+	for _, e := range edges {
+		page, i := e/IndexPageSize, e%IndexPageSize
+		if i == len(pages[page]) {
+			pages[page] = append(pages[page], indexEntry{})
+		} else if i > len(pages[page]) {
+			return nil, nil, ErrorBadIndex
+		}
+		pages[page][i] = entries[e]
+	}
+
+	sealed := make([][]byte, len(order))
+	for i, page := range order {
+		var err error
+		if sealed[i], err = priv.sealIndexPage(int(page), pages[int(page)]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return order, sealed, nil
+}
+
+// coverEntries chooses up to n edges of pub other than skip uniformly at random
+// and returns their entries, which are re-sealed by an update along with the
+// edge it writes. The inputs are read from the index. The caller must hold
+// pub.mu.
+func (priv *PrivStore) coverEntries(pub *PubStore, skip, n int) ([]updateEntry, error) {
+	edges := make([]int, 0, len(pub.sealed))
+	for e := range pub.sealed {
+		if e != skip {
+			edges = append(edges, e)
+		}
+	}
+	if n > len(edges) {
+		n = len(edges)
+	}
+	pages := make(map[int][]indexEntry)
+	rowBytes := priv.dict.rowBytes()
+	cover := make([]updateEntry, 0, n)
+	for i := 0; i < n; i++ {
+		// Choose the i-th edge from the ones not chosen so far.
+		j, err := randInt(len(edges) - i)
+		if err != nil {
+			return nil, err
+		}
+		edges[i], edges[i+j] = edges[i+j], edges[i]
+		e := edges[i]
+
+		page := e / IndexPageSize
+		if _, ok := pages[page]; !ok {
+			if page >= len(pub.index) {
+				return nil, ErrorBadIndex
+			}
+			if pages[page], err = priv.openIndexPage(page, pub.index[page]); err != nil {
+				return nil, err
+			}
+		}
+		if e%IndexPageSize >= len(pages[page]) {
+			return nil, ErrorBadIndex
+		}
+		entry := pages[page][e%IndexPageSize]
+
+		// Open the sealed output of the edge, so that it can be sealed again
+		// under a fresh counter. The sealed output of a deleted input is its
+		// tombstone.
+		x, y, err := priv.GetIdx(entry.input)
+		if err != nil {
+			return nil, err
+		}
+		if pub.g.edge(x, y) != e {
+			return nil, ErrorBadIndex
+		}
+		pubShare, err := pub.getShare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		ctr, err := priv.dict.GetOutput(entry.input, pubShare[:rowBytes])
+		if err != nil {
+			return nil, err
+		}
+		nonce := append(priv.dict.salt(), ctr...)
+		output, err := priv.aead.Open(nil, nonce, pub.sealed[e], priv.ad(entry.input))
+		if err != nil || entry.live == isTombstone([]byte(ctr)) {
+			return nil, ErrorBadIndex
+		}
+		cover = append(cover, updateEntry{e, x, y, entry.input, output, entry.live})
+	}
+	return cover, nil
+}
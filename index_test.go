@@ -0,0 +1,309 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// bigM returns a map with n entries.
+func bigM(n int) map[string]string {
+	M := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		M[fmt.Sprintf("input %d", i)] = fmt.Sprintf("output %d", i)
+	}
+	return M
+}
+
+// readMap reads the map of pub from its index.
+func readMap(priv *PrivStore, pub *PubStore) (map[string]string, error) {
+	inputs, outputs, err := priv.readIndex(pub, nil)
+	if err != nil {
+		return nil, err
+	}
+	M := make(map[string]string, len(inputs))
+	for i := range inputs {
+		M[string(inputs[i])] = string(outputs[i])
+	}
+	return M, nil
+}
+
+func TestIndex(t *testing.T) {
+	// One full page, so that the first insertion starts a new page.
+	M := bigM(IndexPageSize)
+	pub, priv, err := NewStore(GenerateKey(), M, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	if len(pub.index) != 1 {
+		t.Fatalf("len(pub.index) = %d, expected 1", len(pub.index))
+	}
+	got, err := readMap(priv, pub)
+	if err != nil {
+		t.Fatalf("priv.readIndex() fails: %s", err)
+	}
+	if !reflect.DeepEqual(got, M) {
+		t.Errorf("priv.readIndex() doesn't match the map")
+	}
+
+	// Updates keep the index current.
+	in := insertAny(t, pub, &priv, "inserted")
+	M[in] = "inserted"
+	if len(pub.index) != 2 {
+		t.Errorf("len(pub.index) = %d after an insertion, expected 2", len(pub.index))
+	}
+	update, err := priv.Update(pub, "input 0", "updated")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	M["input 0"] = "updated"
+	if update, err = priv.Delete(pub, "input 1"); err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	delete(M, "input 1")
+	if got, err = readMap(priv, pub); err != nil {
+		t.Fatalf("priv.readIndex() fails: %s", err)
+	}
+	if !reflect.DeepEqual(got, M) {
+		t.Errorf("priv.readIndex() doesn't match the map after the updates")
+	}
+
+	// The order of the inputs is preserved by updates.
+	keys, err := priv.Keys(pub)
+	if err != nil {
+		t.Fatalf("priv.Keys() fails: %s", err)
+	}
+	if keys[len(keys)-1] != in {
+		t.Errorf("the last input is %q, expected %q", keys[len(keys)-1], in)
+	}
+
+	// An update must change the index if the store has one.
+	if update, err = priv.Update(pub, "input 2", "updated"); err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	update.IndexPage, update.Index = nil, nil
+	if err = pub.ApplyUpdate(update); err != ErrorStaleUpdate {
+		t.Errorf("pub.ApplyUpdate() returns %v without the index, expected %q", err, ErrorStaleUpdate)
+	}
+
+	// The index survives serialization.
+	table := pub.GetProto()
+	if err = ValidateStoreProto(table); err != nil {
+		t.Fatalf("ValidateStoreProto() fails: %s", err)
+	}
+	pub2 := NewPubStoreFromProto(table)
+	defer pub2.Close()
+	if got, err = readMap(priv, pub2); err != nil || !reflect.DeepEqual(got, M) {
+		t.Errorf("priv.readIndex() fails for the deserialized store: %v", err)
+	}
+	table.Index = append(table.Index, table.Index[0])
+	if err = ValidateStoreProto(table); err != ErrorBadStore {
+		t.Errorf("ValidateStoreProto() returns %v for an index with an extra page, expected %q", err, ErrorBadStore)
+	}
+}
+
+func TestIndexTamper(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), bigM(2*IndexPageSize+1), WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if len(pub.index) != 3 {
+		t.Fatalf("len(pub.index) = %d, expected 3", len(pub.index))
+	}
+	index := pub.index
+
+	// Pages may not be modified, reordered, or dropped.
+	for _, tc := range []struct {
+		desc  string
+		index [][]byte
+	}{
+		{"modified page", [][]byte{index[0], append([]byte{index[1][0] ^ 1}, index[1][1:]...), index[2]}},
+		{"swapped pages", [][]byte{index[1], index[0], index[2]}},
+		{"dropped page", [][]byte{index[0], index[1]}},
+	} {
+		pub.index = tc.index
+		if _, _, err = priv.readIndex(pub, nil); err != ErrorBadIndex {
+			t.Errorf("%s: priv.readIndex() returns %v, expected %q", tc.desc, err, ErrorBadIndex)
+		}
+	}
+
+	// The index is bound to the store key.
+	pub.index = index
+	_, priv2, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer priv2.Close()
+	if _, _, err = priv2.readIndex(pub, nil); err != ErrorBadIndex {
+		t.Errorf("priv2.readIndex() returns %v, expected %q", err, ErrorBadIndex)
+	}
+}
+
+// Test that an update of a store with an index writes UpdateCover sealed
+// outputs, or all of them if there are fewer.
+func TestUpdateCover(t *testing.T) {
+	for _, M := range []map[string]string{bigM(100), goodM} {
+		pub, priv, err := NewStore(GenerateKey(), M, WithIndex(), WithPadding(16))
+		if err != nil {
+			t.Fatalf("NewStore() fails: %s", err)
+		}
+		expected := UpdateCover
+		if len(M) < expected {
+			expected = len(M)
+		}
+
+		M = copyMap(M)
+		for in := range M {
+			update, err := priv.Update(pub, in, "updated")
+			if err != nil {
+				t.Fatalf("priv.Update() fails: %s", err)
+			}
+			AssertIntEqError(t, "len(update.Sealed)", len(update.GetSealed()), expected)
+			x, y, _ := priv.GetIdx(in)
+			found := false
+			for _, e := range update.GetSealedIdx() {
+				found = found || int(e) == pub.g.edge(x, y)
+			}
+			if !found {
+				t.Errorf("the update doesn't write the sealed output of %q", in)
+			}
+			applyUpdate(t, pub, &priv, update)
+			M[in] = "updated"
+			break
+		}
+		for in := range M {
+			update, err := priv.Delete(pub, in)
+			if err != nil {
+				t.Fatalf("priv.Delete() fails: %s", err)
+			}
+			AssertIntEqError(t, "len(update.Sealed)", len(update.GetSealed()), expected)
+			applyUpdate(t, pub, &priv, update)
+			delete(M, in)
+			break
+		}
+		checkStore(t, pub, priv, M)
+		if got, err := readMap(priv, pub); err != nil || !reflect.DeepEqual(got, M) {
+			t.Errorf("priv.readIndex() = (%v, %v) after the updates", got, err)
+		}
+		pub.Close()
+		priv.Close()
+	}
+}
+
+// Test that the entries of deleted inputs are kept in the page, but are not
+// listed.
+func TestIndexDeleted(t *testing.T) {
+	_, priv, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer priv.Close()
+
+	entries := []indexEntry{{"hip", true}, {"this", false}, {"the", true}}
+	sealed, err := priv.sealIndexPage(0, entries)
+	if err != nil {
+		t.Fatalf("priv.sealIndexPage() fails: %s", err)
+	}
+	got, err := priv.openIndexPage(0, sealed)
+	if err != nil {
+		t.Fatalf("priv.openIndexPage() fails: %s", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("priv.openIndexPage() = %v, expected %v", got, entries)
+	}
+	inputs, err := priv.OpenIndexPage(0, sealed)
+	if err != nil {
+		t.Fatalf("priv.OpenIndexPage() fails: %s", err)
+	}
+	if !reflect.DeepEqual(inputs, []string{"hip", "the"}) {
+		t.Errorf("priv.OpenIndexPage() = %q, expected [\"hip\" \"the\"]", inputs)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	// No input is reserved, and inputs may contain newlines.
+	M := bigM(IndexPageSize + 1)
+	M["ls"] = "not a listing"
+	M["two\nlines"] = "fine"
+	pub, priv, err := NewStore(GenerateKey(), M, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+
+	update, err := priv.Delete(pub, "input 0")
+	if err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	delete(M, "input 0")
+
+	keys, err := priv.Keys(pub)
+	if err != nil {
+		t.Fatalf("priv.Keys() fails: %s", err)
+	}
+	if len(keys) != len(M) {
+		t.Errorf("len(priv.Keys()) = %d, expected %d", len(keys), len(M))
+	}
+	for _, in := range keys {
+		if _, ok := M[in]; !ok {
+			t.Errorf("priv.Keys() lists %q, which is not in the map", in)
+		}
+	}
+
+	// List stops at the first error.
+	stop := Error("stop")
+	n := 0
+	if err = priv.List(pub, func(string) error { n++; return stop }); err != stop || n != 1 {
+		t.Errorf("priv.List() = %v after %d calls, expected %q after 1", err, n, stop)
+	}
+
+	// The pages may be fetched one at a time.
+	var paged []string
+	for page := 0; ; page++ {
+		sealed, pageCt, err := pub.GetIndexPage(page)
+		if err == ErrorIdx {
+			if page != pageCt || pageCt != 2 {
+				t.Errorf("pub.GetIndexPage(%d) returns %d pages, expected 2", page, pageCt)
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("pub.GetIndexPage(%d) fails: %s", page, err)
+		}
+		inputs, err := priv.OpenIndexPage(page, sealed)
+		if err != nil {
+			t.Fatalf("priv.OpenIndexPage(%d) fails: %s", page, err)
+		}
+		paged = append(paged, inputs...)
+		if _, err = priv.OpenIndexPage(page+1, sealed); err != ErrorBadIndex {
+			t.Errorf("priv.OpenIndexPage() returns %v for the wrong page, expected %q", err, ErrorBadIndex)
+		}
+	}
+	if !reflect.DeepEqual(paged, keys) {
+		t.Error("the pages don't match priv.Keys()")
+	}
+
+	// A store without an index.
+	pub2, priv2, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub2.Close()
+	defer priv2.Close()
+	if _, err = priv2.Keys(pub2); err != ErrorNoIndex {
+		t.Errorf("priv.Keys() returns %v without an index, expected %q", err, ErrorNoIndex)
+	}
+	if _, _, err = pub2.GetIndexPage(0); err != ErrorNoIndex {
+		t.Errorf("pub.GetIndexPage() returns %v without an index, expected %q", err, ErrorNoIndex)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Returned by NewStore() and DeriveKeyFromHeader() if the KDF header is
+// malformed or its costs are out of range.
+const ErrorBadKdf = Error("bad password KDF parameters")
+
+// Returned by DeriveKeyFromHeader() and policy.DeriveKey() if the costs of the
+// KDF header are below the minimum.
+const ErrorWeakKdf = Error("password KDF costs are too low")
+
+// Returned by policy.DeriveKey() if the KDF header is weaker than the header
+// pinned by the policy.
+const ErrorKdfDowngrade = Error("password KDF is weaker than the pinned one")
+
+// The length of the salt generated by NewKdfHeader().
+const KdfSaltBytes = 16
+
+// The default costs of each password KDF set by NewKdfHeader(). These follow the
+// recommendations of the documentation of each KDF.
+const (
+	DefaultPbkdf2Iterations = 600000
+
+	DefaultScryptLogN      = 15
+	DefaultScryptBlockSize = 8
+
+	DefaultArgon2Iterations  = 1
+	DefaultArgon2MemoryKib   = 64 * 1024
+	DefaultArgon2Parallelism = 4
+)
+
+// The default minimum costs of each password KDF accepted by
+// DeriveKeyFromHeader(). (See NewKdfPolicy().) These are well below the default
+// costs, but high enough that the derived key is not cheap to brute force.
+const (
+	MinPbkdf2Iterations = 100000
+
+	MinScryptLogN = 14
+
+	MinArgon2Iterations = 1
+	MinArgon2MemoryKib  = 19 * 1024
+)
+
+// The bounds on the costs accepted by DeriveKeyFromHeader(). The header is
+// served by the StoreProvider, so the bounds keep a misbehaving server from
+// making the client do an unreasonable amount of work.
+const (
+	kdfMinSaltBytes  = 8
+	kdfMaxSaltBytes  = 64
+	kdfMaxIterations = 1 << 24
+	kdfMaxMemoryKib  = 1 << 20 // 1 GiB
+	kdfMaxThreads    = 255
+	kdfMaxBlockSize  = 64
+)
+
+// NewKdfHeader returns a header for the password KDF with a fresh, random salt
+// and the default costs. The costs may be tuned by changing the fields of the
+// header before it is used.
+func NewKdfHeader(kdf pb.PasswordKdf) (*pb.KdfHeader, error) {
+	header := &pb.KdfHeader{
+		Kdf:  kdf,
+		Salt: make([]byte, KdfSaltBytes),
+	}
+	switch kdf {
+	case pb.PasswordKdf_PBKDF2_SHA256:
+		header.Iterations = DefaultPbkdf2Iterations
+	case pb.PasswordKdf_SCRYPT:
+		header.LogN = DefaultScryptLogN
+		header.BlockSize = DefaultScryptBlockSize
+		header.Parallelism = 1
+	case pb.PasswordKdf_ARGON2ID:
+		header.Iterations = DefaultArgon2Iterations
+		header.MemoryKib = DefaultArgon2MemoryKib
+		header.Parallelism = DefaultArgon2Parallelism
+	default:
+		return nil, ErrorBadKdf
+	}
+	if _, err := rand.Read(header.Salt); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// checkKdfHeader returns ErrorBadKdf if the header is malformed or its costs
+// are out of range.
+func checkKdfHeader(header *pb.KdfHeader) error {
+	if header == nil ||
+		len(header.GetSalt()) < kdfMinSaltBytes || len(header.GetSalt()) > kdfMaxSaltBytes {
+		return ErrorBadKdf
+	}
+	switch header.GetKdf() {
+	case pb.PasswordKdf_PBKDF2_SHA256:
+		if header.GetIterations() < 1 || header.GetIterations() > kdfMaxIterations {
+			return ErrorBadKdf
+		}
+	case pb.PasswordKdf_SCRYPT:
+		// scrypt uses 128*r*N bytes of memory.
+		logN, r, p := header.GetLogN(), header.GetBlockSize(), header.GetParallelism()
+		if logN < 1 || logN > 30 || r < 1 || r > kdfMaxBlockSize ||
+			p < 1 || p > kdfMaxThreads ||
+			(int64(128*r)<<uint(logN))>>10 > kdfMaxMemoryKib {
+			return ErrorBadKdf
+		}
+	case pb.PasswordKdf_ARGON2ID:
+		t, m, p := header.GetIterations(), header.GetMemoryKib(), header.GetParallelism()
+		if t < 1 || t > kdfMaxIterations || p < 1 || p > kdfMaxThreads ||
+			m < 8*p || m > kdfMaxMemoryKib {
+			return ErrorBadKdf
+		}
+	default:
+		return ErrorBadKdf
+	}
+	return nil
+}
+
+// KdfPolicy is the client's policy for the KDF headers it accepts. The header
+// is served by the StoreProvider and is not authenticated, so a misbehaving
+// server could serve a header with trivial costs (e.g., one iteration of
+// PBKDF2), so that a key wrapped with the password is cheap to brute force. A
+// policy rejects headers whose costs are below the minimum, and, once a header
+// is pinned, headers that are weaker than the pinned one.
+//
+// The methods of KdfPolicy are safe for concurrent use.
+type KdfPolicy struct {
+	// The minimum costs of each KDF. See MinPbkdf2Iterations.
+	MinPbkdf2Iterations int32
+	MinScryptLogN       int32
+	MinArgon2Iterations int32
+	MinArgon2MemoryKib  int32
+
+	mu     sync.Mutex
+	pinned *pb.KdfHeader
+}
+
+// NewKdfPolicy returns a policy with the default minimum costs and no pinned
+// header.
+func NewKdfPolicy() *KdfPolicy {
+	return &KdfPolicy{
+		MinPbkdf2Iterations: MinPbkdf2Iterations,
+		MinScryptLogN:       MinScryptLogN,
+		MinArgon2Iterations: MinArgon2Iterations,
+		MinArgon2MemoryKib:  MinArgon2MemoryKib,
+	}
+}
+
+// Pin pins the header, e.g., one the client saved the first time it derived the
+// key. Thereafter, policy.DeriveKey() rejects headers that use a different KDF
+// or lower costs. The salt may change, since a header with the wrong salt
+// just derives the wrong key.
+func (policy *KdfPolicy) Pin(header *pb.KdfHeader) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	policy.pinned = proto.Clone(header).(*pb.KdfHeader)
+}
+
+// Pinned returns the pinned header, or nil if no header is pinned, so that the
+// client can save it.
+func (policy *KdfPolicy) Pinned() *pb.KdfHeader {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	if policy.pinned == nil {
+		return nil
+	}
+	return proto.Clone(policy.pinned).(*pb.KdfHeader)
+}
+
+// check returns ErrorBadKdf if the header is malformed and ErrorWeakKdf if its
+// costs are below the minimum. If pin is set, then it returns
+// ErrorKdfDowngrade if the header is weaker than the pinned header, and pins
+// the header if none is pinned.
+func (policy *KdfPolicy) check(header *pb.KdfHeader, pin bool) error {
+	if err := checkKdfHeader(header); err != nil {
+		return err
+	}
+	var weak bool
+	switch header.GetKdf() {
+	case pb.PasswordKdf_PBKDF2_SHA256:
+		weak = header.GetIterations() < policy.MinPbkdf2Iterations
+	case pb.PasswordKdf_SCRYPT:
+		weak = header.GetLogN() < policy.MinScryptLogN
+	case pb.PasswordKdf_ARGON2ID:
+		weak = header.GetIterations() < policy.MinArgon2Iterations ||
+			header.GetMemoryKib() < policy.MinArgon2MemoryKib
+	}
+	if weak {
+		return ErrorWeakKdf
+	} else if !pin {
+		return nil
+	}
+
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	if policy.pinned == nil {
+		policy.pinned = proto.Clone(header).(*pb.KdfHeader)
+		return nil
+	}
+	pinned := policy.pinned
+	if header.GetKdf() != pinned.GetKdf() ||
+		header.GetIterations() < pinned.GetIterations() ||
+		header.GetMemoryKib() < pinned.GetMemoryKib() ||
+		header.GetParallelism() < pinned.GetParallelism() ||
+		header.GetLogN() < pinned.GetLogN() ||
+		header.GetBlockSize() < pinned.GetBlockSize() {
+		return ErrorKdfDowngrade
+	}
+	return nil
+}
+
+// DeriveKey is the same as DeriveKeyFromHeader(), except that the header is
+// checked against the policy. It returns ErrorWeakKdf if the costs of the header
+// are below the minimum, and ErrorKdfDowngrade if the header is weaker than the
+// pinned header. If no header is pinned, then the header is pinned.
+func (policy *KdfPolicy) DeriveKey(password []byte, header *pb.KdfHeader) ([]byte, error) {
+	if err := policy.check(header, true); err != nil {
+		return nil, err
+	}
+	return deriveKeyFromHeader(password, header)
+}
+
+// DeriveKeyFromHeader derives a store key from a password using the KDF
+// described by the header. It returns ErrorBadKdf if the header is malformed,
+// and ErrorWeakKdf if its costs are below the default minimum. (See
+// NewKdfPolicy().)
+//
+// Unlike DeriveKeyFromPassword(), the KDF, its costs, and the salt are chosen
+// when the store is created and recorded in the store (see WithKdfHeader()), so
+// any client can derive the key from the password alone.
+func DeriveKeyFromHeader(password []byte, header *pb.KdfHeader) ([]byte, error) {
+	if err := NewKdfPolicy().check(header, false); err != nil {
+		return nil, err
+	}
+	return deriveKeyFromHeader(password, header)
+}
+
+// deriveKeyFromHeader derives the key from a header that has been checked.
+func deriveKeyFromHeader(password []byte, header *pb.KdfHeader) ([]byte, error) {
+	salt := header.GetSalt()
+	switch header.GetKdf() {
+	case pb.PasswordKdf_SCRYPT:
+		return scrypt.Key(password, salt, 1<<uint(header.GetLogN()),
+			int(header.GetBlockSize()), int(header.GetParallelism()), KeyBytes)
+	case pb.PasswordKdf_ARGON2ID:
+		return argon2.IDKey(password, salt, uint32(header.GetIterations()),
+			uint32(header.GetMemoryKib()), uint8(header.GetParallelism()), KeyBytes), nil
+	}
+	return pbkdf2.Key(password, salt, int(header.GetIterations()), KeyBytes, sha256.New), nil
+}
+
+// WithKdfHeader records the header of the KDF used to derive the store key
+// from the user's password. The header is public: the StoreProvider serves it
+// along with the parameters, so that the client can call DeriveKeyFromHeader()
+// without knowing the KDF in advance.
+func WithKdfHeader(header *pb.KdfHeader) Option {
+	return func(priv *PrivStore) {
+		priv.kdfHeader = header
+	}
+}
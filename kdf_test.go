@@ -0,0 +1,196 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// cheapKdfHeaders returns a header for each KDF with the minimum costs, so that
+// the tests are fast.
+func cheapKdfHeaders() []*pb.KdfHeader {
+	salt := []byte("0123456789abcdef")
+	return []*pb.KdfHeader{
+		{Kdf: pb.PasswordKdf_PBKDF2_SHA256, Salt: salt, Iterations: MinPbkdf2Iterations},
+		{Kdf: pb.PasswordKdf_SCRYPT, Salt: salt, LogN: MinScryptLogN, BlockSize: 1, Parallelism: 1},
+		{Kdf: pb.PasswordKdf_ARGON2ID, Salt: salt, Iterations: MinArgon2Iterations, MemoryKib: MinArgon2MemoryKib, Parallelism: 1},
+	}
+}
+
+func TestDeriveKeyFromHeader(t *testing.T) {
+	password := []byte("super secret password")
+	seen := make(map[string]bool)
+	for _, header := range cheapKdfHeaders() {
+		K, err := DeriveKeyFromHeader(password, header)
+		if err != nil {
+			t.Fatalf("DeriveKeyFromHeader(%s) fails: %s", header.GetKdf(), err)
+		}
+		if len(K) != KeyBytes {
+			t.Errorf("%s: len(K) = %d, expected %d", header.GetKdf(), len(K), KeyBytes)
+		}
+		if seen[string(K)] {
+			t.Errorf("%s derives the same key as another KDF", header.GetKdf())
+		}
+		seen[string(K)] = true
+
+		// The key depends only on the password and the header.
+		K2, err := DeriveKeyFromHeader(password, proto.Clone(header).(*pb.KdfHeader))
+		if err != nil || !bytes.Equal(K, K2) {
+			t.Errorf("%s: DeriveKeyFromHeader() isn't deterministic", header.GetKdf())
+		}
+		if K2, _ = DeriveKeyFromHeader([]byte("wrong password"), header); bytes.Equal(K, K2) {
+			t.Errorf("%s: the key doesn't depend on the password", header.GetKdf())
+		}
+		header.Salt = []byte("fedcba9876543210")
+		if K2, _ = DeriveKeyFromHeader(password, header); bytes.Equal(K, K2) {
+			t.Errorf("%s: the key doesn't depend on the salt", header.GetKdf())
+		}
+	}
+}
+
+func TestNewKdfHeader(t *testing.T) {
+	for kdf := range pb.PasswordKdf_name {
+		header, err := NewKdfHeader(pb.PasswordKdf(kdf))
+		if err != nil {
+			t.Fatalf("NewKdfHeader(%s) fails: %s", pb.PasswordKdf(kdf), err)
+		}
+		if err = checkKdfHeader(header); err != nil {
+			t.Errorf("NewKdfHeader(%s) returns a bad header: %s", pb.PasswordKdf(kdf), err)
+		}
+		header2, _ := NewKdfHeader(pb.PasswordKdf(kdf))
+		if len(header.GetSalt()) != KdfSaltBytes || bytes.Equal(header.GetSalt(), header2.GetSalt()) {
+			t.Errorf("NewKdfHeader(%s) doesn't generate a fresh salt", pb.PasswordKdf(kdf))
+		}
+	}
+	if _, err := NewKdfHeader(pb.PasswordKdf(100)); err != ErrorBadKdf {
+		t.Errorf("NewKdfHeader() returns %v for an unknown KDF, expected %q", err, ErrorBadKdf)
+	}
+}
+
+func TestBadKdfHeader(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	for i, header := range []*pb.KdfHeader{
+		nil,
+		{Kdf: pb.PasswordKdf_PBKDF2_SHA256, Salt: salt[:4], Iterations: 1000},
+		{Kdf: pb.PasswordKdf_PBKDF2_SHA256, Salt: salt},
+		{Kdf: pb.PasswordKdf_SCRYPT, Salt: salt, LogN: 30, BlockSize: 8, Parallelism: 1},
+		{Kdf: pb.PasswordKdf_SCRYPT, Salt: salt, LogN: 10, Parallelism: 1},
+		{Kdf: pb.PasswordKdf_ARGON2ID, Salt: salt, Iterations: 1, MemoryKib: 1 << 30, Parallelism: 1},
+		{Kdf: pb.PasswordKdf_ARGON2ID, Salt: salt, Iterations: 1, MemoryKib: 1024},
+		{Kdf: pb.PasswordKdf(100), Salt: salt, Iterations: 1000},
+	} {
+		if _, err := DeriveKeyFromHeader([]byte("password"), header); err != ErrorBadKdf {
+			t.Errorf("header %d: DeriveKeyFromHeader() returns %v, expected %q", i, err, ErrorBadKdf)
+		}
+	}
+}
+
+func TestWeakKdfHeader(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	for i, header := range []*pb.KdfHeader{
+		{Kdf: pb.PasswordKdf_PBKDF2_SHA256, Salt: salt, Iterations: 1},
+		{Kdf: pb.PasswordKdf_SCRYPT, Salt: salt, LogN: 1, BlockSize: 8, Parallelism: 1},
+		{Kdf: pb.PasswordKdf_ARGON2ID, Salt: salt, Iterations: 1, MemoryKib: 8, Parallelism: 1},
+	} {
+		if _, err := DeriveKeyFromHeader([]byte("password"), header); err != ErrorWeakKdf {
+			t.Errorf("header %d: DeriveKeyFromHeader() returns %v, expected %q", i, err, ErrorWeakKdf)
+		}
+	}
+
+	// The minimum costs may be changed by the caller.
+	policy := NewKdfPolicy()
+	policy.MinPbkdf2Iterations = 1
+	if _, err := policy.DeriveKey([]byte("password"), &pb.KdfHeader{
+		Kdf: pb.PasswordKdf_PBKDF2_SHA256, Salt: salt, Iterations: 1,
+	}); err != nil {
+		t.Errorf("policy.DeriveKey() fails: %s", err)
+	}
+}
+
+func TestKdfPolicyPin(t *testing.T) {
+	headers := cheapKdfHeaders()
+	policy := NewKdfPolicy()
+	if policy.Pinned() != nil {
+		t.Fatal("policy.Pinned() != nil for a new policy")
+	}
+	strong := proto.Clone(headers[0]).(*pb.KdfHeader)
+	strong.Iterations *= 2
+	K, err := policy.DeriveKey([]byte("password"), strong)
+	if err != nil {
+		t.Fatalf("policy.DeriveKey() fails: %s", err)
+	}
+	if !proto.Equal(policy.Pinned(), strong) {
+		t.Errorf("policy.Pinned() = %v, expected %v", policy.Pinned(), strong)
+	}
+
+	// Weaker headers, or headers for another KDF, are rejected.
+	for i, header := range headers {
+		if _, err = policy.DeriveKey([]byte("password"), header); err != ErrorKdfDowngrade {
+			t.Errorf("header %d: policy.DeriveKey() returns %v, expected %q", i, err, ErrorKdfDowngrade)
+		}
+	}
+	if K2, err := policy.DeriveKey([]byte("password"), strong); err != nil || !bytes.Equal(K, K2) {
+		t.Errorf("policy.DeriveKey() = (%x, %v), expected (%x, nil)", K2, err, K)
+	}
+
+	// A saved header may be pinned.
+	policy = NewKdfPolicy()
+	policy.Pin(strong)
+	if _, err = policy.DeriveKey([]byte("password"), headers[0]); err != ErrorKdfDowngrade {
+		t.Errorf("policy.DeriveKey() returns %v, expected %q", err, ErrorKdfDowngrade)
+	}
+}
+
+func TestStoreKdfHeader(t *testing.T) {
+	header := cheapKdfHeaders()[2]
+	K, err := DeriveKeyFromHeader([]byte("password"), header)
+	if err != nil {
+		t.Fatalf("DeriveKeyFromHeader() fails: %s", err)
+	}
+	pub, priv, err := NewStore(K, goodM, WithKdfHeader(header))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	// The header is stored next to the parameters.
+	table := pub.GetProto()
+	if !proto.Equal(table.GetKdfHeader(), header) {
+		t.Errorf("pub.GetProto().GetKdfHeader() = %v, expected %v", table.GetKdfHeader(), header)
+	}
+	if err = ValidateStoreProto(table); err != nil {
+		t.Errorf("ValidateStoreProto() fails: %s", err)
+	}
+	pub2 := NewPubStoreFromProto(table)
+	defer pub2.Close()
+	if !proto.Equal(pub2.GetProto().GetKdfHeader(), header) {
+		t.Error("the header doesn't survive serialization")
+	}
+
+	// The client re-derives the key from the password and the header.
+	K2, err := DeriveKeyFromHeader([]byte("password"), table.GetKdfHeader())
+	if err != nil {
+		t.Fatalf("DeriveKeyFromHeader() fails: %s", err)
+	}
+	priv2, err := NewPrivStore(K2, table.GetDict().GetParams(), 0)
+	if err != nil {
+		t.Fatalf("NewPrivStore() fails: %s", err)
+	}
+	defer priv2.Close()
+	checkStore(t, pub2, priv2, goodM)
+
+	// Bad headers are rejected.
+	table.KdfHeader.Iterations = 0
+	if err = ValidateStoreProto(table); err != ErrorBadStore {
+		t.Errorf("ValidateStoreProto() returns %v for a bad header, expected %q", err, ErrorBadStore)
+	}
+	if _, _, err = NewStore(K, goodM, WithKdfHeader(table.GetKdfHeader())); err != ErrorBadKdf {
+		t.Errorf("NewStore() returns %v for a bad header, expected %q", err, ErrorBadKdf)
+	}
+}
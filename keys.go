@@ -0,0 +1,135 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+
+	"github.com/cjpatton/store/pb"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Returned by NewStore() and NewPrivStore() if the key schedule is not
+// supported.
+const ErrorKeySchedule = Error("unsupported key schedule")
+
+// Returned by NewStore() and NewPrivStore() if the store key is shorter than
+// KeyBytes.
+const ErrorKeyLength = Error("store key is too short")
+
+// The key schedule used by NewStore() unless WithKeySchedule() is passed.
+const DefaultKeySchedule = pb.KeySchedule_HKDF_SHA256_AES128
+
+// The labels of the keys derived from the store key. Each is prefixed by the
+// name of the key schedule, so that the schedules derive independent keys.
+const (
+	prfLabel    = "prf"
+	aeadLabel   = "aead"
+	commitLabel = "commitment"
+	indexLabel  = "index"
+)
+
+// WithKeySchedule sets how the keys of the store are derived from the store
+// key. The default is DefaultKeySchedule; pass
+// WithKeySchedule(pb.KeySchedule_HKDF_SHA256_AES256) to seal the outputs with
+// AES-256-GCM. The key schedule is recorded in the parameters, so
+// NewPrivStore() picks it up automatically.
+//
+// Under every schedule, the key of the dictionary is DictKeyBytes (16) long,
+// since this is the key length of the HMAC used by the dictionary (see
+// NewPrivDict()). Hence, HKDF_SHA256_AES256 strengthens the encryption of the
+// outputs and the index, but not the mapping of inputs to rows.
+func WithKeySchedule(schedule pb.KeySchedule) Option {
+	return func(priv *PrivStore) {
+		priv.schedule = schedule
+	}
+}
+
+// storeKeys are the keys derived from the store key.
+type storeKeys struct {
+	prf    []byte // The key of the dictionary.
+	aead   []byte // The key for sealing the outputs.
+	commit []byte // The key of the MAC of the parameters. (See WithVersion().)
+	index  []byte // The key for sealing the index of the inputs. (See WithIndex().)
+}
+
+// deriveKeys derives the keys of the store from K using the key schedule.
+//
+// The legacy schedule splits K into the key of the AEAD and the key of the
+// dictionary, and derives the keys of the MAC and the index with HMAC-SHA256.
+// The other schedules derive each key with HKDF-SHA256 (RFC 5869), whose info
+// string is the name of the schedule followed by the label of the key. The key
+// of the dictionary is always DictKeyBytes long. (See WithKeySchedule().)
+func deriveKeys(K []byte, schedule pb.KeySchedule) (*storeKeys, error) {
+	if len(K) < KeyBytes {
+		return nil, ErrorKeyLength
+	}
+	switch schedule {
+	case pb.KeySchedule_LEGACY_SPLIT:
+		mac := hmac.New(sha256.New, K)
+		mac.Write([]byte("store params mac"))
+		index := hmac.New(sha256.New, K)
+		index.Write([]byte("store index"))
+		return &storeKeys{
+			prf:    K[DictKeyBytes:KeyBytes],
+			aead:   K[:DictKeyBytes],
+			commit: mac.Sum(nil),
+			index:  index.Sum(nil)[:SealKeyBytes],
+		}, nil
+	case pb.KeySchedule_HKDF_SHA256_AES128, pb.KeySchedule_HKDF_SHA256_AES256:
+		aeadBytes := SealKeyBytes
+		if schedule == pb.KeySchedule_HKDF_SHA256_AES256 {
+			aeadBytes = SealKey256Bytes
+		}
+		keys := new(storeKeys)
+		for _, k := range []struct {
+			key   *[]byte
+			label string
+			len   int
+		}{
+			{&keys.prf, prfLabel, DictKeyBytes},
+			{&keys.aead, aeadLabel, aeadBytes},
+			{&keys.commit, commitLabel, sha256.Size},
+			{&keys.index, indexLabel, aeadBytes},
+		} {
+			*k.key = make([]byte, k.len)
+			info := []byte(schedule.String() + " " + k.label)
+			if _, err := io.ReadFull(hkdf.New(sha256.New, K, nil, info), *k.key); err != nil {
+				return nil, err
+			}
+		}
+		return keys, nil
+	}
+	return nil, ErrorKeySchedule
+}
+
+// setKeys derives the keys of the store from K using priv.schedule and sets up
+// the AEADs of the outputs and the index. It sets priv.keys and returns them.
+func (priv *PrivStore) setKeys(K []byte) (*storeKeys, error) {
+	keys, err := deriveKeys(K, priv.schedule)
+	if err != nil {
+		return nil, err
+	}
+	priv.keys = keys
+	if priv.aead, err = newGCM(keys.aead); err != nil {
+		return nil, err
+	}
+	if priv.indexAead, err = newGCM(keys.index); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// newGCM returns AES-GCM keyed by key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+)
+
+func TestKeySchedule(t *testing.T) {
+	K := GenerateKey()
+	for _, schedule := range []pb.KeySchedule{
+		pb.KeySchedule_LEGACY_SPLIT,
+		pb.KeySchedule_HKDF_SHA256_AES128,
+		pb.KeySchedule_HKDF_SHA256_AES256,
+	} {
+		pub, priv, err := NewStore(K, goodM, WithKeySchedule(schedule))
+		if err != nil {
+			t.Fatalf("NewStore(%s) fails: %s", schedule, err)
+		}
+		checkStore(t, pub, priv, goodM)
+
+		// The key schedule is recorded in the parameters.
+		params := pub.GetProto().GetDict().GetParams()
+		if params.GetKeySchedule() != schedule {
+			t.Errorf("params.GetKeySchedule() = %s, expected %s", params.GetKeySchedule(), schedule)
+		}
+		priv2, err := NewPrivStore(K, params, 0)
+		if err != nil {
+			t.Fatalf("NewPrivStore(%s) fails: %s", schedule, err)
+		}
+		checkStore(t, pub, priv2, goodM)
+		priv2.Close()
+
+		// Each schedule derives different keys, so the schedule can't be
+		// changed.
+		for _, other := range []pb.KeySchedule{
+			pb.KeySchedule_LEGACY_SPLIT,
+			pb.KeySchedule_HKDF_SHA256_AES128,
+			pb.KeySchedule_HKDF_SHA256_AES256,
+		} {
+			if other == schedule {
+				continue
+			}
+			params.KeySchedule = other
+			if _, err = NewPrivStore(K, params, 0); err != ErrorBadParams {
+				t.Errorf("NewPrivStore() returns %v for %s changed to %s, expected %q", err, schedule, other, ErrorBadParams)
+			}
+		}
+		pub.Close()
+		priv.Close()
+	}
+}
+
+func TestDefaultKeySchedule(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if schedule := priv.GetParams().GetKeySchedule(); schedule != DefaultKeySchedule {
+		t.Errorf("priv.GetParams().GetKeySchedule() = %s, expected %s", schedule, DefaultKeySchedule)
+	}
+}
+
+func TestLegacyKeySchedule(t *testing.T) {
+	// The legacy schedule splits the key, so stores created before key
+	// schedules were supported still open.
+	K := GenerateKey()
+	keys, err := deriveKeys(K, pb.KeySchedule_LEGACY_SPLIT)
+	if err != nil {
+		t.Fatalf("deriveKeys() fails: %s", err)
+	}
+	if !bytes.Equal(keys.aead, K[:DictKeyBytes]) || !bytes.Equal(keys.prf, K[DictKeyBytes:]) {
+		t.Error("the legacy schedule doesn't split the key")
+	}
+
+	pub, priv, err := NewStore(K, goodM, WithKeySchedule(pb.KeySchedule_LEGACY_SPLIT))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	dict, err := NewPrivDict(K[DictKeyBytes:], priv.GetParams())
+	if err != nil {
+		t.Fatalf("NewPrivDict() fails: %s", err)
+	}
+	defer dict.Close()
+	for in := range goodM {
+		x1, y1, _ := priv.GetIdx(in)
+		x2, y2, _ := dict.GetIdx(in)
+		if x1 != x2 || y1 != y2 {
+			t.Errorf("index of %q is (%d, %d), expected (%d, %d)", in, x1, y1, x2, y2)
+		}
+	}
+}
+
+func TestDeriveKeys(t *testing.T) {
+	K := GenerateKey()
+	keys128, err := deriveKeys(K, pb.KeySchedule_HKDF_SHA256_AES128)
+	if err != nil {
+		t.Fatalf("deriveKeys() fails: %s", err)
+	}
+	keys256, err := deriveKeys(K, pb.KeySchedule_HKDF_SHA256_AES256)
+	if err != nil {
+		t.Fatalf("deriveKeys() fails: %s", err)
+	}
+	if len(keys128.aead) != SealKeyBytes || len(keys256.aead) != SealKey256Bytes {
+		t.Errorf("AEAD keys have lengths %d and %d, expected %d and %d",
+			len(keys128.aead), len(keys256.aead), SealKeyBytes, SealKey256Bytes)
+	}
+	seen := make(map[string]bool)
+	for _, keys := range []*storeKeys{keys128, keys256} {
+		for _, key := range [][]byte{keys.prf, keys.aead, keys.commit, keys.index} {
+			if seen[string(key)] {
+				t.Errorf("key %x is derived twice", key)
+			}
+			seen[string(key)] = true
+		}
+	}
+
+	if _, err = deriveKeys(K[:KeyBytes-1], pb.KeySchedule_HKDF_SHA256_AES128); err != ErrorKeyLength {
+		t.Errorf("deriveKeys() returns %v for a short key, expected %q", err, ErrorKeyLength)
+	}
+	if _, _, err = NewStore(K, goodM, WithKeySchedule(pb.KeySchedule(100))); err != ErrorKeySchedule {
+		t.Errorf("NewStore() returns %v for an unknown schedule, expected %q", err, ErrorKeySchedule)
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Returned by priv.GetOutput() if the share doesn't match the commitment of the
+// store. This means the server misbehaved: it answered from a different (e.g.,
+// older) store, or it corrupted or withheld the sealed output. Unlike
+// ItemNotFound, it never means that the input is not in the map.
+const ErrorBadProof = Error("share does not match the commitment")
+
+// The length of the commitment of a store.
+const CommitmentBytes = sha256.Size
+
+// Prefixes of the inputs of the hash function, which separate its uses.
+const (
+	merkleLeaf = iota
+	merkleNode
+	merkleCommit
+)
+
+// merkleTree is a Merkle tree whose leaves are the rows of the table, followed
+// by the sealed outputs. The leaf of each row also contains the list of edges
+// incident to the row (see rowLeaf()). The number of leaves is padded to a power
+// of two. The first level consists of the hashes of the leaves, and the last
+// level consists of the root.
+type merkleTree [][][]byte
+
+// The hash of each padding leaf. This is not the hash of any string.
+var merkleEmpty = make([]byte, sha256.Size)
+
+// merkleLeafHash returns the hash of a leaf.
+func merkleLeafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeaf})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// merkleNodeHash returns the hash of a node with the given children.
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNode})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// newMerkleTree computes the Merkle tree of the leaves.
+func newMerkleTree(leaves [][]byte) merkleTree {
+	n := 1
+	for n < len(leaves) {
+		n <<= 1
+	}
+	level := make([][]byte, n)
+	for i := range level {
+		if i < len(leaves) {
+			level[i] = merkleLeafHash(leaves[i])
+		} else {
+			level[i] = merkleEmpty
+		}
+	}
+	tree := merkleTree{level}
+	for len(level) > 1 {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = merkleNodeHash(level[2*i], level[2*i+1])
+		}
+		tree = append(tree, next)
+		level = next
+	}
+	return tree
+}
+
+// size returns the (approximate) number of bytes of memory used by the tree.
+func (t merkleTree) size() int64 {
+	var n int64
+	for _, level := range t {
+		n += int64(len(level)) * (sha256.Size + 24) // The hash and its slice header.
+	}
+	return n
+}
+
+// merkleTreeSize returns the size of the tree with the given number of leaves,
+// without computing it. (See t.size().)
+func merkleTreeSize(leafCt int) int64 {
+	n := 1
+	for n < leafCt {
+		n <<= 1
+	}
+	return int64(2*n-1) * (sha256.Size + 24)
+}
+
+// root returns the root of the tree.
+func (t merkleTree) root() []byte {
+	return t[len(t)-1][0]
+}
+
+// path returns the authentication path of the leaf at position pos, i.e., the
+// sibling of each node on the path from the leaf to the root.
+func (t merkleTree) path(pos int) [][]byte {
+	path := make([][]byte, len(t)-1)
+	for i := range path {
+		path[i] = t[i][pos^1]
+		pos >>= 1
+	}
+	return path
+}
+
+// merkleRoot computes the root of the tree from a leaf, its position, and its
+// authentication path. It returns nil if the position is out of range.
+func merkleRoot(leaf []byte, pos int, path [][]byte) []byte {
+	if pos < 0 || pos >= 1<<uint(len(path)) {
+		return nil
+	}
+	h := merkleLeafHash(leaf)
+	for _, sibling := range path {
+		if pos&1 == 0 {
+			h = merkleNodeHash(h, sibling)
+		} else {
+			h = merkleNodeHash(sibling, h)
+		}
+		pos >>= 1
+	}
+	return h
+}
+
+// rowLeaf returns the leaf of a row of the table, which consists of the row and
+// the edges incident to it. This way a proof for rows x and y also proves
+// whether the edge (x, y) exists, and so whether the input is in the map.
+func rowLeaf(row []byte, adj []int32) []byte {
+	leaf := make([]byte, len(row)+4*len(adj))
+	copy(leaf, row)
+	for i, e := range adj {
+		binary.BigEndian.PutUint32(leaf[len(row)+4*i:], uint32(e))
+	}
+	return leaf
+}
+
+// commit computes the commitment of a store from its parameters and the root of
+// its Merkle tree.
+func commit(params *pb.Params, root []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleCommit})
+	writeParams(h, params)
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(params.GetMac())))
+	h.Write(buf[:])
+	h.Write(params.GetMac())
+	h.Write(root)
+	return h.Sum(nil)
+}
+
+// getTree returns the Merkle tree and the commitment of pub. They are computed
+// the first time they are needed after pub is created or updated. The caller
+// must hold pub.mu, and pub must not be closed.
+func (pub *PubStore) getTree() (merkleTree, []byte) {
+	pub.treeMu.Lock()
+	defer pub.treeMu.Unlock()
+	if pub.tree == nil {
+		pub.buildTree()
+	}
+	return pub.tree, pub.commitment
+}
+
+// resetTree discards the Merkle tree and the commitment of pub, so that they
+// are computed again from the current store. The caller must hold pub.mu for
+// writing.
+func (pub *PubStore) resetTree() {
+	pub.tree, pub.commitment = nil, nil
+}
+
+// buildTree computes the Merkle tree and the commitment of pub. The caller must
+// hold pub.mu and pub.treeMu.
+func (pub *PubStore) buildTree() {
+	table := pub.dict.getTable()
+	rowBytes := pub.dict.rowBytes()
+	tableLen := len(table) / rowBytes
+	leaves := make([][]byte, tableLen+len(pub.sealed))
+	for x := 0; x < tableLen; x++ {
+		var adj []int32
+		if x < len(pub.g) {
+			adj = pub.g[x]
+		}
+		leaves[x] = rowLeaf(table[x*rowBytes:(x+1)*rowBytes], adj)
+	}
+	copy(leaves[tableLen:], pub.sealed)
+	pub.tree = newMerkleTree(leaves)
+
+	params := pub.dict.getParams()
+	pub.setParams(params)
+	pub.commitment = commit(params, pub.tree.root())
+}
+
+// TreeSize returns the (approximate) number of bytes of memory used by the
+// Merkle tree of the store. The tree is computed the first time it is needed,
+// i.e., by pub.Commitment() or pub.GetVerifiableShare(), but its size is
+// counted from the start, so that a server can budget for it when the store is
+// loaded. It returns 0 if pub is closed.
+func (pub *PubStore) TreeSize() int64 {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return 0
+	}
+	return merkleTreeSize(len(pub.g) + len(pub.sealed))
+}
+
+// Commitment returns the commitment of the store. It is a hash of the
+// parameters, the rows of the table, and the sealed outputs, so it changes every
+// time the store is updated. It returns nil if pub is closed.
+//
+// The client should keep the commitment and pass it to priv.SetCommitment(),
+// so that it can verify that the server's shares come from this store.
+func (pub *PubStore) Commitment() []byte {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return nil
+	}
+	_, commitment := pub.getTree()
+	return append([]byte(nil), commitment...)
+}
+
+// GetVerifiableShare is like pub.GetShare(), except that the share carries a
+// proof that it was computed from the store with pub's commitment. (See
+// priv.SetCommitment().) Unlike pub.GetShare(), it computes the share even if
+// there is no sealed output for the index, in which case the share proves that
+// the input is not in the map.
+func (pub *PubStore) GetVerifiableShare(x, y int) ([]byte, error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	return pub.getVerifiableShare(x, y)
+}
+
+// getVerifiableShare is the same as GetVerifiableShare, except that the caller
+// must hold pub.mu.
+func (pub *PubStore) getVerifiableShare(x, y int) ([]byte, error) {
+	if pub.dict.closed() {
+		return nil, ErrorClosed
+	}
+	if x < 0 || x >= len(pub.g) || y < 0 || y >= len(pub.g) {
+		return nil, ErrorIdx
+	}
+	tree, _ := pub.getTree()
+	proof := &pb.ShareProof{
+		RowX:  pub.dict.getRow(x),
+		PathX: tree.path(x),
+		RowY:  pub.dict.getRow(y),
+		PathY: tree.path(y),
+		AdjX:  pub.g[x],
+		AdjY:  pub.g[y],
+	}
+	if e := pub.g.edge(x, y); e >= 0 {
+		proof.Found = true
+		proof.SealedIdx = int32(e)
+		proof.Sealed = pub.sealed[e]
+		proof.PathSealed = tree.path(len(pub.g) + e)
+	}
+	return proto.Marshal(proof)
+}
+
+// SetCommitment sets the commitment of the store, as computed by
+// pub.Commitment(). Once it is set, priv.GetOutput() accepts only shares
+// computed by pub.GetVerifiableShare() for the store with this commitment, and
+// returns ErrorBadProof otherwise.
+//
+// The commitment changes every time the store is updated, so the client should
+// set the new commitment after each update. SetCommitment must not be called
+// concurrently with the other methods of priv.
+func (priv *PrivStore) SetCommitment(commitment []byte) {
+	priv.commitment = append([]byte(nil), commitment...)
+}
+
+// Commitment returns the commitment set by priv.SetCommitment(), or nil if none
+// is set.
+func (priv *PrivStore) Commitment() []byte {
+	if priv.commitment == nil {
+		return nil
+	}
+	return append([]byte(nil), priv.commitment...)
+}
+
+// verifyOutput is like priv.getOutput(), except that pubShare is computed by
+// pub.GetVerifiableShare() and is verified against the commitment.
+//
+// The rows x and y are verified along with the edges incident to them. If there
+// is no edge (x, y), then the input is not in the map. Otherwise, the sealed
+// output of the edge is verified, and if it doesn't unseal, then the input is
+// not in the map. (The edge belongs to another input.) Every other outcome means
+// the server misbehaved.
+func (priv *PrivStore) verifyOutput(input string, pubShare []byte) (string, error) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return "", err
+	}
+	params := priv.GetParams()
+	proof := new(pb.ShareProof)
+	if err = proto.Unmarshal(pubShare, proof); err != nil {
+		return "", ErrorBadProof
+	}
+
+	rowBytes := int(params.GetRowBytes())
+	if len(proof.GetRowX()) != rowBytes || len(proof.GetRowY()) != rowBytes {
+		return "", ErrorBadProof
+	}
+	root := merkleRoot(rowLeaf(proof.GetRowX(), proof.GetAdjX()), x, proof.GetPathX())
+	if root == nil ||
+		!bytes.Equal(root, merkleRoot(rowLeaf(proof.GetRowY(), proof.GetAdjY()), y, proof.GetPathY())) ||
+		!bytes.Equal(commit(params, root), priv.commitment) {
+		return "", ErrorBadProof
+	}
+
+	// Find the edge (x, y) the same way pub.g.edge() does.
+	e := int32(graph{proof.GetAdjX(), proof.GetAdjY()}.edge(0, 1))
+	if e < 0 {
+		return "", ItemNotFound
+	}
+	pos := int(params.GetTableLen()) + int(e)
+	if !proof.GetFound() || proof.GetSealedIdx() != e ||
+		!bytes.Equal(root, merkleRoot(proof.GetSealed(), pos, proof.GetPathSealed())) {
+		return "", ErrorBadProof
+	}
+
+	share := make([]byte, rowBytes, rowBytes+len(proof.GetSealed()))
+	for i := range share {
+		share[i] = proof.GetRowX()[i] ^ proof.GetRowY()[i]
+	}
+	return priv.getOutput(input, append(share, proof.GetSealed()...))
+}
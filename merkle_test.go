@@ -0,0 +1,245 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestMerkleTree(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	tree := newMerkleTree(leaves)
+	if len(tree) != 4 {
+		t.Fatalf("tree has %d levels, expected 4", len(tree))
+	}
+	for pos, leaf := range leaves {
+		path := tree.path(pos)
+		if root := merkleRoot(leaf, pos, path); !bytes.Equal(root, tree.root()) {
+			t.Errorf("leaf %d doesn't match the root", pos)
+		}
+		if root := merkleRoot([]byte("f"), pos, path); bytes.Equal(root, tree.root()) {
+			t.Errorf("wrong leaf %d matches the root", pos)
+		}
+		if root := merkleRoot(leaf, pos^1, path); bytes.Equal(root, tree.root()) {
+			t.Errorf("leaf %d matches the root at the wrong position", pos)
+		}
+	}
+	if root := merkleRoot(leaves[0], 8, tree.path(0)); root != nil {
+		t.Error("merkleRoot() accepts a position out of range")
+	}
+}
+
+func TestCommitment(t *testing.T) {
+	K := GenerateKey()
+	pub, priv, err := NewStore(K, goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	commitment := pub.Commitment()
+	if len(commitment) != CommitmentBytes {
+		t.Fatalf("pub.Commitment() has length %d, expected %d", len(commitment), CommitmentBytes)
+	}
+
+	// The commitment doesn't depend on the representation of the store.
+	pub2 := NewPubStoreFromProto(pub.GetProto())
+	defer pub2.Close()
+	if !bytes.Equal(pub2.Commitment(), commitment) {
+		t.Error("commitment changes after serialization")
+	}
+	priv2, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams(), 0)
+	if err != nil {
+		t.Fatalf("NewPrivStore() fails: %s", err)
+	}
+	defer priv2.Close()
+
+	for _, priv := range []*PrivStore{priv, priv2} {
+		priv.SetCommitment(commitment)
+		for in, out := range goodM {
+			if output, err := priv.Get(pub2, in); err != nil || output != out {
+				t.Errorf("priv.Get(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+			}
+		}
+		if _, err := priv.Get(pub2, "not an input"); err != ItemNotFound {
+			t.Errorf("priv.Get() returns %v, expected %q", err, ItemNotFound)
+		}
+		outputs, errs := priv.GetMany(pub2, []string{"hip", "not an input"})
+		if errs[0] != nil || outputs[0] != goodM["hip"] || errs[1] != ItemNotFound {
+			t.Errorf("priv.GetMany() = (%q, %v)", outputs, errs)
+		}
+	}
+
+	// A share without a proof is rejected.
+	x, y, _ := priv.GetIdx("hip")
+	pubShare, err := pub.GetShare(x, y)
+	if err != nil {
+		t.Fatalf("pub.GetShare() fails: %s", err)
+	}
+	if _, err = priv.GetOutput("hip", pubShare); err != ErrorBadProof {
+		t.Errorf("priv.GetOutput() returns %v for an unverifiable share, expected %q", err, ErrorBadProof)
+	}
+}
+
+func TestCommitmentRollback(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	old := NewPubStoreFromProto(pub.GetProto())
+	defer old.Close()
+
+	update, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	if bytes.Equal(pub.Commitment(), old.Commitment()) {
+		t.Fatal("commitment doesn't change after an update")
+	}
+	priv.SetCommitment(pub.Commitment())
+
+	if output, err := priv.Get(pub, "hip"); err != nil || output != "burger" {
+		t.Errorf("priv.Get() = (%q, %v), expected (\"burger\", nil)", output, err)
+	}
+	for in := range goodM {
+		if _, err := priv.Get(old, in); err != ErrorBadProof {
+			t.Errorf("priv.Get(%q) returns %v for the old store, expected %q", in, err, ErrorBadProof)
+		}
+	}
+	if _, err := priv.Get(old, "not an input"); err != ErrorBadProof {
+		t.Errorf("priv.Get() returns %v for the old store, expected %q", err, ErrorBadProof)
+	}
+}
+
+func TestCommitmentTamper(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	priv.SetCommitment(pub.Commitment())
+
+	x, y, _ := priv.GetIdx("hip")
+	pubShare, err := pub.GetVerifiableShare(x, y)
+	if err != nil {
+		t.Fatalf("pub.GetVerifiableShare() fails: %s", err)
+	}
+	for i, tamper := range []func(*pb.ShareProof){
+		func(p *pb.ShareProof) { p.RowX[0] ^= 1 },
+		func(p *pb.ShareProof) { p.PathY[0][0] ^= 1 },
+		func(p *pb.ShareProof) { p.Sealed[0] ^= 1 },
+		func(p *pb.ShareProof) { p.SealedIdx++ },
+		func(p *pb.ShareProof) { p.PathSealed = p.PathSealed[1:] },
+		// The server claims the input is not in the map.
+		func(p *pb.ShareProof) { p.Found = false },
+		func(p *pb.ShareProof) { p.AdjX = nil },
+		func(p *pb.ShareProof) { p.AdjY = append(p.AdjY, 1000) },
+	} {
+		proof := new(pb.ShareProof)
+		if err = proto.Unmarshal(pubShare, proof); err != nil {
+			t.Fatalf("proto.Unmarshal() fails: %s", err)
+		}
+		tamper(proof)
+		bad, err := proto.Marshal(proof)
+		if err != nil {
+			t.Fatalf("proto.Marshal() fails: %s", err)
+		}
+		if _, err = priv.GetOutput("hip", bad); err != ErrorBadProof {
+			t.Errorf("tamper %d: priv.GetOutput() returns %v, expected %q", i, err, ErrorBadProof)
+		}
+	}
+}
+
+func TestNonMembership(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	priv.SetCommitment(pub.Commitment())
+
+	// Every input that is not in the map is proven to be absent, whether or
+	// not its index happens to be an edge of the graph.
+	for i := 0; i < 100; i++ {
+		in := fmt.Sprintf("not an input %d", i)
+		if _, err := priv.Get(pub, in); err != ItemNotFound {
+			t.Errorf("priv.Get(%q) returns %v, expected %q", in, err, ItemNotFound)
+		}
+	}
+
+	// A deleted input is absent, too.
+	update, err := priv.Delete(pub, "hip")
+	if err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	priv.SetCommitment(pub.Commitment())
+	if _, err := priv.Get(pub, "hip"); err != ItemNotFound {
+		t.Errorf("priv.Get(\"hip\") returns %v after deletion, expected %q", err, ItemNotFound)
+	}
+}
+
+// Test that the Merkle tree is computed on first use and again after each
+// update. Run with "go test -race".
+func TestCommitmentLazy(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	if pub.tree != nil {
+		t.Error("the tree is computed before it is needed")
+	}
+
+	// The tree may be computed by concurrent requests.
+	priv.SetCommitment(pub.Commitment())
+	pub2 := NewPubStoreFromProto(pub.GetProto())
+	defer pub2.Close()
+	size := pub2.TreeSize()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for in, out := range goodM {
+				if output, err := priv.Get(pub2, in); err != nil || output != out {
+					t.Errorf("priv.Get(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if pub2.tree == nil || pub2.tree.size() != size {
+		t.Errorf("pub.TreeSize() = %d, expected the size of the tree", size)
+	}
+
+	commitment := pub.Commitment()
+	update, err := priv.Update(pub, "hip", "hooray")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	if pub.tree != nil {
+		t.Error("the tree is not discarded by pub.ApplyUpdate()")
+	}
+	if bytes.Equal(pub.Commitment(), commitment) {
+		t.Error("the commitment doesn't change after an update")
+	}
+	priv.SetCommitment(pub.Commitment())
+	if output, err := priv.Get(pub, "hip"); err != nil || output != "hooray" {
+		t.Errorf("priv.Get(\"hip\") = (%q, %v), expected (\"hooray\", nil)", output, err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/rand"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+// The OPRF used for password hardening. This is the base mode of
+// OPRF(P-256, SHA-256) specified in RFC 9497.
+var oprfSuite = oprf.SuiteP256
+
+// Length of the OPRF key.
+const OprfKeyBytes = 32
+
+// Returned by OprfServer.Evaluate() and PasswordBlind.Finalize() if the input
+// is not a valid group element.
+const ErrorOprfInput = Error("invalid OPRF input")
+
+// Returned by NewOprfServer() if the key is malformed.
+const ErrorOprfKey = Error("bad OPRF key")
+
+// GenerateOprfKey generates a fresh, random OPRF key and returns it. The key
+// is held by the StoreProvider and is used to harden the client's password.
+func GenerateOprfKey() []byte {
+	key, err := oprf.GenerateKey(oprfSuite, rand.Reader)
+	if err != nil {
+		return nil
+	}
+	K, err := key.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return K
+}
+
+// OprfServer evaluates the OPRF on blinded passwords.
+//
+// The point of the OPRF is that the key derived from the password depends on a
+// secret held by the server. Since the password is blinded, the server learns
+// nothing about it. An attacker who gets the public store must interact with
+// the server in order to test each guess of the password, which means the
+// server can rate-limit these guesses.
+type OprfServer struct {
+	server oprf.Server
+}
+
+// NewOprfServer creates a new OPRF server from a key generated by
+// GenerateOprfKey().
+func NewOprfServer(K []byte) (*OprfServer, error) {
+	if len(K) != OprfKeyBytes {
+		return nil, ErrorOprfKey
+	}
+	key := new(oprf.PrivateKey)
+	if err := key.UnmarshalBinary(oprfSuite, K); err != nil {
+		return nil, ErrorOprfKey
+	}
+	return &OprfServer{oprf.NewServer(oprfSuite, key)}, nil
+}
+
+// Evaluate evaluates the OPRF on a blinded password.
+func (s *OprfServer) Evaluate(blinded []byte) ([]byte, error) {
+	elem := oprfSuite.Group().NewElement()
+	if err := elem.UnmarshalBinary(blinded); err != nil {
+		return nil, ErrorOprfInput
+	}
+	eval, err := s.server.Evaluate(&oprf.EvaluationRequest{Elements: []oprf.Blinded{elem}})
+	if err != nil {
+		return nil, err
+	}
+	return eval.Elements[0].MarshalBinaryCompress()
+}
+
+// DeriveKey derives the key for the password and (optional) salt. The result is
+// the same as client.DeriveKeyFromProvider() for a provider that holds the OPRF key.
+// This is useful for creating a store without interacting with the provider.
+func (s *OprfServer) DeriveKey(password, salt []byte) ([]byte, error) {
+	out, err := s.server.FullEvaluate(password)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveKeyFromPassword(out, salt), nil
+}
+
+// PasswordBlind stores the state of the client between blinding the password
+// and finalizing the OPRF evaluation.
+type PasswordBlind struct {
+	client  oprf.Client
+	finData *oprf.FinalizeData
+	salt    []byte
+}
+
+// BlindPassword blinds the password for evaluation by the server. The salt
+// (which may be nil) plays the same role as in DeriveKeyFromPassword().
+func BlindPassword(password, salt []byte) (*PasswordBlind, []byte, error) {
+	client := oprf.NewClient(oprfSuite)
+	finData, req, err := client.Blind([][]byte{password})
+	if err != nil {
+		return nil, nil, err
+	}
+	blinded, err := req.Elements[0].MarshalBinaryCompress()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &PasswordBlind{client, finData, salt}, blinded, nil
+}
+
+// Finalize computes the key from the server's evaluation of the blinded
+// password. The result is a key for NewPrivStore().
+func (b *PasswordBlind) Finalize(evaluated []byte) ([]byte, error) {
+	elem := oprfSuite.Group().NewElement()
+	if err := elem.UnmarshalBinary(evaluated); err != nil {
+		return nil, ErrorOprfInput
+	}
+	outputs, err := b.client.Finalize(b.finData,
+		&oprf.Evaluation{Elements: []oprf.Evaluated{elem}})
+	if err != nil {
+		return nil, err
+	}
+	return DeriveKeyFromPassword(outputs[0], b.salt), nil
+}
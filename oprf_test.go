@@ -0,0 +1,83 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOprf(t *testing.T) {
+	server, err := NewOprfServer(GenerateOprfKey())
+	if err != nil {
+		t.Fatalf("NewOprfServer() fails: %s", err)
+	}
+	password, salt := []byte("this is a password"), []byte("salt")
+
+	expected, err := server.DeriveKey(password, salt)
+	if err != nil {
+		t.Fatalf("server.DeriveKey() fails: %s", err)
+	}
+	AssertIntEqError(t, "len(K)", len(expected), KeyBytes)
+
+	blind, blinded, err := BlindPassword(password, salt)
+	if err != nil {
+		t.Fatalf("BlindPassword() fails: %s", err)
+	}
+	evaluated, err := server.Evaluate(blinded)
+	if err != nil {
+		t.Fatalf("server.Evaluate() fails: %s", err)
+	}
+	K, err := blind.Finalize(evaluated)
+	if err != nil {
+		t.Fatalf("blind.Finalize() fails: %s", err)
+	}
+	if !bytes.Equal(K, expected) {
+		t.Errorf("blind.Finalize() = %x, expected %x", K, expected)
+	}
+
+	// The key depends on the password.
+	K, err = server.DeriveKey([]byte("this is not the password"), salt)
+	if err != nil {
+		t.Fatalf("server.DeriveKey() fails: %s", err)
+	}
+	if bytes.Equal(K, expected) {
+		t.Error("server.DeriveKey() returns the same key for different passwords")
+	}
+
+	// The key depends on the OPRF key.
+	server2, err := NewOprfServer(GenerateOprfKey())
+	if err != nil {
+		t.Fatalf("NewOprfServer() fails: %s", err)
+	}
+	K, err = server2.DeriveKey(password, salt)
+	if err != nil {
+		t.Fatalf("server2.DeriveKey() fails: %s", err)
+	}
+	if bytes.Equal(K, expected) {
+		t.Error("server2.DeriveKey() returns the same key for different OPRF keys")
+	}
+}
+
+func TestOprfBadInput(t *testing.T) {
+	server, err := NewOprfServer(GenerateOprfKey())
+	if err != nil {
+		t.Fatalf("NewOprfServer() fails: %s", err)
+	}
+	if _, err = server.Evaluate([]byte("not a point")); err != ErrorOprfInput {
+		t.Errorf("server.Evaluate() returns %v, expected %q", err, ErrorOprfInput)
+	}
+
+	blind, _, err := BlindPassword([]byte("password"), nil)
+	if err != nil {
+		t.Fatalf("BlindPassword() fails: %s", err)
+	}
+	if _, err = blind.Finalize(nil); err != ErrorOprfInput {
+		t.Errorf("blind.Finalize() returns %v, expected %q", err, ErrorOprfInput)
+	}
+
+	if _, err = NewOprfServer([]byte("bad key")); err != ErrorOprfKey {
+		t.Errorf("NewOprfServer() returns %v, expected %q", err, ErrorOprfKey)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"encoding/binary"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// Returned by NewStore(), priv.Insert(), and priv.Update() if an output is too
+// long to be padded to the length specified by WithPadding().
+const ErrorOutputTooLong = Error("output is too long for the padding")
+
+// Returned by NewStore() and NewPrivStore() if the padding parameters are
+// invalid.
+const ErrorBadPadding = Error("bad padding parameters")
+
+// The first byte of the padding. The remaining bytes are 0.
+const outputPadByte = 0x80
+
+// An Option configures the store created by NewStore().
+type Option func(*PrivStore)
+
+// WithPadding pads each output to n bytes before it is sealed, so that every
+// sealed output has the same length. Each output must be shorter than n bytes.
+func WithPadding(n int) Option {
+	return func(priv *PrivStore) {
+		priv.padding = pb.OutputPadding_FIXED
+		priv.paddedBytes = n
+	}
+}
+
+// WithBucketPadding pads each output to the next power of two before it is
+// sealed, so that the length of the sealed output leaks only the logarithm of
+// the length of the output.
+func WithBucketPadding() Option {
+	return func(priv *PrivStore) {
+		priv.padding = pb.OutputPadding_POWER_OF_TWO
+		priv.paddedBytes = 0
+	}
+}
+
+// setPadding sets the padding of the outputs from params.
+func (priv *PrivStore) setPadding(params *pb.Params) {
+	priv.padding = params.GetOutputPadding()
+	priv.paddedBytes = int(params.GetPaddedOutputBytes())
+}
+
+// checkPadding returns ErrorBadPadding if the padding parameters are invalid.
+func (priv *PrivStore) checkPadding() error {
+	switch priv.padding {
+	case pb.OutputPadding_NONE, pb.OutputPadding_POWER_OF_TWO:
+		if priv.paddedBytes != 0 {
+			return ErrorBadPadding
+		}
+	case pb.OutputPadding_FIXED:
+		if priv.paddedBytes < 1 {
+			return ErrorBadPadding
+		}
+	default:
+		return ErrorBadPadding
+	}
+	return nil
+}
+
+// paddedLen returns the length of the output once it is padded.
+func (priv *PrivStore) paddedLen(outputBytes int) int {
+	switch priv.padding {
+	case pb.OutputPadding_FIXED:
+		return priv.paddedBytes
+	case pb.OutputPadding_POWER_OF_TWO:
+		n := 1
+		for n < outputBytes+1 {
+			n <<= 1
+		}
+		return n
+	}
+	return outputBytes
+}
+
+// pad pads the output. The output is followed by outputPadByte and as many 0s
+// as needed.
+func (priv *PrivStore) pad(output []byte) ([]byte, error) {
+	if priv.padding == pb.OutputPadding_NONE {
+		return output, nil
+	}
+	n := priv.paddedLen(len(output))
+	if len(output) >= n {
+		return nil, ErrorOutputTooLong
+	}
+	padded := make([]byte, n)
+	copy(padded, output)
+	padded[len(output)] = outputPadByte
+	return padded, nil
+}
+
+// unpad removes the padding from the output. It returns ItemNotFound if the
+// padding is malformed.
+func (priv *PrivStore) unpad(padded []byte) ([]byte, error) {
+	if priv.padding == pb.OutputPadding_NONE {
+		return padded, nil
+	}
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != outputPadByte || priv.paddedLen(i) != len(padded) {
+		return nil, ItemNotFound
+	}
+	return padded[:i], nil
+}
+
+// ad returns the associated data used to seal the output corresponding to
+// input. If the outputs are padded, then the padding parameters are
+// authenticated along with the input. Otherwise, the associated data is just
+// the input.
+func (priv *PrivStore) ad(input string) []byte {
+	if priv.padding == pb.OutputPadding_NONE {
+		return []byte(input)
+	}
+	ad := make([]byte, 8, 8+len(input))
+	binary.LittleEndian.PutUint32(ad, uint32(priv.padding))
+	binary.LittleEndian.PutUint32(ad[4:], uint32(priv.paddedBytes))
+	return append(ad, input...)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// checkSealedBytes checks that each sealed output has the expected length.
+func checkSealedBytes(t *testing.T, pub *PubStore, priv *PrivStore, M map[string]string, sealedBytes func(int) int) {
+	for in, out := range M {
+		x, y, err := priv.GetIdx(in)
+		if err != nil {
+			t.Fatalf("priv.GetIdx(%q) fails: %s", in, err)
+		}
+		pubShare, err := pub.GetShare(x, y)
+		if err != nil {
+			t.Fatalf("pub.GetShare(%d, %d) fails: %s", x, y, err)
+		}
+		AssertIntEqError(t, "len(pubShare)", len(pubShare)-priv.dict.rowBytes(), sealedBytes(len(out)))
+	}
+}
+
+func TestWithPadding(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithPadding(16), WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	overhead := priv.aead.Overhead()
+	checkStore(t, pub, priv, goodM)
+	checkSealedBytes(t, pub, priv, goodM, func(int) int { return 16 + overhead })
+
+	// The padding is preserved by the protobuf representation.
+	params := pub.GetProto().GetDict().GetParams()
+	if params.GetOutputPadding() != pb.OutputPadding_FIXED {
+		t.Errorf("params.OutputPadding = %s, expected %s", params.GetOutputPadding(), pb.OutputPadding_FIXED)
+	}
+	AssertInt32EqError(t, "params.PaddedOutputBytes", params.GetPaddedOutputBytes(), 16)
+
+	// The padding is applied to updates.
+	update, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	for _, sealed := range update.GetSealed() {
+		AssertIntEqError(t, "len(sealed)", len(sealed), 16+overhead)
+	}
+	if _, err = priv.Update(pub, "hip", "this output is far too long"); err != ErrorOutputTooLong {
+		t.Errorf("priv.Update() returns %v, expected %q", err, ErrorOutputTooLong)
+	}
+
+	if _, _, err = NewStore(GenerateKey(), goodM, WithPadding(4)); err != ErrorOutputTooLong {
+		t.Errorf("NewStore(WithPadding(4)) returns %v, expected %q", err, ErrorOutputTooLong)
+	}
+	if _, _, err = NewStore(GenerateKey(), goodM, WithPadding(0)); err != ErrorBadPadding {
+		t.Errorf("NewStore(WithPadding(0)) returns %v, expected %q", err, ErrorBadPadding)
+	}
+}
+
+func TestWithBucketPadding(t *testing.T) {
+	K := GenerateKey()
+	pub, priv, err := NewStore(K, goodM, WithBucketPadding())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	overhead := priv.aead.Overhead()
+	checkStore(t, pub, priv, goodM)
+	checkSealedBytes(t, pub, priv, goodM, func(n int) int {
+		switch {
+		case n < 1:
+			return 1 + overhead
+		case n < 2:
+			return 2 + overhead
+		case n < 4:
+			return 4 + overhead
+		}
+		return 8 + overhead
+	})
+
+	// The client's context is created from the public parameters.
+	priv2, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams(), 0)
+	if err != nil {
+		t.Fatalf("NewPrivStore() fails: %s", err)
+	}
+	defer priv2.Close()
+	checkStore(t, pub, priv2, goodM)
+
+	// The padding parameters are authenticated, both by the MAC of the
+	// parameters and by the associated data of each sealed output.
+	params := priv.GetParams()
+	params.OutputPadding = pb.OutputPadding_NONE
+	if _, err = NewPrivStore(K, params, 0); err != ErrorBadParams {
+		t.Errorf("NewPrivStore() returns %v for modified parameters, expected %q", err, ErrorBadParams)
+	}
+	params.Mac = nil
+	if _, err = NewPrivStore(K, params, 0); err != ErrorBadParams {
+		t.Errorf("NewPrivStore() returns %v for modified parameters without a MAC, expected %q", err, ErrorBadParams)
+	}
+	priv3, err := NewPrivStore(K, priv.GetParams(), 0)
+	if err != nil {
+		t.Fatalf("NewPrivStore() fails: %s", err)
+	}
+	defer priv3.Close()
+	priv3.padding, priv3.paddedBytes = pb.OutputPadding_NONE, 0
+	if _, err = priv3.Get(pub, "hip"); err != ItemNotFound {
+		t.Errorf("priv3.Get(pub, \"hip\") returns %v, expected %q", err, ItemNotFound)
+	}
+}
@@ -8,13 +8,39 @@ It is generated from these files:
 	store.proto
 
 It has these top-level messages:
+	KdfHeader
+	WrappedKey
+	KeyEnvelope
 	Params
 	Dict
 	Store
+	ShareProof
+	StoreUpdate
 	ShareRequest
 	ShareReply
+	SharesRequest
+	SharesReply
+	PirRequest
+	PirReply
+	SimplePirHint
+	SimplePirHintRequest
+	SimplePirHintReply
 	ParamsRequest
 	ParamsReply
+	OprfRequest
+	OprfReply
+	PutStoreRequest
+	PutStoreReply
+	DeleteStoreRequest
+	DeleteStoreReply
+	StoreVersionRequest
+	StoreVersionReply
+	IndexPageRequest
+	IndexPageReply
+	KeyEnvelopeRequest
+	KeyEnvelopeReply
+	PutKeyEnvelopeRequest
+	PutKeyEnvelopeReply
 */
 package pb
 
@@ -38,14 +64,103 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// How the outputs of store.PubStore are padded before they are sealed.
+type OutputPadding int32
+
+const (
+	OutputPadding_NONE OutputPadding = 0
+	// Each output is padded to padded_output_bytes.
+	OutputPadding_FIXED OutputPadding = 1
+	// Each output is padded to the next power of two.
+	OutputPadding_POWER_OF_TWO OutputPadding = 2
+)
+
+var OutputPadding_name = map[int32]string{
+	0: "NONE",
+	1: "FIXED",
+	2: "POWER_OF_TWO",
+}
+var OutputPadding_value = map[string]int32{
+	"NONE":         0,
+	"FIXED":        1,
+	"POWER_OF_TWO": 2,
+}
+
+func (x OutputPadding) String() string {
+	return proto.EnumName(OutputPadding_name, int32(x))
+}
+func (OutputPadding) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// How the keys of store.PubStore are derived from the store key. (See
+// store.WithKeySchedule().)
+type KeySchedule int32
+
+const (
+	// The store key is split into the key of the dictionary and the key for
+	// sealing the outputs (AES-128-GCM). This is used by stores created before
+	// key schedules were supported.
+	KeySchedule_LEGACY_SPLIT KeySchedule = 0
+	// Each key is derived from the store key with HKDF-SHA256. The outputs are
+	// sealed with AES-128-GCM.
+	KeySchedule_HKDF_SHA256_AES128 KeySchedule = 1
+	// The same as HKDF_SHA256_AES128, except that the outputs are sealed with
+	// AES-256-GCM.
+	KeySchedule_HKDF_SHA256_AES256 KeySchedule = 2
+)
+
+var KeySchedule_name = map[int32]string{
+	0: "LEGACY_SPLIT",
+	1: "HKDF_SHA256_AES128",
+	2: "HKDF_SHA256_AES256",
+}
+var KeySchedule_value = map[string]int32{
+	"LEGACY_SPLIT":       0,
+	"HKDF_SHA256_AES128": 1,
+	"HKDF_SHA256_AES256": 2,
+}
+
+func (x KeySchedule) String() string {
+	return proto.EnumName(KeySchedule_name, int32(x))
+}
+func (KeySchedule) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+// The password-based key derivation function used to derive the store key.
+type PasswordKdf int32
+
+const (
+	PasswordKdf_PBKDF2_SHA256 PasswordKdf = 0
+	PasswordKdf_SCRYPT        PasswordKdf = 1
+	PasswordKdf_ARGON2ID      PasswordKdf = 2
+)
+
+var PasswordKdf_name = map[int32]string{
+	0: "PBKDF2_SHA256",
+	1: "SCRYPT",
+	2: "ARGON2ID",
+}
+var PasswordKdf_value = map[string]int32{
+	"PBKDF2_SHA256": 0,
+	"SCRYPT":        1,
+	"ARGON2ID":      2,
+}
+
+func (x PasswordKdf) String() string {
+	return proto.EnumName(PasswordKdf_name, int32(x))
+}
+func (PasswordKdf) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
 // Errors output by the remote procedure calls.
 type StoreProviderError int32
 
 const (
-	StoreProviderError_OK             StoreProviderError = 0
-	StoreProviderError_BAD_USER       StoreProviderError = 1
-	StoreProviderError_INDEX          StoreProviderError = 2
-	StoreProviderError_ITEM_NOT_FOUND StoreProviderError = 3
+	StoreProviderError_OK               StoreProviderError = 0
+	StoreProviderError_BAD_USER         StoreProviderError = 1
+	StoreProviderError_INDEX            StoreProviderError = 2
+	StoreProviderError_ITEM_NOT_FOUND   StoreProviderError = 3
+	StoreProviderError_BAD_REQUEST      StoreProviderError = 4
+	StoreProviderError_RATE_LIMITED     StoreProviderError = 5
+	StoreProviderError_UNAUTHENTICATED  StoreProviderError = 6
+	StoreProviderError_VERSION_MISMATCH StoreProviderError = 7
 )
 
 var StoreProviderError_name = map[int32]string{
@@ -53,18 +168,172 @@ var StoreProviderError_name = map[int32]string{
 	1: "BAD_USER",
 	2: "INDEX",
 	3: "ITEM_NOT_FOUND",
+	4: "BAD_REQUEST",
+	5: "RATE_LIMITED",
+	6: "UNAUTHENTICATED",
+	7: "VERSION_MISMATCH",
 }
 var StoreProviderError_value = map[string]int32{
-	"OK":             0,
-	"BAD_USER":       1,
-	"INDEX":          2,
-	"ITEM_NOT_FOUND": 3,
+	"OK":               0,
+	"BAD_USER":         1,
+	"INDEX":            2,
+	"ITEM_NOT_FOUND":   3,
+	"BAD_REQUEST":      4,
+	"RATE_LIMITED":     5,
+	"UNAUTHENTICATED":  6,
+	"VERSION_MISMATCH": 7,
 }
 
 func (x StoreProviderError) String() string {
 	return proto.EnumName(StoreProviderError_name, int32(x))
 }
-func (StoreProviderError) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (StoreProviderError) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+// The public header that describes how the store key is derived from the
+// user's password. (See store.DeriveKeyFromHeader().) It is not secret, so the
+// StoreProvider serves it along with the parameters of the store.
+type KdfHeader struct {
+	Kdf  PasswordKdf `protobuf:"varint,1,opt,name=kdf,enum=pb.PasswordKdf" json:"kdf,omitempty"`
+	Salt []byte      `protobuf:"bytes,2,opt,name=salt,proto3" json:"salt,omitempty"`
+	// The number of iterations of PBKDF2, or the number of passes of Argon2id.
+	Iterations int32 `protobuf:"varint,3,opt,name=iterations" json:"iterations,omitempty"`
+	// The memory used by Argon2id, in KiB.
+	MemoryKib int32 `protobuf:"varint,4,opt,name=memory_kib,json=memoryKib" json:"memory_kib,omitempty"`
+	// The number of threads used by Argon2id, or the parallelization parameter
+	// of scrypt.
+	Parallelism int32 `protobuf:"varint,5,opt,name=parallelism" json:"parallelism,omitempty"`
+	// The log of the CPU/memory cost and the block size of scrypt.
+	LogN      int32 `protobuf:"varint,6,opt,name=log_n,json=logN" json:"log_n,omitempty"`
+	BlockSize int32 `protobuf:"varint,7,opt,name=block_size,json=blockSize" json:"block_size,omitempty"`
+}
+
+func (m *KdfHeader) Reset()                    { *m = KdfHeader{} }
+func (m *KdfHeader) String() string            { return proto.CompactTextString(m) }
+func (*KdfHeader) ProtoMessage()               {}
+func (*KdfHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *KdfHeader) GetKdf() PasswordKdf {
+	if m != nil {
+		return m.Kdf
+	}
+	return PasswordKdf_PBKDF2_SHA256
+}
+
+func (m *KdfHeader) GetSalt() []byte {
+	if m != nil {
+		return m.Salt
+	}
+	return nil
+}
+
+func (m *KdfHeader) GetIterations() int32 {
+	if m != nil {
+		return m.Iterations
+	}
+	return 0
+}
+
+func (m *KdfHeader) GetMemoryKib() int32 {
+	if m != nil {
+		return m.MemoryKib
+	}
+	return 0
+}
+
+func (m *KdfHeader) GetParallelism() int32 {
+	if m != nil {
+		return m.Parallelism
+	}
+	return 0
+}
+
+func (m *KdfHeader) GetLogN() int32 {
+	if m != nil {
+		return m.LogN
+	}
+	return 0
+}
+
+func (m *KdfHeader) GetBlockSize() int32 {
+	if m != nil {
+		return m.BlockSize
+	}
+	return 0
+}
+
+// A data key wrapped under a key-encryption key, which is either derived from a
+// password or held by a device. (See store.WrapKey().)
+type WrappedKey struct {
+	// Names the key-encryption key, e.g., "password" or "laptop".
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// How the key-encryption key is derived from a password. This is not set if
+	// the key-encryption key is not derived from a password.
+	KdfHeader  *KdfHeader `protobuf:"bytes,2,opt,name=kdf_header,json=kdfHeader" json:"kdf_header,omitempty"`
+	Nonce      []byte     `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Ciphertext []byte     `protobuf:"bytes,4,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+}
+
+func (m *WrappedKey) Reset()                    { *m = WrappedKey{} }
+func (m *WrappedKey) String() string            { return proto.CompactTextString(m) }
+func (*WrappedKey) ProtoMessage()               {}
+func (*WrappedKey) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *WrappedKey) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *WrappedKey) GetKdfHeader() *KdfHeader {
+	if m != nil {
+		return m.KdfHeader
+	}
+	return nil
+}
+
+func (m *WrappedKey) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
+func (m *WrappedKey) GetCiphertext() []byte {
+	if m != nil {
+		return m.Ciphertext
+	}
+	return nil
+}
+
+// The data key of a store, wrapped under one or more key-encryption keys. It
+// is not secret, so the StoreProvider holds it for the user. (See
+// store.UnwrapKey().)
+type KeyEnvelope struct {
+	Key []*WrappedKey `protobuf:"bytes,1,rep,name=key" json:"key,omitempty"`
+	// The version of the envelope. This is set by the StoreProvider each time
+	// the envelope is replaced; see PutKeyEnvelope.
+	Version int64 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *KeyEnvelope) Reset()                    { *m = KeyEnvelope{} }
+func (m *KeyEnvelope) String() string            { return proto.CompactTextString(m) }
+func (*KeyEnvelope) ProtoMessage()               {}
+func (*KeyEnvelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *KeyEnvelope) GetKey() []*WrappedKey {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *KeyEnvelope) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
 
 // Parameters needed by store.PubDict and store.PrivDict.
 type Params struct {
@@ -75,12 +344,28 @@ type Params struct {
 	SaltBytes      int32  `protobuf:"varint,5,opt,name=salt_bytes,json=saltBytes" json:"salt_bytes,omitempty"`
 	Salt           []byte `protobuf:"bytes,6,opt,name=salt,proto3" json:"salt,omitempty"`
 	Pad            bool   `protobuf:"varint,7,opt,name=pad" json:"pad,omitempty"`
+	// The padding of the sealed outputs. These are used by store.PubStore and
+	// store.PrivStore, but not by store.PubDict and store.PrivDict.
+	OutputPadding     OutputPadding `protobuf:"varint,8,opt,name=output_padding,json=outputPadding,enum=pb.OutputPadding" json:"output_padding,omitempty"`
+	PaddedOutputBytes int32         `protobuf:"varint,9,opt,name=padded_output_bytes,json=paddedOutputBytes" json:"padded_output_bytes,omitempty"`
+	// The version of the store and the time it was created (in seconds since
+	// the Unix epoch). The version is chosen by the client that created the
+	// store (see store.WithVersion()); unlike Store.version, it is not assigned
+	// by the StoreProvider. mac is a MAC of the parameters under a key derived
+	// from the store key, so that a client that requires a minimum version (see
+	// store.NewPrivStore()) rejects the parameters of an older store. Stores
+	// created before versions were supported have no MAC.
+	Version int64  `protobuf:"varint,10,opt,name=version" json:"version,omitempty"`
+	Created int64  `protobuf:"varint,11,opt,name=created" json:"created,omitempty"`
+	Mac     []byte `protobuf:"bytes,12,opt,name=mac,proto3" json:"mac,omitempty"`
+	// How the keys are derived from the store key.
+	KeySchedule KeySchedule `protobuf:"varint,13,opt,name=key_schedule,json=keySchedule,enum=pb.KeySchedule" json:"key_schedule,omitempty"`
 }
 
 func (m *Params) Reset()                    { *m = Params{} }
 func (m *Params) String() string            { return proto.CompactTextString(m) }
 func (*Params) ProtoMessage()               {}
-func (*Params) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*Params) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 func (m *Params) GetTableLen() int32 {
 	if m != nil {
@@ -131,6 +416,48 @@ func (m *Params) GetPad() bool {
 	return false
 }
 
+func (m *Params) GetOutputPadding() OutputPadding {
+	if m != nil {
+		return m.OutputPadding
+	}
+	return OutputPadding_NONE
+}
+
+func (m *Params) GetPaddedOutputBytes() int32 {
+	if m != nil {
+		return m.PaddedOutputBytes
+	}
+	return 0
+}
+
+func (m *Params) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Params) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *Params) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *Params) GetKeySchedule() KeySchedule {
+	if m != nil {
+		return m.KeySchedule
+	}
+	return KeySchedule_LEGACY_SPLIT
+}
+
 // A compressed representation of store.PubDict.
 type Dict struct {
 	Params *Params `protobuf:"bytes,1,opt,name=params" json:"params,omitempty"`
@@ -141,7 +468,7 @@ type Dict struct {
 func (m *Dict) Reset()                    { *m = Dict{} }
 func (m *Dict) String() string            { return proto.CompactTextString(m) }
 func (*Dict) ProtoMessage()               {}
-func (*Dict) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*Dict) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 func (m *Dict) GetParams() *Params {
 	if m != nil {
@@ -171,12 +498,25 @@ type Store struct {
 	NodeCt  int32            `protobuf:"varint,3,opt,name=node_ct,json=nodeCt" json:"node_ct,omitempty"`
 	Sealed  [][]byte         `protobuf:"bytes,4,rep,name=sealed,proto3" json:"sealed,omitempty"`
 	Dict    *Dict            `protobuf:"bytes,5,opt,name=dict" json:"dict,omitempty"`
+	// The next unused AEAD nonce counter.
+	Ctr int32 `protobuf:"varint,6,opt,name=ctr" json:"ctr,omitempty"`
+	// The version of the store. This is set by the StoreProvider each time the
+	// store is replaced; see PutStore. A store that has not been provisioned via
+	// PutStore has version 0.
+	Version int64 `protobuf:"varint,7,opt,name=version" json:"version,omitempty"`
+	// How the store key is derived from the user's password, if it is. (See
+	// store.WithKdfHeader().)
+	KdfHeader *KdfHeader `protobuf:"bytes,8,opt,name=kdf_header,json=kdfHeader" json:"kdf_header,omitempty"`
+	// The index of the inputs, if the store has one. (See store.WithIndex().)
+	// Each page lists the inputs of store.IndexPageSize consecutive edges and is
+	// sealed under a key derived from the store key.
+	Index [][]byte `protobuf:"bytes,9,rep,name=index,proto3" json:"index,omitempty"`
 }
 
 func (m *Store) Reset()                    { *m = Store{} }
 func (m *Store) String() string            { return proto.CompactTextString(m) }
 func (*Store) ProtoMessage()               {}
-func (*Store) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*Store) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *Store) GetAdjList() []*Store_AdjList {
 	if m != nil {
@@ -213,6 +553,34 @@ func (m *Store) GetDict() *Dict {
 	return nil
 }
 
+func (m *Store) GetCtr() int32 {
+	if m != nil {
+		return m.Ctr
+	}
+	return 0
+}
+
+func (m *Store) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Store) GetKdfHeader() *KdfHeader {
+	if m != nil {
+		return m.KdfHeader
+	}
+	return nil
+}
+
+func (m *Store) GetIndex() [][]byte {
+	if m != nil {
+		return m.Index
+	}
+	return nil
+}
+
 type Store_AdjList struct {
 	Edge []int32 `protobuf:"varint,1,rep,packed,name=edge" json:"edge,omitempty"`
 }
@@ -220,7 +588,7 @@ type Store_AdjList struct {
 func (m *Store_AdjList) Reset()                    { *m = Store_AdjList{} }
 func (m *Store_AdjList) String() string            { return proto.CompactTextString(m) }
 func (*Store_AdjList) ProtoMessage()               {}
-func (*Store_AdjList) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+func (*Store_AdjList) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
 
 func (m *Store_AdjList) GetEdge() []int32 {
 	if m != nil {
@@ -229,142 +597,1023 @@ func (m *Store_AdjList) GetEdge() []int32 {
 	return nil
 }
 
-// The share request message.
-type ShareRequest struct {
-	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
-	X      int32  `protobuf:"varint,2,opt,name=x" json:"x,omitempty"`
-	Y      int32  `protobuf:"varint,3,opt,name=y" json:"y,omitempty"`
+// A share of the public store together with a proof that it matches the
+// store's commitment. (See store.PubStore.GetVerifiableShare().) The proof
+// consists of the authentication path of each leaf of the Merkle tree.
+type ShareProof struct {
+	// The x-th and y-th rows of the table. The leaf of each row also contains
+	// the edges incident to the row, so that the client can tell whether there
+	// is an edge (x, y).
+	RowX  []byte   `protobuf:"bytes,1,opt,name=row_x,json=rowX,proto3" json:"row_x,omitempty"`
+	PathX [][]byte `protobuf:"bytes,2,rep,name=path_x,json=pathX,proto3" json:"path_x,omitempty"`
+	RowY  []byte   `protobuf:"bytes,3,opt,name=row_y,json=rowY,proto3" json:"row_y,omitempty"`
+	PathY [][]byte `protobuf:"bytes,4,rep,name=path_y,json=pathY,proto3" json:"path_y,omitempty"`
+	AdjX  []int32  `protobuf:"varint,9,rep,packed,name=adj_x,json=adjX" json:"adj_x,omitempty"`
+	AdjY  []int32  `protobuf:"varint,10,rep,packed,name=adj_y,json=adjY" json:"adj_y,omitempty"`
+	// The sealed output of the edge (x, y), if there is one.
+	Found      bool     `protobuf:"varint,5,opt,name=found" json:"found,omitempty"`
+	SealedIdx  int32    `protobuf:"varint,6,opt,name=sealed_idx,json=sealedIdx" json:"sealed_idx,omitempty"`
+	Sealed     []byte   `protobuf:"bytes,7,opt,name=sealed,proto3" json:"sealed,omitempty"`
+	PathSealed [][]byte `protobuf:"bytes,8,rep,name=path_sealed,json=pathSealed,proto3" json:"path_sealed,omitempty"`
 }
 
-func (m *ShareRequest) Reset()                    { *m = ShareRequest{} }
-func (m *ShareRequest) String() string            { return proto.CompactTextString(m) }
-func (*ShareRequest) ProtoMessage()               {}
-func (*ShareRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *ShareProof) Reset()                    { *m = ShareProof{} }
+func (m *ShareProof) String() string            { return proto.CompactTextString(m) }
+func (*ShareProof) ProtoMessage()               {}
+func (*ShareProof) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
-func (m *ShareRequest) GetUserId() string {
+func (m *ShareProof) GetRowX() []byte {
 	if m != nil {
-		return m.UserId
+		return m.RowX
 	}
-	return ""
+	return nil
 }
 
-func (m *ShareRequest) GetX() int32 {
+func (m *ShareProof) GetPathX() [][]byte {
 	if m != nil {
-		return m.X
+		return m.PathX
 	}
-	return 0
+	return nil
 }
 
-func (m *ShareRequest) GetY() int32 {
+func (m *ShareProof) GetRowY() []byte {
 	if m != nil {
-		return m.Y
+		return m.RowY
 	}
-	return 0
-}
-
-// The share response message.
-type ShareReply struct {
-	PubShare []byte             `protobuf:"bytes,1,opt,name=pub_share,json=pubShare,proto3" json:"pub_share,omitempty"`
-	Error    StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+	return nil
 }
 
-func (m *ShareReply) Reset()                    { *m = ShareReply{} }
-func (m *ShareReply) String() string            { return proto.CompactTextString(m) }
-func (*ShareReply) ProtoMessage()               {}
-func (*ShareReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
-
-func (m *ShareReply) GetPubShare() []byte {
+func (m *ShareProof) GetPathY() [][]byte {
 	if m != nil {
-		return m.PubShare
+		return m.PathY
 	}
 	return nil
 }
 
-func (m *ShareReply) GetError() StoreProviderError {
+func (m *ShareProof) GetAdjX() []int32 {
 	if m != nil {
-		return m.Error
+		return m.AdjX
 	}
-	return StoreProviderError_OK
+	return nil
 }
 
-// The parameters request message.
-type ParamsRequest struct {
-	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+func (m *ShareProof) GetAdjY() []int32 {
+	if m != nil {
+		return m.AdjY
+	}
+	return nil
 }
 
-func (m *ParamsRequest) Reset()                    { *m = ParamsRequest{} }
-func (m *ParamsRequest) String() string            { return proto.CompactTextString(m) }
-func (*ParamsRequest) ProtoMessage()               {}
-func (*ParamsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
-
-func (m *ParamsRequest) GetUserId() string {
+func (m *ShareProof) GetFound() bool {
 	if m != nil {
-		return m.UserId
+		return m.Found
 	}
-	return ""
+	return false
 }
 
-// The parameters response message.
-type ParamsReply struct {
-	Params *Params            `protobuf:"bytes,1,opt,name=params" json:"params,omitempty"`
-	Error  StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+func (m *ShareProof) GetSealedIdx() int32 {
+	if m != nil {
+		return m.SealedIdx
+	}
+	return 0
 }
 
-func (m *ParamsReply) Reset()                    { *m = ParamsReply{} }
-func (m *ParamsReply) String() string            { return proto.CompactTextString(m) }
-func (*ParamsReply) ProtoMessage()               {}
-func (*ParamsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
-
-func (m *ParamsReply) GetParams() *Params {
+func (m *ShareProof) GetSealed() []byte {
 	if m != nil {
-		return m.Params
+		return m.Sealed
 	}
 	return nil
 }
 
-func (m *ParamsReply) GetError() StoreProviderError {
+func (m *ShareProof) GetPathSealed() [][]byte {
 	if m != nil {
-		return m.Error
+		return m.PathSealed
 	}
-	return StoreProviderError_OK
+	return nil
 }
 
-func init() {
-	proto.RegisterType((*Params)(nil), "pb.Params")
-	proto.RegisterType((*Dict)(nil), "pb.Dict")
-	proto.RegisterType((*Store)(nil), "pb.Store")
-	proto.RegisterType((*Store_AdjList)(nil), "pb.Store.AdjList")
-	proto.RegisterType((*ShareRequest)(nil), "pb.ShareRequest")
-	proto.RegisterType((*ShareReply)(nil), "pb.ShareReply")
-	proto.RegisterType((*ParamsRequest)(nil), "pb.ParamsRequest")
-	proto.RegisterType((*ParamsReply)(nil), "pb.ParamsReply")
-	proto.RegisterEnum("pb.StoreProviderError", StoreProviderError_name, StoreProviderError_value)
+// An update to store.PubStore. It is computed by store.PrivStore and applied
+// by store.PubStore.
+type StoreUpdate struct {
+	// The counter of the store the update was computed from, and the counter of
+	// the store after the update.
+	Ctr     int32 `protobuf:"varint,1,opt,name=ctr" json:"ctr,omitempty"`
+	NextCtr int32 `protobuf:"varint,2,opt,name=next_ctr,json=nextCtr" json:"next_ctr,omitempty"`
+	// The parameters of the store after the update, and the rows of the table
+	// that change. Each tree of the graph containing one of these rows is
+	// re-randomized.
+	Dict *Dict `protobuf:"bytes,3,opt,name=dict" json:"dict,omitempty"`
+	// Indicates whether the update inserts the edge (x, y). The new edge comes
+	// after every other edge of the graph.
+	Insert bool  `protobuf:"varint,4,opt,name=insert" json:"insert,omitempty"`
+	X      int32 `protobuf:"varint,5,opt,name=x" json:"x,omitempty"`
+	Y      int32 `protobuf:"varint,6,opt,name=y" json:"y,omitempty"`
+	// The edges whose sealed outputs change, and the new sealed outputs.
+	SealedIdx []int32  `protobuf:"varint,7,rep,packed,name=sealed_idx,json=sealedIdx" json:"sealed_idx,omitempty"`
+	Sealed    [][]byte `protobuf:"bytes,8,rep,name=sealed,proto3" json:"sealed,omitempty"`
+	// The pages of the index of the inputs that change, if the store has an
+	// index, and the new pages.
+	IndexPage []int32  `protobuf:"varint,9,rep,packed,name=index_page,json=indexPage" json:"index_page,omitempty"`
+	Index     [][]byte `protobuf:"bytes,10,rep,name=index,proto3" json:"index,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *StoreUpdate) Reset()                    { *m = StoreUpdate{} }
+func (m *StoreUpdate) String() string            { return proto.CompactTextString(m) }
+func (*StoreUpdate) ProtoMessage()               {}
+func (*StoreUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
-// Client API for StoreProvider service
+func (m *StoreUpdate) GetCtr() int32 {
+	if m != nil {
+		return m.Ctr
+	}
+	return 0
+}
 
-type StoreProviderClient interface {
-	GetShare(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareReply, error)
-	GetParams(ctx context.Context, in *ParamsRequest, opts ...grpc.CallOption) (*ParamsReply, error)
+func (m *StoreUpdate) GetNextCtr() int32 {
+	if m != nil {
+		return m.NextCtr
+	}
+	return 0
 }
 
-type storeProviderClient struct {
-	cc *grpc.ClientConn
+func (m *StoreUpdate) GetDict() *Dict {
+	if m != nil {
+		return m.Dict
+	}
+	return nil
 }
 
-func NewStoreProviderClient(cc *grpc.ClientConn) StoreProviderClient {
-	return &storeProviderClient{cc}
+func (m *StoreUpdate) GetInsert() bool {
+	if m != nil {
+		return m.Insert
+	}
+	return false
 }
 
-func (c *storeProviderClient) GetShare(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareReply, error) {
+func (m *StoreUpdate) GetX() int32 {
+	if m != nil {
+		return m.X
+	}
+	return 0
+}
+
+func (m *StoreUpdate) GetY() int32 {
+	if m != nil {
+		return m.Y
+	}
+	return 0
+}
+
+func (m *StoreUpdate) GetSealedIdx() []int32 {
+	if m != nil {
+		return m.SealedIdx
+	}
+	return nil
+}
+
+func (m *StoreUpdate) GetSealed() [][]byte {
+	if m != nil {
+		return m.Sealed
+	}
+	return nil
+}
+
+func (m *StoreUpdate) GetIndexPage() []int32 {
+	if m != nil {
+		return m.IndexPage
+	}
+	return nil
+}
+
+func (m *StoreUpdate) GetIndex() [][]byte {
+	if m != nil {
+		return m.Index
+	}
+	return nil
+}
+
+// The share request message.
+type ShareRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	X      int32  `protobuf:"varint,2,opt,name=x" json:"x,omitempty"`
+	Y      int32  `protobuf:"varint,3,opt,name=y" json:"y,omitempty"`
+	// If set, then the share is a serialized ShareProof. It is computed even if
+	// the item is not found.
+	Verifiable bool `protobuf:"varint,4,opt,name=verifiable" json:"verifiable,omitempty"`
+}
+
+func (m *ShareRequest) Reset()                    { *m = ShareRequest{} }
+func (m *ShareRequest) String() string            { return proto.CompactTextString(m) }
+func (*ShareRequest) ProtoMessage()               {}
+func (*ShareRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ShareRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *ShareRequest) GetX() int32 {
+	if m != nil {
+		return m.X
+	}
+	return 0
+}
+
+func (m *ShareRequest) GetY() int32 {
+	if m != nil {
+		return m.Y
+	}
+	return 0
+}
+
+func (m *ShareRequest) GetVerifiable() bool {
+	if m != nil {
+		return m.Verifiable
+	}
+	return false
+}
+
+// The share response message.
+type ShareReply struct {
+	PubShare []byte             `protobuf:"bytes,1,opt,name=pub_share,json=pubShare,proto3" json:"pub_share,omitempty"`
+	Error    StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *ShareReply) Reset()                    { *m = ShareReply{} }
+func (m *ShareReply) String() string            { return proto.CompactTextString(m) }
+func (*ShareReply) ProtoMessage()               {}
+func (*ShareReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ShareReply) GetPubShare() []byte {
+	if m != nil {
+		return m.PubShare
+	}
+	return nil
+}
+
+func (m *ShareReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The batch share request message. It is equivalent to one ShareRequest for
+// each index.
+type SharesRequest struct {
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	Index  []*SharesRequest_Index `protobuf:"bytes,2,rep,name=index" json:"index,omitempty"`
+	// If set, then each share is a serialized ShareProof.
+	Verifiable bool `protobuf:"varint,3,opt,name=verifiable" json:"verifiable,omitempty"`
+}
+
+func (m *SharesRequest) Reset()                    { *m = SharesRequest{} }
+func (m *SharesRequest) String() string            { return proto.CompactTextString(m) }
+func (*SharesRequest) ProtoMessage()               {}
+func (*SharesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *SharesRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *SharesRequest) GetIndex() []*SharesRequest_Index {
+	if m != nil {
+		return m.Index
+	}
+	return nil
+}
+
+func (m *SharesRequest) GetVerifiable() bool {
+	if m != nil {
+		return m.Verifiable
+	}
+	return false
+}
+
+type SharesRequest_Index struct {
+	X int32 `protobuf:"varint,1,opt,name=x" json:"x,omitempty"`
+	Y int32 `protobuf:"varint,2,opt,name=y" json:"y,omitempty"`
+}
+
+func (m *SharesRequest_Index) Reset()                    { *m = SharesRequest_Index{} }
+func (m *SharesRequest_Index) String() string            { return proto.CompactTextString(m) }
+func (*SharesRequest_Index) ProtoMessage()               {}
+func (*SharesRequest_Index) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10, 0} }
+
+func (m *SharesRequest_Index) GetX() int32 {
+	if m != nil {
+		return m.X
+	}
+	return 0
+}
+
+func (m *SharesRequest_Index) GetY() int32 {
+	if m != nil {
+		return m.Y
+	}
+	return 0
+}
+
+// The batch share response message. The i-th share corresponds to the i-th
+// index of the request. If error is not OK, then the whole request failed and
+// share is empty.
+type SharesReply struct {
+	Share []*SharesReply_Share `protobuf:"bytes,1,rep,name=share" json:"share,omitempty"`
+	Error StoreProviderError   `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *SharesReply) Reset()                    { *m = SharesReply{} }
+func (m *SharesReply) String() string            { return proto.CompactTextString(m) }
+func (*SharesReply) ProtoMessage()               {}
+func (*SharesReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *SharesReply) GetShare() []*SharesReply_Share {
+	if m != nil {
+		return m.Share
+	}
+	return nil
+}
+
+func (m *SharesReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+type SharesReply_Share struct {
+	PubShare []byte             `protobuf:"bytes,1,opt,name=pub_share,json=pubShare,proto3" json:"pub_share,omitempty"`
+	Error    StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *SharesReply_Share) Reset()                    { *m = SharesReply_Share{} }
+func (m *SharesReply_Share) String() string            { return proto.CompactTextString(m) }
+func (*SharesReply_Share) ProtoMessage()               {}
+func (*SharesReply_Share) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
+
+func (m *SharesReply_Share) GetPubShare() []byte {
+	if m != nil {
+		return m.PubShare
+	}
+	return nil
+}
+
+func (m *SharesReply_Share) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The PIR request message. For two-server PIR (GetPirShare), each query is a
+// subset of the rows of the table, encoded as a bit vector. (See
+// PrivStore.GetPirQuery().) For single-server PIR (GetSimplePirShare), each
+// query is computed by SimplePirClient.Query().
+type PirRequest struct {
+	UserId string   `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	Query  [][]byte `protobuf:"bytes,2,rep,name=query,proto3" json:"query,omitempty"`
+}
+
+func (m *PirRequest) Reset()                    { *m = PirRequest{} }
+func (m *PirRequest) String() string            { return proto.CompactTextString(m) }
+func (*PirRequest) ProtoMessage()               {}
+func (*PirRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *PirRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *PirRequest) GetQuery() [][]byte {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+// The PIR response message. The i-th share is the answer to the i-th query.
+type PirReply struct {
+	PubShare [][]byte           `protobuf:"bytes,1,rep,name=pub_share,json=pubShare,proto3" json:"pub_share,omitempty"`
+	Error    StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *PirReply) Reset()                    { *m = PirReply{} }
+func (m *PirReply) String() string            { return proto.CompactTextString(m) }
+func (*PirReply) ProtoMessage()               {}
+func (*PirReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *PirReply) GetPubShare() [][]byte {
+	if m != nil {
+		return m.PubShare
+	}
+	return nil
+}
+
+func (m *PirReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The hint for single-server PIR. The client downloads it once for each
+// version of the store. (See SimplePirServer.)
+type SimplePirHint struct {
+	// The seed from which the public matrix is derived.
+	Seed []byte `protobuf:"bytes,1,opt,name=seed,proto3" json:"seed,omitempty"`
+	// The length of each record, i.e., the number of rows of the database.
+	RecordBytes int32 `protobuf:"varint,2,opt,name=record_bytes,json=recordBytes" json:"record_bytes,omitempty"`
+	// The number of records, i.e., the length of the table.
+	TableLen int32 `protobuf:"varint,3,opt,name=table_len,json=tableLen" json:"table_len,omitempty"`
+	// The product of the database and the public matrix. Each entry is encoded
+	// as 4 bytes in little-endian order.
+	Hint []byte `protobuf:"bytes,4,opt,name=hint,proto3" json:"hint,omitempty"`
+}
+
+func (m *SimplePirHint) Reset()                    { *m = SimplePirHint{} }
+func (m *SimplePirHint) String() string            { return proto.CompactTextString(m) }
+func (*SimplePirHint) ProtoMessage()               {}
+func (*SimplePirHint) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *SimplePirHint) GetSeed() []byte {
+	if m != nil {
+		return m.Seed
+	}
+	return nil
+}
+
+func (m *SimplePirHint) GetRecordBytes() int32 {
+	if m != nil {
+		return m.RecordBytes
+	}
+	return 0
+}
+
+func (m *SimplePirHint) GetTableLen() int32 {
+	if m != nil {
+		return m.TableLen
+	}
+	return 0
+}
+
+func (m *SimplePirHint) GetHint() []byte {
+	if m != nil {
+		return m.Hint
+	}
+	return nil
+}
+
+// The request for the single-server PIR hint.
+type SimplePirHintRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+}
+
+func (m *SimplePirHintRequest) Reset()                    { *m = SimplePirHintRequest{} }
+func (m *SimplePirHintRequest) String() string            { return proto.CompactTextString(m) }
+func (*SimplePirHintRequest) ProtoMessage()               {}
+func (*SimplePirHintRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *SimplePirHintRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+// The single-server PIR hint response message.
+type SimplePirHintReply struct {
+	Hint  *SimplePirHint     `protobuf:"bytes,1,opt,name=hint" json:"hint,omitempty"`
+	Error StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *SimplePirHintReply) Reset()                    { *m = SimplePirHintReply{} }
+func (m *SimplePirHintReply) String() string            { return proto.CompactTextString(m) }
+func (*SimplePirHintReply) ProtoMessage()               {}
+func (*SimplePirHintReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *SimplePirHintReply) GetHint() *SimplePirHint {
+	if m != nil {
+		return m.Hint
+	}
+	return nil
+}
+
+func (m *SimplePirHintReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The parameters request message.
+type ParamsRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+}
+
+func (m *ParamsRequest) Reset()                    { *m = ParamsRequest{} }
+func (m *ParamsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ParamsRequest) ProtoMessage()               {}
+func (*ParamsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *ParamsRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+// The parameters response message.
+type ParamsReply struct {
+	Params *Params            `protobuf:"bytes,1,opt,name=params" json:"params,omitempty"`
+	Error  StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+	// The header of the store, if it has one. The client needs it to derive
+	// the store key from the user's password.
+	KdfHeader *KdfHeader `protobuf:"bytes,3,opt,name=kdf_header,json=kdfHeader" json:"kdf_header,omitempty"`
+}
+
+func (m *ParamsReply) Reset()                    { *m = ParamsReply{} }
+func (m *ParamsReply) String() string            { return proto.CompactTextString(m) }
+func (*ParamsReply) ProtoMessage()               {}
+func (*ParamsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *ParamsReply) GetParams() *Params {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *ParamsReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+func (m *ParamsReply) GetKdfHeader() *KdfHeader {
+	if m != nil {
+		return m.KdfHeader
+	}
+	return nil
+}
+
+// The OPRF request message. The client's password is blinded, so the server
+// learns nothing about it.
+type OprfRequest struct {
+	UserId         string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	BlindedElement []byte `protobuf:"bytes,2,opt,name=blinded_element,json=blindedElement,proto3" json:"blinded_element,omitempty"`
+}
+
+func (m *OprfRequest) Reset()                    { *m = OprfRequest{} }
+func (m *OprfRequest) String() string            { return proto.CompactTextString(m) }
+func (*OprfRequest) ProtoMessage()               {}
+func (*OprfRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *OprfRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *OprfRequest) GetBlindedElement() []byte {
+	if m != nil {
+		return m.BlindedElement
+	}
+	return nil
+}
+
+// The OPRF response message.
+type OprfReply struct {
+	EvaluatedElement []byte             `protobuf:"bytes,1,opt,name=evaluated_element,json=evaluatedElement,proto3" json:"evaluated_element,omitempty"`
+	Error            StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *OprfReply) Reset()                    { *m = OprfReply{} }
+func (m *OprfReply) String() string            { return proto.CompactTextString(m) }
+func (*OprfReply) ProtoMessage()               {}
+func (*OprfReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *OprfReply) GetEvaluatedElement() []byte {
+	if m != nil {
+		return m.EvaluatedElement
+	}
+	return nil
+}
+
+func (m *OprfReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request to create or replace a user's store. The store is replaced only
+// if its current version is expected_version. (Use 0 if the user has no
+// store.) Otherwise the reply is VERSION_MISMATCH and carries the current
+// version.
+type PutStoreRequest struct {
+	UserId          string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	Store           *Store `protobuf:"bytes,2,opt,name=store" json:"store,omitempty"`
+	ExpectedVersion int64  `protobuf:"varint,3,opt,name=expected_version,json=expectedVersion" json:"expected_version,omitempty"`
+}
+
+func (m *PutStoreRequest) Reset()                    { *m = PutStoreRequest{} }
+func (m *PutStoreRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutStoreRequest) ProtoMessage()               {}
+func (*PutStoreRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *PutStoreRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *PutStoreRequest) GetStore() *Store {
+	if m != nil {
+		return m.Store
+	}
+	return nil
+}
+
+func (m *PutStoreRequest) GetExpectedVersion() int64 {
+	if m != nil {
+		return m.ExpectedVersion
+	}
+	return 0
+}
+
+// The PutStore response message. On success, version is the new version of the
+// store.
+type PutStoreReply struct {
+	Version int64              `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Error   StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *PutStoreReply) Reset()                    { *m = PutStoreReply{} }
+func (m *PutStoreReply) String() string            { return proto.CompactTextString(m) }
+func (*PutStoreReply) ProtoMessage()               {}
+func (*PutStoreReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *PutStoreReply) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *PutStoreReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request to delete a user's store. The store is deleted only if its
+// current version is expected_version.
+type DeleteStoreRequest struct {
+	UserId          string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	ExpectedVersion int64  `protobuf:"varint,2,opt,name=expected_version,json=expectedVersion" json:"expected_version,omitempty"`
+}
+
+func (m *DeleteStoreRequest) Reset()                    { *m = DeleteStoreRequest{} }
+func (m *DeleteStoreRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteStoreRequest) ProtoMessage()               {}
+func (*DeleteStoreRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *DeleteStoreRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *DeleteStoreRequest) GetExpectedVersion() int64 {
+	if m != nil {
+		return m.ExpectedVersion
+	}
+	return 0
+}
+
+// The DeleteStore response message.
+type DeleteStoreReply struct {
+	Version int64              `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Error   StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *DeleteStoreReply) Reset()                    { *m = DeleteStoreReply{} }
+func (m *DeleteStoreReply) String() string            { return proto.CompactTextString(m) }
+func (*DeleteStoreReply) ProtoMessage()               {}
+func (*DeleteStoreReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *DeleteStoreReply) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *DeleteStoreReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request for the current version of a user's store.
+type StoreVersionRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+}
+
+func (m *StoreVersionRequest) Reset()                    { *m = StoreVersionRequest{} }
+func (m *StoreVersionRequest) String() string            { return proto.CompactTextString(m) }
+func (*StoreVersionRequest) ProtoMessage()               {}
+func (*StoreVersionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *StoreVersionRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+// The GetStoreVersion response message.
+type StoreVersionReply struct {
+	Version int64              `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Error   StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *StoreVersionReply) Reset()                    { *m = StoreVersionReply{} }
+func (m *StoreVersionReply) String() string            { return proto.CompactTextString(m) }
+func (*StoreVersionReply) ProtoMessage()               {}
+func (*StoreVersionReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *StoreVersionReply) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *StoreVersionReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request for a page of the index of the inputs of a user's store. (See
+// store.WithIndex().)
+type IndexPageRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	Page   int32  `protobuf:"varint,2,opt,name=page" json:"page,omitempty"`
+}
+
+func (m *IndexPageRequest) Reset()                    { *m = IndexPageRequest{} }
+func (m *IndexPageRequest) String() string            { return proto.CompactTextString(m) }
+func (*IndexPageRequest) ProtoMessage()               {}
+func (*IndexPageRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *IndexPageRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *IndexPageRequest) GetPage() int32 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+// The GetIndexPage response message.
+type IndexPageReply struct {
+	// The sealed page.
+	Page []byte `protobuf:"bytes,1,opt,name=page,proto3" json:"page,omitempty"`
+	// The number of pages of the index.
+	PageCt int32 `protobuf:"varint,2,opt,name=page_ct,json=pageCt" json:"page_ct,omitempty"`
+	// ITEM_NOT_FOUND if the store has no index and INDEX if there is no such
+	// page.
+	Error StoreProviderError `protobuf:"varint,3,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *IndexPageReply) Reset()                    { *m = IndexPageReply{} }
+func (m *IndexPageReply) String() string            { return proto.CompactTextString(m) }
+func (*IndexPageReply) ProtoMessage()               {}
+func (*IndexPageReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *IndexPageReply) GetPage() []byte {
+	if m != nil {
+		return m.Page
+	}
+	return nil
+}
+
+func (m *IndexPageReply) GetPageCt() int32 {
+	if m != nil {
+		return m.PageCt
+	}
+	return 0
+}
+
+func (m *IndexPageReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request for a user's key envelope.
+type KeyEnvelopeRequest struct {
+	UserId string `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+}
+
+func (m *KeyEnvelopeRequest) Reset()                    { *m = KeyEnvelopeRequest{} }
+func (m *KeyEnvelopeRequest) String() string            { return proto.CompactTextString(m) }
+func (*KeyEnvelopeRequest) ProtoMessage()               {}
+func (*KeyEnvelopeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *KeyEnvelopeRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+// The GetKeyEnvelope response message.
+type KeyEnvelopeReply struct {
+	Envelope *KeyEnvelope       `protobuf:"bytes,1,opt,name=envelope" json:"envelope,omitempty"`
+	Error    StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *KeyEnvelopeReply) Reset()                    { *m = KeyEnvelopeReply{} }
+func (m *KeyEnvelopeReply) String() string            { return proto.CompactTextString(m) }
+func (*KeyEnvelopeReply) ProtoMessage()               {}
+func (*KeyEnvelopeReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *KeyEnvelopeReply) GetEnvelope() *KeyEnvelope {
+	if m != nil {
+		return m.Envelope
+	}
+	return nil
+}
+
+func (m *KeyEnvelopeReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+// The request to create or replace a user's key envelope. Like PutStore, the
+// envelope is replaced only if its current version is expected_version. (Use 0
+// if the user has no envelope.)
+type PutKeyEnvelopeRequest struct {
+	UserId          string       `protobuf:"bytes,1,opt,name=user_id,json=userId" json:"user_id,omitempty"`
+	Envelope        *KeyEnvelope `protobuf:"bytes,2,opt,name=envelope" json:"envelope,omitempty"`
+	ExpectedVersion int64        `protobuf:"varint,3,opt,name=expected_version,json=expectedVersion" json:"expected_version,omitempty"`
+}
+
+func (m *PutKeyEnvelopeRequest) Reset()                    { *m = PutKeyEnvelopeRequest{} }
+func (m *PutKeyEnvelopeRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutKeyEnvelopeRequest) ProtoMessage()               {}
+func (*PutKeyEnvelopeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *PutKeyEnvelopeRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *PutKeyEnvelopeRequest) GetEnvelope() *KeyEnvelope {
+	if m != nil {
+		return m.Envelope
+	}
+	return nil
+}
+
+func (m *PutKeyEnvelopeRequest) GetExpectedVersion() int64 {
+	if m != nil {
+		return m.ExpectedVersion
+	}
+	return 0
+}
+
+// The PutKeyEnvelope response message. On success, version is the new version
+// of the envelope.
+type PutKeyEnvelopeReply struct {
+	Version int64              `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Error   StoreProviderError `protobuf:"varint,2,opt,name=error,enum=pb.StoreProviderError" json:"error,omitempty"`
+}
+
+func (m *PutKeyEnvelopeReply) Reset()                    { *m = PutKeyEnvelopeReply{} }
+func (m *PutKeyEnvelopeReply) String() string            { return proto.CompactTextString(m) }
+func (*PutKeyEnvelopeReply) ProtoMessage()               {}
+func (*PutKeyEnvelopeReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *PutKeyEnvelopeReply) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *PutKeyEnvelopeReply) GetError() StoreProviderError {
+	if m != nil {
+		return m.Error
+	}
+	return StoreProviderError_OK
+}
+
+func init() {
+	proto.RegisterType((*KdfHeader)(nil), "pb.KdfHeader")
+	proto.RegisterType((*WrappedKey)(nil), "pb.WrappedKey")
+	proto.RegisterType((*KeyEnvelope)(nil), "pb.KeyEnvelope")
+	proto.RegisterType((*Params)(nil), "pb.Params")
+	proto.RegisterType((*Dict)(nil), "pb.Dict")
+	proto.RegisterType((*Store)(nil), "pb.Store")
+	proto.RegisterType((*Store_AdjList)(nil), "pb.Store.AdjList")
+	proto.RegisterType((*ShareProof)(nil), "pb.ShareProof")
+	proto.RegisterType((*StoreUpdate)(nil), "pb.StoreUpdate")
+	proto.RegisterType((*ShareRequest)(nil), "pb.ShareRequest")
+	proto.RegisterType((*ShareReply)(nil), "pb.ShareReply")
+	proto.RegisterType((*SharesRequest)(nil), "pb.SharesRequest")
+	proto.RegisterType((*SharesRequest_Index)(nil), "pb.SharesRequest.Index")
+	proto.RegisterType((*SharesReply)(nil), "pb.SharesReply")
+	proto.RegisterType((*SharesReply_Share)(nil), "pb.SharesReply.Share")
+	proto.RegisterType((*PirRequest)(nil), "pb.PirRequest")
+	proto.RegisterType((*PirReply)(nil), "pb.PirReply")
+	proto.RegisterType((*SimplePirHint)(nil), "pb.SimplePirHint")
+	proto.RegisterType((*SimplePirHintRequest)(nil), "pb.SimplePirHintRequest")
+	proto.RegisterType((*SimplePirHintReply)(nil), "pb.SimplePirHintReply")
+	proto.RegisterType((*ParamsRequest)(nil), "pb.ParamsRequest")
+	proto.RegisterType((*ParamsReply)(nil), "pb.ParamsReply")
+	proto.RegisterType((*OprfRequest)(nil), "pb.OprfRequest")
+	proto.RegisterType((*OprfReply)(nil), "pb.OprfReply")
+	proto.RegisterType((*PutStoreRequest)(nil), "pb.PutStoreRequest")
+	proto.RegisterType((*PutStoreReply)(nil), "pb.PutStoreReply")
+	proto.RegisterType((*DeleteStoreRequest)(nil), "pb.DeleteStoreRequest")
+	proto.RegisterType((*DeleteStoreReply)(nil), "pb.DeleteStoreReply")
+	proto.RegisterType((*StoreVersionRequest)(nil), "pb.StoreVersionRequest")
+	proto.RegisterType((*StoreVersionReply)(nil), "pb.StoreVersionReply")
+	proto.RegisterType((*IndexPageRequest)(nil), "pb.IndexPageRequest")
+	proto.RegisterType((*IndexPageReply)(nil), "pb.IndexPageReply")
+	proto.RegisterType((*KeyEnvelopeRequest)(nil), "pb.KeyEnvelopeRequest")
+	proto.RegisterType((*KeyEnvelopeReply)(nil), "pb.KeyEnvelopeReply")
+	proto.RegisterType((*PutKeyEnvelopeRequest)(nil), "pb.PutKeyEnvelopeRequest")
+	proto.RegisterType((*PutKeyEnvelopeReply)(nil), "pb.PutKeyEnvelopeReply")
+	proto.RegisterEnum("pb.OutputPadding", OutputPadding_name, OutputPadding_value)
+	proto.RegisterEnum("pb.KeySchedule", KeySchedule_name, KeySchedule_value)
+	proto.RegisterEnum("pb.PasswordKdf", PasswordKdf_name, PasswordKdf_value)
+	proto.RegisterEnum("pb.StoreProviderError", StoreProviderError_name, StoreProviderError_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for StoreProvider service
+
+type StoreProviderClient interface {
+	GetShare(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareReply, error)
+	GetShares(ctx context.Context, in *SharesRequest, opts ...grpc.CallOption) (*SharesReply, error)
+	GetPirShare(ctx context.Context, in *PirRequest, opts ...grpc.CallOption) (*PirReply, error)
+	GetSimplePirHint(ctx context.Context, in *SimplePirHintRequest, opts ...grpc.CallOption) (*SimplePirHintReply, error)
+	GetSimplePirShare(ctx context.Context, in *PirRequest, opts ...grpc.CallOption) (*PirReply, error)
+	GetParams(ctx context.Context, in *ParamsRequest, opts ...grpc.CallOption) (*ParamsReply, error)
+	EvaluateOprf(ctx context.Context, in *OprfRequest, opts ...grpc.CallOption) (*OprfReply, error)
+	PutStore(ctx context.Context, in *PutStoreRequest, opts ...grpc.CallOption) (*PutStoreReply, error)
+	DeleteStore(ctx context.Context, in *DeleteStoreRequest, opts ...grpc.CallOption) (*DeleteStoreReply, error)
+	GetStoreVersion(ctx context.Context, in *StoreVersionRequest, opts ...grpc.CallOption) (*StoreVersionReply, error)
+	GetKeyEnvelope(ctx context.Context, in *KeyEnvelopeRequest, opts ...grpc.CallOption) (*KeyEnvelopeReply, error)
+	PutKeyEnvelope(ctx context.Context, in *PutKeyEnvelopeRequest, opts ...grpc.CallOption) (*PutKeyEnvelopeReply, error)
+	GetIndexPage(ctx context.Context, in *IndexPageRequest, opts ...grpc.CallOption) (*IndexPageReply, error)
+}
+
+type storeProviderClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStoreProviderClient(cc *grpc.ClientConn) StoreProviderClient {
+	return &storeProviderClient{cc}
+}
+
+func (c *storeProviderClient) GetShare(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareReply, error) {
 	out := new(ShareReply)
 	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetShare", in, out, c.cc, opts...)
 	if err != nil {
@@ -373,6 +1622,42 @@ func (c *storeProviderClient) GetShare(ctx context.Context, in *ShareRequest, op
 	return out, nil
 }
 
+func (c *storeProviderClient) GetShares(ctx context.Context, in *SharesRequest, opts ...grpc.CallOption) (*SharesReply, error) {
+	out := new(SharesReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetShares", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetPirShare(ctx context.Context, in *PirRequest, opts ...grpc.CallOption) (*PirReply, error) {
+	out := new(PirReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetPirShare", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetSimplePirHint(ctx context.Context, in *SimplePirHintRequest, opts ...grpc.CallOption) (*SimplePirHintReply, error) {
+	out := new(SimplePirHintReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetSimplePirHint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetSimplePirShare(ctx context.Context, in *PirRequest, opts ...grpc.CallOption) (*PirReply, error) {
+	out := new(PirReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetSimplePirShare", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storeProviderClient) GetParams(ctx context.Context, in *ParamsRequest, opts ...grpc.CallOption) (*ParamsReply, error) {
 	out := new(ParamsReply)
 	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetParams", in, out, c.cc, opts...)
@@ -382,11 +1667,85 @@ func (c *storeProviderClient) GetParams(ctx context.Context, in *ParamsRequest,
 	return out, nil
 }
 
+func (c *storeProviderClient) EvaluateOprf(ctx context.Context, in *OprfRequest, opts ...grpc.CallOption) (*OprfReply, error) {
+	out := new(OprfReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/EvaluateOprf", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) PutStore(ctx context.Context, in *PutStoreRequest, opts ...grpc.CallOption) (*PutStoreReply, error) {
+	out := new(PutStoreReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/PutStore", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) DeleteStore(ctx context.Context, in *DeleteStoreRequest, opts ...grpc.CallOption) (*DeleteStoreReply, error) {
+	out := new(DeleteStoreReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/DeleteStore", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetStoreVersion(ctx context.Context, in *StoreVersionRequest, opts ...grpc.CallOption) (*StoreVersionReply, error) {
+	out := new(StoreVersionReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetStoreVersion", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetKeyEnvelope(ctx context.Context, in *KeyEnvelopeRequest, opts ...grpc.CallOption) (*KeyEnvelopeReply, error) {
+	out := new(KeyEnvelopeReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetKeyEnvelope", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) PutKeyEnvelope(ctx context.Context, in *PutKeyEnvelopeRequest, opts ...grpc.CallOption) (*PutKeyEnvelopeReply, error) {
+	out := new(PutKeyEnvelopeReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/PutKeyEnvelope", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeProviderClient) GetIndexPage(ctx context.Context, in *IndexPageRequest, opts ...grpc.CallOption) (*IndexPageReply, error) {
+	out := new(IndexPageReply)
+	err := grpc.Invoke(ctx, "/pb.StoreProvider/GetIndexPage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for StoreProvider service
 
 type StoreProviderServer interface {
 	GetShare(context.Context, *ShareRequest) (*ShareReply, error)
+	GetShares(context.Context, *SharesRequest) (*SharesReply, error)
+	GetPirShare(context.Context, *PirRequest) (*PirReply, error)
+	GetSimplePirHint(context.Context, *SimplePirHintRequest) (*SimplePirHintReply, error)
+	GetSimplePirShare(context.Context, *PirRequest) (*PirReply, error)
 	GetParams(context.Context, *ParamsRequest) (*ParamsReply, error)
+	EvaluateOprf(context.Context, *OprfRequest) (*OprfReply, error)
+	PutStore(context.Context, *PutStoreRequest) (*PutStoreReply, error)
+	DeleteStore(context.Context, *DeleteStoreRequest) (*DeleteStoreReply, error)
+	GetStoreVersion(context.Context, *StoreVersionRequest) (*StoreVersionReply, error)
+	GetKeyEnvelope(context.Context, *KeyEnvelopeRequest) (*KeyEnvelopeReply, error)
+	PutKeyEnvelope(context.Context, *PutKeyEnvelopeRequest) (*PutKeyEnvelopeReply, error)
+	GetIndexPage(context.Context, *IndexPageRequest) (*IndexPageReply, error)
 }
 
 func RegisterStoreProviderServer(s *grpc.Server, srv StoreProviderServer) {
@@ -411,6 +1770,78 @@ func _StoreProvider_GetShare_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StoreProvider_GetShares_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SharesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetShares(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetShares",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetShares(ctx, req.(*SharesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetPirShare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetPirShare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetPirShare",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetPirShare(ctx, req.(*PirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetSimplePirHint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimplePirHintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetSimplePirHint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetSimplePirHint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetSimplePirHint(ctx, req.(*SimplePirHintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetSimplePirShare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetSimplePirShare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetSimplePirShare",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetSimplePirShare(ctx, req.(*PirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StoreProvider_GetParams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ParamsRequest)
 	if err := dec(in); err != nil {
@@ -429,6 +1860,132 @@ func _StoreProvider_GetParams_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StoreProvider_EvaluateOprf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OprfRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).EvaluateOprf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/EvaluateOprf",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).EvaluateOprf(ctx, req.(*OprfRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_PutStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).PutStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/PutStore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).PutStore(ctx, req.(*PutStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_DeleteStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).DeleteStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/DeleteStore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).DeleteStore(ctx, req.(*DeleteStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetStoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetStoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetStoreVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetStoreVersion(ctx, req.(*StoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetKeyEnvelope_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyEnvelopeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetKeyEnvelope(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetKeyEnvelope",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetKeyEnvelope(ctx, req.(*KeyEnvelopeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_PutKeyEnvelope_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutKeyEnvelopeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).PutKeyEnvelope(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/PutKeyEnvelope",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).PutKeyEnvelope(ctx, req.(*PutKeyEnvelopeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreProvider_GetIndexPage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IndexPageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreProviderServer).GetIndexPage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.StoreProvider/GetIndexPage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreProviderServer).GetIndexPage(ctx, req.(*IndexPageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _StoreProvider_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.StoreProvider",
 	HandlerType: (*StoreProviderServer)(nil),
@@ -437,10 +1994,54 @@ var _StoreProvider_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetShare",
 			Handler:    _StoreProvider_GetShare_Handler,
 		},
+		{
+			MethodName: "GetShares",
+			Handler:    _StoreProvider_GetShares_Handler,
+		},
+		{
+			MethodName: "GetPirShare",
+			Handler:    _StoreProvider_GetPirShare_Handler,
+		},
+		{
+			MethodName: "GetSimplePirHint",
+			Handler:    _StoreProvider_GetSimplePirHint_Handler,
+		},
+		{
+			MethodName: "GetSimplePirShare",
+			Handler:    _StoreProvider_GetSimplePirShare_Handler,
+		},
 		{
 			MethodName: "GetParams",
 			Handler:    _StoreProvider_GetParams_Handler,
 		},
+		{
+			MethodName: "EvaluateOprf",
+			Handler:    _StoreProvider_EvaluateOprf_Handler,
+		},
+		{
+			MethodName: "PutStore",
+			Handler:    _StoreProvider_PutStore_Handler,
+		},
+		{
+			MethodName: "DeleteStore",
+			Handler:    _StoreProvider_DeleteStore_Handler,
+		},
+		{
+			MethodName: "GetStoreVersion",
+			Handler:    _StoreProvider_GetStoreVersion_Handler,
+		},
+		{
+			MethodName: "GetKeyEnvelope",
+			Handler:    _StoreProvider_GetKeyEnvelope_Handler,
+		},
+		{
+			MethodName: "PutKeyEnvelope",
+			Handler:    _StoreProvider_PutKeyEnvelope_Handler,
+		},
+		{
+			MethodName: "GetIndexPage",
+			Handler:    _StoreProvider_GetIndexPage_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "store.proto",
@@ -449,40 +2050,128 @@ var _StoreProvider_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("store.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 549 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0x51, 0x6e, 0xd3, 0x40,
-	0x10, 0xad, 0xe3, 0xd8, 0x71, 0x26, 0x6e, 0x70, 0x57, 0xa8, 0x58, 0x85, 0x4a, 0x96, 0xbf, 0x2c,
-	0x54, 0x45, 0x22, 0x9c, 0x20, 0x6d, 0x42, 0x15, 0x51, 0x92, 0x6a, 0xd3, 0x0a, 0xfe, 0xac, 0x75,
-	0xbc, 0x0a, 0xae, 0x9c, 0xd8, 0xac, 0xd7, 0x34, 0x39, 0x19, 0xb7, 0xe0, 0x4c, 0x68, 0xd6, 0x9b,
-	0x12, 0xc4, 0x07, 0xf4, 0xcb, 0x33, 0xef, 0x8d, 0xdf, 0xcc, 0x9b, 0xd1, 0x42, 0xaf, 0x92, 0x85,
-	0xe0, 0x83, 0x52, 0x14, 0xb2, 0x20, 0xad, 0x32, 0x09, 0x7f, 0x1a, 0x60, 0xdf, 0x32, 0xc1, 0xd6,
-	0x15, 0x79, 0x0d, 0x5d, 0xc9, 0x92, 0x9c, 0xc7, 0x39, 0xdf, 0xf8, 0x46, 0x60, 0x44, 0x16, 0x75,
-	0x14, 0x70, 0xc3, 0x37, 0x24, 0x02, 0x6f, 0xcd, 0xb6, 0x71, 0x51, 0xcb, 0xb2, 0x96, 0x71, 0xb2,
-	0x93, 0xbc, 0xf2, 0x5b, 0xaa, 0xa6, 0xbf, 0x66, 0xdb, 0xb9, 0x82, 0x2f, 0x11, 0x45, 0x19, 0x51,
-	0x3c, 0xea, 0x12, 0xb3, 0x91, 0x11, 0xc5, 0xe3, 0x13, 0x29, 0xd9, 0x4a, 0x93, 0xed, 0x7d, 0x8f,
-	0x55, 0x43, 0x9e, 0x03, 0x54, 0x2c, 0xdf, 0xab, 0x5b, 0x8a, 0xed, 0x22, 0xd2, 0xd0, 0x04, 0xda,
-	0x98, 0xf8, 0x76, 0x60, 0x44, 0x2e, 0x55, 0x31, 0xf1, 0xc0, 0x2c, 0x59, 0xea, 0x77, 0x02, 0x23,
-	0x72, 0x28, 0x86, 0x21, 0x85, 0xf6, 0x38, 0x5b, 0x4a, 0x12, 0x82, 0x5d, 0x2a, 0x5f, 0xca, 0x4a,
-	0x6f, 0x08, 0x83, 0x32, 0x19, 0x34, 0x4e, 0xa9, 0x66, 0xc8, 0x4b, 0xb0, 0x94, 0x41, 0xe5, 0xc4,
-	0xa5, 0x4d, 0x82, 0x9a, 0x59, 0xba, 0xf5, 0xcd, 0xc0, 0x8c, 0x2c, 0x8a, 0x61, 0xf8, 0xc3, 0x00,
-	0x6b, 0x81, 0x8b, 0x23, 0x17, 0xe0, 0xb0, 0xf4, 0x21, 0xce, 0xb3, 0x4a, 0xfa, 0x46, 0x60, 0x46,
-	0xbd, 0xe1, 0x09, 0xea, 0x2a, 0x72, 0x30, 0x4a, 0x1f, 0x6e, 0xb2, 0x4a, 0xd2, 0x0e, 0x6b, 0x02,
-	0x9c, 0x78, 0x53, 0xa4, 0x28, 0x8f, 0x52, 0x2a, 0x26, 0xaf, 0xa0, 0x83, 0xdf, 0x78, 0x29, 0xf5,
-	0x72, 0x6c, 0x4c, 0xaf, 0x24, 0x39, 0x05, 0xbb, 0xe2, 0x2c, 0xe7, 0xa9, 0xdf, 0x0e, 0xcc, 0xc8,
-	0xa5, 0x3a, 0x23, 0x6f, 0xa0, 0x9d, 0x66, 0x4b, 0xa9, 0xf6, 0xd1, 0x1b, 0x3a, 0xd8, 0x0e, 0x0d,
-	0x52, 0x85, 0x9e, 0x9d, 0x43, 0x67, 0xf4, 0xbb, 0x1b, 0x4f, 0x57, 0x5c, 0xcd, 0x65, 0x51, 0x15,
-	0x87, 0x57, 0xe0, 0x2e, 0xbe, 0x32, 0xc1, 0x29, 0xff, 0x56, 0xf3, 0x4a, 0x62, 0xf7, 0xba, 0xe2,
-	0x22, 0xce, 0x52, 0xb5, 0x96, 0x2e, 0xb5, 0x31, 0x9d, 0xa6, 0xc4, 0x05, 0x63, 0xab, 0x0f, 0x6a,
-	0x6c, 0x31, 0xdb, 0xe9, 0xf1, 0x8c, 0x5d, 0xf8, 0x19, 0x40, 0x8b, 0x94, 0xf9, 0x0e, 0x4f, 0x58,
-	0xd6, 0x49, 0x5c, 0x21, 0xa2, 0x44, 0x5c, 0xea, 0x94, 0x75, 0xa2, 0x2a, 0xc8, 0x05, 0x58, 0x5c,
-	0x88, 0x42, 0x28, 0xa9, 0xfe, 0xf0, 0xf4, 0x69, 0x39, 0xb7, 0xa2, 0xf8, 0x9e, 0xa5, 0x5c, 0x4c,
-	0x90, 0xa5, 0x4d, 0x51, 0x18, 0xc1, 0xb1, 0xbe, 0xc8, 0x3f, 0xc6, 0x0b, 0x63, 0xe8, 0xed, 0x2b,
-	0x71, 0x86, 0xff, 0x39, 0xee, 0xb3, 0x46, 0x79, 0x3b, 0x05, 0xf2, 0x37, 0x49, 0x6c, 0x68, 0xcd,
-	0x3f, 0x7a, 0x47, 0xc4, 0x05, 0xe7, 0x72, 0x34, 0x8e, 0xef, 0x17, 0x13, 0xea, 0x19, 0xa4, 0x0b,
-	0xd6, 0x74, 0x36, 0x9e, 0x7c, 0xf1, 0x5a, 0x84, 0x40, 0x7f, 0x7a, 0x37, 0xf9, 0x14, 0xcf, 0xe6,
-	0x77, 0xf1, 0x87, 0xf9, 0xfd, 0x6c, 0xec, 0x99, 0x43, 0x01, 0xc7, 0x7f, 0x48, 0x91, 0x01, 0x38,
-	0xd7, 0x5c, 0x36, 0x0b, 0xf2, 0xd4, 0x18, 0x07, 0x27, 0x39, 0xeb, 0x1f, 0x20, 0x65, 0xbe, 0x0b,
-	0x8f, 0xc8, 0x3b, 0xe8, 0x5e, 0x73, 0xa9, 0x5f, 0xe5, 0xc9, 0x81, 0x35, 0xfd, 0xc7, 0x8b, 0x43,
-	0x48, 0xfd, 0x92, 0xd8, 0xea, 0x45, 0xbf, 0xff, 0x15, 0x00, 0x00, 0xff, 0xff, 0x40, 0x41, 0xc6,
-	0xa2, 0xe0, 0x03, 0x00, 0x00,
+	// 1966 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0xcd, 0x73, 0xe3, 0x48,
+	0x15, 0x8f, 0x2c, 0xcb, 0x96, 0x9f, 0x3f, 0xa2, 0x74, 0x32, 0x19, 0xad, 0x61, 0x76, 0xb3, 0xa2,
+	0x28, 0xc2, 0xb0, 0x13, 0x58, 0x2f, 0x3b, 0xb5, 0xb5, 0x14, 0x05, 0x9e, 0xd8, 0x49, 0x5c, 0xc9,
+	0xd8, 0xa6, 0xed, 0x6c, 0x12, 0x28, 0x4a, 0x25, 0x5b, 0x9d, 0x44, 0x63, 0xd9, 0xd2, 0x4a, 0x72,
+	0x62, 0xef, 0x89, 0x2a, 0x2e, 0x5c, 0x38, 0x73, 0xe1, 0xce, 0x9d, 0x33, 0xff, 0x07, 0xff, 0x0a,
+	0x07, 0x0e, 0x54, 0x7f, 0xc8, 0x96, 0x9c, 0x80, 0x27, 0xc5, 0x9c, 0xdc, 0xef, 0xa3, 0x5f, 0xff,
+	0xde, 0x47, 0xf7, 0x7b, 0x32, 0x14, 0xc3, 0xc8, 0x0b, 0xc8, 0x81, 0x1f, 0x78, 0x91, 0x87, 0x32,
+	0xfe, 0xc0, 0xf8, 0xa7, 0x04, 0x85, 0x53, 0xfb, 0xfa, 0x84, 0x58, 0x36, 0x09, 0xd0, 0xa7, 0x20,
+	0x8f, 0xec, 0x6b, 0x5d, 0xda, 0x93, 0xf6, 0x2b, 0xb5, 0xcd, 0x03, 0x7f, 0x70, 0xd0, 0xb5, 0xc2,
+	0xf0, 0xde, 0x0b, 0xec, 0x53, 0xfb, 0x1a, 0x53, 0x19, 0x42, 0x90, 0x0d, 0x2d, 0x37, 0xd2, 0x33,
+	0x7b, 0xd2, 0x7e, 0x09, 0xb3, 0x35, 0xfa, 0x18, 0xc0, 0x89, 0x48, 0x60, 0x45, 0x8e, 0x37, 0x09,
+	0x75, 0x79, 0x4f, 0xda, 0x57, 0x70, 0x82, 0x83, 0x5e, 0x00, 0x8c, 0xc9, 0xd8, 0x0b, 0xe6, 0xe6,
+	0xc8, 0x19, 0xe8, 0x59, 0x26, 0x2f, 0x70, 0xce, 0xa9, 0x33, 0x40, 0x7b, 0x50, 0xf4, 0xad, 0xc0,
+	0x72, 0x5d, 0xe2, 0x3a, 0xe1, 0x58, 0x57, 0x98, 0x3c, 0xc9, 0x42, 0xdb, 0xa0, 0xb8, 0xde, 0x8d,
+	0x39, 0xd1, 0x73, 0x4c, 0x96, 0x75, 0xbd, 0x9b, 0x36, 0xb5, 0x3a, 0x70, 0xbd, 0xe1, 0xc8, 0x0c,
+	0x9d, 0xef, 0x88, 0x9e, 0xe7, 0x56, 0x19, 0xa7, 0xe7, 0x7c, 0x47, 0x8c, 0x3f, 0x48, 0x00, 0x17,
+	0x81, 0xe5, 0xfb, 0xc4, 0x3e, 0x25, 0x73, 0x54, 0x81, 0x8c, 0x63, 0x33, 0xcf, 0x0a, 0x38, 0xe3,
+	0xd8, 0xe8, 0x33, 0x80, 0x91, 0x7d, 0x6d, 0xde, 0x32, 0xc7, 0x99, 0x37, 0xc5, 0x5a, 0x99, 0x7a,
+	0xbc, 0x88, 0x06, 0x2e, 0x8c, 0xe2, 0x25, 0xda, 0x01, 0x65, 0xe2, 0x4d, 0x86, 0x84, 0x39, 0x57,
+	0xc2, 0x9c, 0xa0, 0x7e, 0x0f, 0x1d, 0xff, 0x96, 0x04, 0x11, 0x99, 0x45, 0xcc, 0xaf, 0x12, 0x4e,
+	0x70, 0x8c, 0x16, 0x14, 0x4f, 0xc9, 0xbc, 0x39, 0xb9, 0x23, 0xae, 0xe7, 0x13, 0xb4, 0x07, 0xf2,
+	0x88, 0xcc, 0x75, 0x69, 0x4f, 0xde, 0x2f, 0xd6, 0x2a, 0xf4, 0xac, 0x25, 0x3e, 0x4c, 0x45, 0x48,
+	0x87, 0xfc, 0x1d, 0x09, 0x42, 0xc7, 0x9b, 0x30, 0x44, 0x32, 0x8e, 0x49, 0xe3, 0xef, 0x32, 0xe4,
+	0xba, 0x56, 0x60, 0x8d, 0x43, 0xf4, 0x3d, 0x28, 0x44, 0xd6, 0xc0, 0x25, 0xa6, 0x4b, 0x26, 0xcc,
+	0x21, 0x05, 0xab, 0x8c, 0x71, 0x46, 0x26, 0x68, 0x1f, 0xb4, 0xb1, 0x35, 0x33, 0xbd, 0x69, 0xe4,
+	0x4f, 0x23, 0x73, 0x30, 0x8f, 0x48, 0xc8, 0x4c, 0x29, 0xb8, 0x32, 0xb6, 0x66, 0x1d, 0xc6, 0x7e,
+	0x43, 0xb9, 0xd4, 0x4c, 0xe0, 0xdd, 0x0b, 0x15, 0x9e, 0x33, 0x35, 0xf0, 0xee, 0x17, 0xc2, 0xc8,
+	0xba, 0x11, 0xc2, 0x6c, 0x7c, 0xc6, 0x0d, 0x17, 0xbe, 0x00, 0xa0, 0x69, 0x17, 0x52, 0x9e, 0xae,
+	0x02, 0xe5, 0x70, 0x71, 0x5c, 0x21, 0xb9, 0x44, 0x85, 0x68, 0x20, 0xfb, 0x96, 0xcd, 0x92, 0xa4,
+	0x62, 0xba, 0x44, 0x5f, 0x41, 0x45, 0x80, 0xf4, 0x2d, 0xdb, 0x76, 0x26, 0x37, 0xba, 0xca, 0xaa,
+	0x6e, 0x8b, 0xc6, 0x85, 0xe3, 0xec, 0x72, 0x01, 0x2e, 0x7b, 0x49, 0x12, 0x1d, 0xc0, 0x36, 0xdd,
+	0x42, 0xec, 0xb4, 0x97, 0x05, 0x86, 0x63, 0x8b, 0x8b, 0x92, 0x8e, 0x26, 0x82, 0x0a, 0xa9, 0xa0,
+	0x52, 0xc9, 0x30, 0x20, 0x56, 0x44, 0x6c, 0xbd, 0xc8, 0x25, 0x82, 0xa4, 0x78, 0xc7, 0xd6, 0x50,
+	0x2f, 0x31, 0x17, 0xe8, 0x12, 0xd5, 0xa0, 0x34, 0x22, 0x73, 0x33, 0x1c, 0xde, 0x12, 0x7b, 0xea,
+	0x12, 0xbd, 0xbc, 0xbc, 0x23, 0xa7, 0x64, 0xde, 0x13, 0x6c, 0x5c, 0x1c, 0x2d, 0x09, 0x03, 0x43,
+	0xb6, 0xe1, 0x0c, 0x23, 0x64, 0x40, 0xce, 0x67, 0xb9, 0x63, 0xe9, 0x2a, 0xd6, 0x80, 0xdf, 0x2c,
+	0xca, 0xc1, 0x42, 0x42, 0x2b, 0x8c, 0x25, 0x51, 0x5c, 0x2c, 0x4e, 0x50, 0x1c, 0x8e, 0x3d, 0xd3,
+	0xe5, 0x3d, 0x79, 0x5f, 0xc1, 0x74, 0x69, 0xfc, 0x2d, 0x03, 0x4a, 0x8f, 0x5e, 0x62, 0xf4, 0x19,
+	0xa8, 0x96, 0xfd, 0xce, 0x74, 0x9d, 0x30, 0x12, 0x35, 0xc5, 0x62, 0xc7, 0x84, 0x07, 0x75, 0xfb,
+	0xdd, 0x99, 0x13, 0x46, 0x38, 0x6f, 0xf1, 0x05, 0xcd, 0xca, 0xc4, 0xb3, 0xa9, 0x79, 0x6a, 0x8a,
+	0xad, 0xd1, 0x73, 0xc8, 0xd3, 0x5f, 0x73, 0x18, 0x89, 0x02, 0xc8, 0x51, 0xf2, 0x30, 0x42, 0xbb,
+	0x90, 0x0b, 0x89, 0xe5, 0x12, 0x5b, 0xcf, 0xee, 0xc9, 0xfb, 0x25, 0x2c, 0x28, 0xf4, 0x7d, 0xc8,
+	0xda, 0xce, 0x30, 0x62, 0x39, 0x2f, 0xd6, 0x54, 0x7a, 0x1c, 0x75, 0x10, 0x33, 0x2e, 0x05, 0x3b,
+	0x8c, 0x02, 0x71, 0x47, 0xe9, 0x32, 0x19, 0xfa, 0x7c, 0x3a, 0xf4, 0xe9, 0xeb, 0xa7, 0xae, 0xbf,
+	0x7e, 0xce, 0xc4, 0x26, 0x33, 0xbd, 0xc0, 0xe0, 0x70, 0xa2, 0xfa, 0x02, 0xf2, 0xf5, 0xa5, 0x77,
+	0xc4, 0xbe, 0x21, 0x2c, 0x0e, 0x0a, 0x66, 0x6b, 0xe3, 0xdf, 0x12, 0x40, 0xef, 0xd6, 0x0a, 0x48,
+	0x37, 0xf0, 0xbc, 0x6b, 0xfa, 0x86, 0xd0, 0x7a, 0x9f, 0xb1, 0x1c, 0x94, 0x70, 0x36, 0xf0, 0xee,
+	0x2f, 0xd1, 0x33, 0x9a, 0x99, 0xe8, 0xd6, 0x9c, 0xb1, 0xb8, 0x94, 0xb0, 0x42, 0xa9, 0xcb, 0x58,
+	0x77, 0xae, 0xcb, 0x0b, 0xdd, 0xab, 0x85, 0xee, 0x5c, 0xcf, 0x2e, 0x75, 0xaf, 0xa8, 0x2e, 0x4d,
+	0x03, 0xc7, 0xa6, 0xe0, 0xac, 0x65, 0xbf, 0xbb, 0x8c, 0x99, 0x73, 0x1d, 0x16, 0xcc, 0x2b, 0xea,
+	0xc5, 0xb5, 0x37, 0x9d, 0xd8, 0x2c, 0x7c, 0x2a, 0xe6, 0x04, 0xbb, 0x4d, 0x2c, 0xba, 0x26, 0xcd,
+	0x74, 0x4e, 0xdc, 0x26, 0xc6, 0x69, 0xd9, 0xb3, 0x44, 0x2a, 0xf2, 0x0c, 0x8b, 0xa0, 0xd0, 0x27,
+	0xf4, 0xd1, 0x8c, 0x6e, 0x4d, 0x21, 0x54, 0x19, 0x24, 0xa0, 0xac, 0x1e, 0xe3, 0x18, 0xff, 0x92,
+	0xa0, 0xc8, 0x6a, 0xe1, 0xdc, 0xb7, 0xad, 0x88, 0xc4, 0xd9, 0x91, 0x96, 0xd9, 0xf9, 0x08, 0xd4,
+	0x09, 0x99, 0x45, 0x26, 0x65, 0xf3, 0x37, 0x22, 0x4f, 0xe9, 0xc3, 0x28, 0x58, 0x24, 0x5a, 0x7e,
+	0x34, 0xd1, 0xbb, 0x90, 0x73, 0x26, 0x21, 0x09, 0xf8, 0x9b, 0xa7, 0x62, 0x41, 0xa1, 0x12, 0x48,
+	0x33, 0xf1, 0x1e, 0x48, 0x33, 0x4a, 0xcd, 0x85, 0x3f, 0xd2, 0x7c, 0xc5, 0xcd, 0xfc, 0x9e, 0xfc,
+	0xdf, 0xdc, 0x54, 0x53, 0x15, 0xf7, 0x02, 0x80, 0x25, 0xdb, 0xf4, 0xad, 0x1b, 0x22, 0x42, 0x5c,
+	0x60, 0x9c, 0xae, 0x75, 0x43, 0x96, 0x85, 0x01, 0x89, 0xc2, 0x30, 0x86, 0x50, 0x62, 0x89, 0xc7,
+	0xe4, 0xdb, 0x29, 0x09, 0x23, 0x5a, 0xe7, 0xd3, 0x90, 0x04, 0xe6, 0xa2, 0x01, 0xe4, 0x28, 0xd9,
+	0xb2, 0x39, 0xe0, 0x4c, 0x0a, 0xb0, 0x1c, 0x03, 0xfe, 0x18, 0xe0, 0x8e, 0x04, 0xce, 0xb5, 0xc3,
+	0x6e, 0x25, 0x77, 0x34, 0xc1, 0x31, 0x2e, 0x44, 0x75, 0x61, 0xe2, 0xbb, 0x73, 0xfa, 0x60, 0xfa,
+	0xd3, 0x81, 0x19, 0x52, 0x8e, 0xa8, 0x30, 0xd5, 0x9f, 0x0e, 0x98, 0x06, 0xfa, 0x0c, 0x14, 0x12,
+	0x04, 0x1e, 0x8f, 0x72, 0xa5, 0xb6, 0xbb, 0xb8, 0xa6, 0xdd, 0xc0, 0xbb, 0x73, 0x6c, 0x12, 0x34,
+	0xa9, 0x14, 0x73, 0x25, 0xe3, 0xaf, 0x12, 0x94, 0xd9, 0xbe, 0x70, 0x2d, 0xfe, 0x57, 0xb1, 0xfb,
+	0x19, 0x76, 0xff, 0x9f, 0x33, 0xc3, 0xc9, 0xad, 0x07, 0x2d, 0x2a, 0x16, 0x71, 0x59, 0x71, 0x49,
+	0x5e, 0x75, 0xa9, 0xfa, 0x03, 0x50, 0x98, 0x3e, 0x8f, 0x8b, 0x94, 0x8a, 0x8b, 0x88, 0xd2, 0xdc,
+	0xf8, 0x07, 0xad, 0x2b, 0x71, 0x06, 0xf5, 0xfc, 0x27, 0xa0, 0xc4, 0x5e, 0x53, 0x0c, 0xcf, 0x92,
+	0x18, 0x7c, 0x77, 0xce, 0xd7, 0x58, 0x09, 0x9f, 0x1e, 0x89, 0x2a, 0x06, 0x85, 0x07, 0xf0, 0x03,
+	0x46, 0xf7, 0x17, 0x00, 0x5d, 0x27, 0x58, 0x1b, 0xd9, 0x1d, 0x50, 0xbe, 0x9d, 0x92, 0x60, 0x1e,
+	0xbf, 0x0b, 0x8c, 0x30, 0xce, 0x41, 0x65, 0x9b, 0x1f, 0xc9, 0xb8, 0xfc, 0x7f, 0x60, 0xba, 0x87,
+	0x72, 0xcf, 0x19, 0xfb, 0x2e, 0xe9, 0x3a, 0xc1, 0x89, 0x33, 0x61, 0xcf, 0x59, 0x48, 0x88, 0x1d,
+	0x3f, 0x55, 0x74, 0x8d, 0x3e, 0x85, 0x52, 0x40, 0x86, 0x5e, 0x60, 0xa7, 0xba, 0x7a, 0x91, 0xf3,
+	0x12, 0x5d, 0x3b, 0x9e, 0x0c, 0xe4, 0x95, 0xc9, 0x00, 0x41, 0xf6, 0xd6, 0x99, 0xc4, 0x63, 0x0a,
+	0x5b, 0x1b, 0x3f, 0x85, 0x9d, 0xd4, 0xc1, 0xeb, 0xc2, 0x62, 0x38, 0x80, 0x56, 0x36, 0xd0, 0x50,
+	0xfc, 0x50, 0x98, 0xe6, 0xdd, 0x8d, 0x77, 0xa1, 0x94, 0x16, 0x13, 0x3f, 0x31, 0x28, 0xfb, 0x50,
+	0x16, 0x2d, 0x72, 0x1d, 0xa8, 0x3f, 0x4b, 0x50, 0x8c, 0x55, 0x29, 0x9c, 0xf7, 0x69, 0xb7, 0x4f,
+	0xc2, 0xb2, 0xd2, 0xad, 0xe4, 0xff, 0xdd, 0xad, 0x8c, 0x0e, 0x14, 0x3b, 0x7e, 0x70, 0xbd, 0xb6,
+	0xc6, 0x7e, 0x04, 0x9b, 0x03, 0x97, 0xde, 0x4c, 0xdb, 0x24, 0x2e, 0x19, 0x93, 0x49, 0x3c, 0x55,
+	0x57, 0x04, 0xbb, 0xc9, 0xb9, 0xc6, 0x35, 0x14, 0xb8, 0x41, 0x7e, 0xdf, 0xb6, 0xc8, 0x9d, 0xe5,
+	0x4e, 0xad, 0x28, 0xb1, 0x8f, 0x17, 0x8a, 0xb6, 0x10, 0x88, 0x9d, 0x4f, 0x0c, 0xf9, 0x1d, 0x6c,
+	0x76, 0xa7, 0x11, 0x93, 0xaf, 0x05, 0xff, 0x09, 0x28, 0xec, 0x5b, 0x42, 0x8c, 0xce, 0x85, 0x85,
+	0x65, 0xcc, 0xf9, 0xe8, 0xc7, 0xa0, 0x91, 0x99, 0x4f, 0x86, 0x14, 0x66, 0x3c, 0x04, 0xc8, 0x6c,
+	0x08, 0xd8, 0x8c, 0xf9, 0xdf, 0x70, 0xb6, 0x71, 0x01, 0xe5, 0xe5, 0xb9, 0xd4, 0xc7, 0xc4, 0xdc,
+	0x20, 0xad, 0xce, 0x0d, 0x4f, 0x71, 0xe8, 0x12, 0x50, 0x83, 0xb8, 0x24, 0x22, 0xef, 0xe7, 0xd3,
+	0x63, 0x90, 0x33, 0x8f, 0x43, 0xfe, 0x2d, 0x68, 0x29, 0xcb, 0x1f, 0x12, 0xf5, 0x01, 0x6c, 0x33,
+	0xa1, 0x38, 0x6b, 0x6d, 0xfd, 0xff, 0x0e, 0xb6, 0xd2, 0xfa, 0x1f, 0x12, 0xcc, 0xaf, 0x40, 0x6b,
+	0xc5, 0xed, 0x76, 0x6d, 0x00, 0x11, 0x64, 0x59, 0x9f, 0xe6, 0x6f, 0x13, 0x5b, 0x1b, 0x23, 0xa8,
+	0x24, 0x0c, 0x50, 0x68, 0xb1, 0x96, 0x78, 0xdd, 0xe8, 0x9a, 0x9a, 0xa4, 0xbf, 0x74, 0x14, 0xe5,
+	0x9b, 0x73, 0x94, 0x3c, 0x4c, 0x54, 0xb0, 0xfc, 0x3e, 0x68, 0x5f, 0x01, 0x4a, 0x7c, 0x71, 0xad,
+	0x8d, 0xdc, 0x18, 0xb4, 0x94, 0x3a, 0xbf, 0x5f, 0x2a, 0x11, 0x0c, 0xf1, 0x7e, 0xc4, 0x43, 0xfe,
+	0x42, 0x6f, 0xa1, 0xf0, 0xc4, 0x58, 0xfe, 0x49, 0x82, 0x67, 0xdd, 0x69, 0xf4, 0x04, 0x84, 0x29,
+	0x34, 0x99, 0x75, 0x68, 0x9e, 0x70, 0xe5, 0x7e, 0x0f, 0xdb, 0xab, 0x48, 0x3e, 0x60, 0xd5, 0xbc,
+	0x7c, 0x0d, 0xe5, 0xd4, 0x37, 0x1c, 0x52, 0x21, 0xdb, 0xee, 0xb4, 0x9b, 0xda, 0x06, 0x2a, 0x80,
+	0x72, 0xd4, 0xba, 0x6c, 0x36, 0x34, 0x09, 0x69, 0x50, 0xea, 0x76, 0x2e, 0x9a, 0xd8, 0xec, 0x1c,
+	0x99, 0xfd, 0x8b, 0x8e, 0x96, 0x79, 0xd9, 0x61, 0x5f, 0xcc, 0xf1, 0x07, 0x14, 0x55, 0x38, 0x6b,
+	0x1e, 0xd7, 0x0f, 0xaf, 0xcc, 0x5e, 0xf7, 0xac, 0xd5, 0xd7, 0x36, 0xd0, 0x2e, 0xa0, 0x93, 0xd3,
+	0xc6, 0x91, 0xd9, 0x3b, 0xa9, 0xd7, 0xbe, 0x7c, 0x6d, 0xd6, 0x9b, 0xbd, 0xcf, 0x6b, 0x5f, 0x69,
+	0xd2, 0x23, 0xfc, 0xda, 0x97, 0xaf, 0xb5, 0xcc, 0xcb, 0xaf, 0xa1, 0x98, 0xf8, 0x0b, 0x03, 0x6d,
+	0x41, 0xb9, 0xfb, 0xe6, 0xb4, 0x71, 0x54, 0x13, 0x8a, 0xda, 0x06, 0x02, 0xc8, 0xf5, 0x0e, 0xf1,
+	0x55, 0xb7, 0xaf, 0x49, 0xa8, 0x04, 0x6a, 0x1d, 0x1f, 0x77, 0xda, 0xb5, 0x56, 0x43, 0xcb, 0xbc,
+	0xfc, 0x8b, 0x04, 0xe8, 0xa1, 0x8b, 0x28, 0x07, 0x99, 0xce, 0xa9, 0xb6, 0x41, 0x95, 0xdf, 0xd4,
+	0x1b, 0xe6, 0x79, 0xaf, 0x89, 0x35, 0x89, 0xba, 0xd5, 0x6a, 0x37, 0x9a, 0x97, 0x5a, 0x06, 0x21,
+	0xa8, 0xb4, 0xfa, 0xcd, 0xb7, 0x66, 0xbb, 0xd3, 0x37, 0x8f, 0x3a, 0xe7, 0xed, 0x86, 0x26, 0xa3,
+	0x4d, 0x28, 0x52, 0x65, 0xdc, 0xfc, 0xcd, 0x79, 0xb3, 0xd7, 0xd7, 0xb2, 0xd4, 0x35, 0x5c, 0xef,
+	0x37, 0xcd, 0xb3, 0xd6, 0xdb, 0x56, 0xbf, 0xd9, 0xd0, 0x14, 0xb4, 0x0d, 0x9b, 0xe7, 0xed, 0xfa,
+	0x79, 0xff, 0xa4, 0xd9, 0xee, 0xb7, 0x0e, 0xeb, 0x94, 0x99, 0x43, 0x3b, 0xa0, 0x7d, 0xd3, 0xc4,
+	0xbd, 0x56, 0xa7, 0x6d, 0xbe, 0x6d, 0xf5, 0xde, 0xd6, 0xfb, 0x87, 0x27, 0x5a, 0xbe, 0xf6, 0xc7,
+	0x1c, 0x94, 0x53, 0xc8, 0xd0, 0x01, 0xa8, 0xc7, 0x24, 0xe2, 0xc3, 0x87, 0xb6, 0x18, 0xc1, 0x44,
+	0x79, 0x55, 0x2b, 0x09, 0x8e, 0xef, 0xce, 0x8d, 0x0d, 0xf4, 0x39, 0x14, 0x62, 0xfd, 0x10, 0x6d,
+	0x3d, 0x98, 0x1b, 0xab, 0x9b, 0x2b, 0x63, 0x9c, 0xb1, 0x81, 0x5e, 0x41, 0xf1, 0x98, 0x44, 0x5d,
+	0x27, 0xe0, 0xa7, 0x30, 0x9b, 0xcb, 0x51, 0xaa, 0x5a, 0x5a, 0xd0, 0x5c, 0xfd, 0x08, 0x34, 0x7a,
+	0x42, 0x6a, 0xae, 0xd1, 0x1f, 0x8e, 0x06, 0x62, 0xf7, 0xee, 0x23, 0x12, 0x6e, 0xe7, 0x0b, 0xd8,
+	0x4a, 0xda, 0x79, 0xbf, 0xc3, 0xb9, 0x7b, 0xe2, 0x0f, 0x93, 0xad, 0x44, 0xff, 0x4f, 0xba, 0x97,
+	0x98, 0x19, 0x8c, 0x0d, 0xf4, 0x33, 0x28, 0x35, 0x45, 0xfb, 0xa4, 0xcd, 0x16, 0x31, 0x95, 0x44,
+	0x1f, 0xaf, 0x96, 0x97, 0x0c, 0xbe, 0xe3, 0xe7, 0xa0, 0xc6, 0x6d, 0x0b, 0x6d, 0x33, 0x83, 0xe9,
+	0xe6, 0x59, 0xdd, 0x4a, 0x33, 0xf9, 0xae, 0x5f, 0x42, 0x31, 0xd1, 0x39, 0x10, 0x73, 0xfc, 0x61,
+	0x93, 0xaa, 0xee, 0x3c, 0xe0, 0xf3, 0xed, 0x87, 0xb0, 0x49, 0xc3, 0x91, 0x78, 0xef, 0xd1, 0xf3,
+	0xc5, 0x5d, 0x4c, 0x77, 0x8c, 0xea, 0xb3, 0x87, 0x02, 0x6e, 0xe4, 0xd7, 0x50, 0x39, 0x26, 0xc9,
+	0xdb, 0xcf, 0x61, 0x3c, 0x7c, 0x98, 0xaa, 0x3b, 0x0f, 0xf8, 0x71, 0x76, 0x2b, 0xe9, 0xf7, 0x03,
+	0x7d, 0x24, 0x9c, 0x7d, 0xc4, 0xc8, 0xf3, 0xc7, 0x44, 0xdc, 0xce, 0xd7, 0x50, 0x3a, 0x26, 0x51,
+	0x6b, 0xf9, 0x41, 0x47, 0x55, 0x57, 0x1b, 0x4e, 0x15, 0xad, 0x70, 0xd9, 0xde, 0x41, 0x8e, 0xfd,
+	0x8d, 0xf9, 0xc5, 0x7f, 0x06, 0x00, 0x7f, 0x93, 0xbd, 0x5b, 0xd5, 0x14, 0x00, 0x00,
 }
@@ -0,0 +1,183 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/rand"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// Returned by pub.GetPirShare() if the sealed outputs are not all the same
+// length. (See WithPadding().)
+const ErrorPirSealed = Error("sealed outputs are not of fixed length")
+
+// Returned by pub.GetPirShare() if the query is malformed.
+const ErrorPirQuery = Error("malformed PIR query")
+
+// Returned by priv.GetPirOutput() if the shares of the two servers don't match.
+const ErrorPirShare = Error("mismatched PIR shares")
+
+// Returned by priv.GetPirOutput() and c.Output() if the commitment of the store
+// is set. PIR responses don't carry Merkle proofs, so the output can't be
+// verified. (See priv.SetCommitment().)
+const ErrorPirCommitment = Error("PIR responses can't be verified")
+
+// GetPirQuery computes the queries sent to the two servers in order to look up
+// input using two-server PIR. (See the package documentation.) Each query
+// consists of two subsets of the rows of the table, each encoded as a bit
+// vector: the first for x and the second for y, where (x, y) is the index of
+// input. The subsets sent to the two servers differ only in x (resp. y).
+func (priv *PrivStore) GetPirQuery(input string) (query0, query1 [][]byte, err error) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	tableLen := int(priv.dict.GetParams().GetTableLen())
+	query0 = make([][]byte, 2)
+	query1 = make([][]byte, 2)
+	for i, row := range []int{x, y} {
+		query0[i] = make([]byte, (tableLen+7)/8)
+		if _, err = rand.Read(query0[i]); err != nil {
+			return nil, nil, err
+		}
+		// Clear the bits beyond the end of the table.
+		if r := tableLen % 8; r > 0 {
+			query0[i][len(query0[i])-1] &= byte(1<<uint(r)) - 1
+		}
+		query1[i] = append([]byte(nil), query0[i]...)
+		query1[i][row/8] ^= 1 << uint(row%8)
+	}
+	return query0, query1, nil
+}
+
+// GetPirShare computes the server's share of the response to a PIR query. The
+// record of row x consists of the row itself and the sealed output of each edge
+// incident to x, padded to the maximum degree of the graph. The i-th share is
+// the XOR of the records of the rows in the i-th subset.
+//
+// It returns ErrorPirQuery if a subset is not a bit vector of the length of the
+// table. The records have the same length only if the sealed outputs do, so it
+// returns ErrorPirSealed unless the outputs are padded with WithPadding().
+func (pub *PubStore) GetPirShare(query [][]byte) ([][]byte, error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return nil, ErrorClosed
+	}
+
+	sealedBytes, maxDegree, err := pub.pirLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	table := pub.dict.getTable()
+	tableLen := len(table) / pub.dict.rowBytes()
+	pubShares := make([][]byte, len(query))
+	for i, subset := range query {
+		if len(subset) != (tableLen+7)/8 {
+			return nil, ErrorPirQuery
+		}
+		share := make([]byte, pub.dict.rowBytes()+maxDegree*sealedBytes)
+		for x := 0; x < tableLen; x++ {
+			if subset[x/8]&(1<<uint(x%8)) != 0 {
+				pub.xorRecord(share, table, x, sealedBytes)
+			}
+		}
+		pubShares[i] = share
+	}
+	return pubShares, nil
+}
+
+// pirLayout returns the length of each sealed output and the maximum degree of
+// the graph, which together determine the length of each record. It returns
+// ErrorPirSealed if the sealed outputs are not all the same length. The caller
+// must hold pub.mu.
+func (pub *PubStore) pirLayout() (sealedBytes, maxDegree int, err error) {
+	if pub.padding != pb.OutputPadding_FIXED {
+		return 0, 0, ErrorPirSealed
+	}
+	for i := range pub.sealed {
+		if i == 0 {
+			sealedBytes = len(pub.sealed[i])
+		} else if len(pub.sealed[i]) != sealedBytes {
+			return 0, 0, ErrorPirSealed
+		}
+	}
+	for x := range pub.g {
+		if len(pub.g[x]) > maxDegree {
+			maxDegree = len(pub.g[x])
+		}
+	}
+	return sealedBytes, maxDegree, nil
+}
+
+// xorRecord sets dst to the bitwise-XOR of dst and the record of row x, where
+// table is the table of pub.dict. The caller must hold pub.mu.
+func (pub *PubStore) xorRecord(dst, table []byte, x, sealedBytes int) {
+	rowBytes := pub.dict.rowBytes()
+	xor(dst[:rowBytes], table[x*rowBytes:(x+1)*rowBytes])
+	if x >= len(pub.g) {
+		return
+	}
+	for j, e := range pub.g[x] {
+		xor(dst[rowBytes+j*sealedBytes:], pub.sealed[e])
+	}
+}
+
+// xor sets dst to the bitwise-XOR of dst and src.
+func xor(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
+
+// GetPirOutput computes the output for input from the shares of the two
+// servers' responses to the queries computed by priv.GetPirQuery(input). It
+// returns ItemNotFound if the input is not in the map, and ErrorPirCommitment if
+// the commitment of the store is set.
+func (priv *PrivStore) GetPirOutput(input string, pubShare0, pubShare1 [][]byte) (string, error) {
+	if len(pubShare0) != 2 || len(pubShare1) != 2 {
+		return "", ErrorPirShare
+	}
+	records := make([][]byte, 2)
+	for i := range records {
+		if len(pubShare0[i]) != len(pubShare1[i]) {
+			return "", ErrorPirShare
+		}
+		records[i] = append([]byte(nil), pubShare0[i]...)
+		xor(records[i], pubShare1[i])
+	}
+
+	return priv.pirOutput(input, records[0], records[1])
+}
+
+// pirOutput computes the output for input from the records of x and y, where
+// (x, y) is the index of input.
+func (priv *PrivStore) pirOutput(input string, recordX, recordY []byte) (string, error) {
+	if priv.commitment != nil {
+		return "", ErrorPirCommitment
+	}
+	rowBytes := priv.dict.rowBytes()
+	sealedBytes := priv.aead.Overhead() + priv.paddedBytes
+	if priv.padding != pb.OutputPadding_FIXED || len(recordX) < rowBytes ||
+		(len(recordX)-rowBytes)%sealedBytes != 0 || len(recordY) < rowBytes {
+		return "", ErrorPirShare
+	}
+
+	// The sealed output is one of those in the record of x; the others were
+	// sealed with a different input as associated data, so they don't
+	// authenticate.
+	pubShare := make([]byte, rowBytes+sealedBytes)
+	copy(pubShare, recordX[:rowBytes])
+	xor(pubShare[:rowBytes], recordY[:rowBytes])
+	for j := rowBytes; j < len(recordX); j += sealedBytes {
+		copy(pubShare[rowBytes:], recordX[j:j+sealedBytes])
+		output, err := priv.getOutput(input, pubShare)
+		if err != ItemNotFound {
+			return output, err
+		}
+	}
+	return "", ItemNotFound
+}
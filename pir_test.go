@@ -0,0 +1,168 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"testing"
+)
+
+// pirGet looks up input using two-server PIR, where both servers hold pub.
+func pirGet(t *testing.T, pub *PubStore, priv *PrivStore, input string) (string, error) {
+	query0, query1, err := priv.GetPirQuery(input)
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	pubShare0, err := pub.GetPirShare(query0)
+	if err != nil {
+		t.Fatalf("pub.GetPirShare() fails: %s", err)
+	}
+	pubShare1, err := pub.GetPirShare(query1)
+	if err != nil {
+		t.Fatalf("pub.GetPirShare() fails: %s", err)
+	}
+	return priv.GetPirOutput(input, pubShare0, pubShare1)
+}
+
+func TestPir(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithPadding(32), WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+
+	for in, out := range goodM {
+		if output, err := pirGet(t, pub, priv, in); err != nil || output != out {
+			t.Errorf("pirGet(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	if _, err := pirGet(t, pub, priv, "not an input"); err != ItemNotFound {
+		t.Errorf("pirGet() returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// The records change as the store is updated.
+	update, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	if update, err = priv.Delete(pub, "this"); err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	if output, err := pirGet(t, pub, priv, "hip"); err != nil || output != "burger" {
+		t.Errorf("pirGet(\"hip\") = (%q, %v), expected (\"burger\", nil)", output, err)
+	}
+	if _, err := pirGet(t, pub, priv, "this"); err != ItemNotFound {
+		t.Errorf("pirGet(\"this\") returns %v, expected %q", err, ItemNotFound)
+	}
+}
+
+func TestPirQueryIsRandom(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithPadding(32))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	// The queries differ only in the rows of the index.
+	x, y, _ := priv.GetIdx("hip")
+	query0, query1, err := priv.GetPirQuery("hip")
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	for i, row := range []int{x, y} {
+		diff := append([]byte(nil), query0[i]...)
+		xor(diff, query1[i])
+		expected := make([]byte, len(diff))
+		expected[row/8] = 1 << uint(row%8)
+		if string(diff) != string(expected) {
+			t.Errorf("query %d differs in the wrong rows", i)
+		}
+	}
+
+	// A second query for the same input is different.
+	query2, _, err := priv.GetPirQuery("hip")
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	if string(query0[0]) == string(query2[0]) {
+		t.Error("priv.GetPirQuery() returns the same query twice")
+	}
+}
+
+func TestBadPir(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	query0, _, err := priv.GetPirQuery("hip")
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	if _, err = pub.GetPirShare(query0); err != ErrorPirSealed {
+		t.Errorf("pub.GetPirShare() returns %v for an unpadded store, expected %q", err, ErrorPirSealed)
+	}
+
+	pub, priv, err = NewStore(GenerateKey(), goodM, WithPadding(32))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if _, err = pub.GetPirShare([][]byte{{1, 2, 3}}); err != ErrorPirQuery {
+		t.Errorf("pub.GetPirShare() returns %v for a short query, expected %q", err, ErrorPirQuery)
+	}
+	query0, query1, err := priv.GetPirQuery("hip")
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	pubShare0, err := pub.GetPirShare(query0)
+	if err != nil {
+		t.Fatalf("pub.GetPirShare() fails: %s", err)
+	}
+	pubShare1, err := pub.GetPirShare(query1)
+	if err != nil {
+		t.Fatalf("pub.GetPirShare() fails: %s", err)
+	}
+	if _, err = priv.GetPirOutput("hip", pubShare0, pubShare1[:1]); err != ErrorPirShare {
+		t.Errorf("priv.GetPirOutput() returns %v for a missing share, expected %q", err, ErrorPirShare)
+	}
+	pubShare1[0] = pubShare1[0][1:]
+	if _, err = priv.GetPirOutput("hip", pubShare0, pubShare1); err != ErrorPirShare {
+		t.Errorf("priv.GetPirOutput() returns %v for a short share, expected %q", err, ErrorPirShare)
+	}
+}
+
+// Test that PIR outputs are rejected if the commitment of the store is set,
+// since they can't be verified.
+func TestPirCommitment(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithPadding(32))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	priv.SetCommitment(pub.Commitment())
+
+	if _, err := pirGet(t, pub, priv, "hip"); err != ErrorPirCommitment {
+		t.Errorf("pirGet() returns %v, expected %q", err, ErrorPirCommitment)
+	}
+
+	s, err := NewSimplePirServer(pub)
+	if err != nil {
+		t.Fatalf("NewSimplePirServer() fails: %s", err)
+	}
+	c, err := NewSimplePirClient(priv, s.Hint())
+	if err != nil {
+		t.Fatalf("NewSimplePirClient() fails: %s", err)
+	}
+	if _, err := simplePirGet(t, s, c, "hip"); err != ErrorPirCommitment {
+		t.Errorf("simplePirGet() returns %v, expected %q", err, ErrorPirCommitment)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+// Package provider implements the server side of the StoreProvider RPC
+// specified in store.proto.
+package provider
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Length of a bearer token, before it is hex-encoded.
+const TokenBytes = 32
+
+// The metadata key used to transmit the bearer token.
+const authorizationKey = "authorization"
+
+// The prefix of the authorization metadata.
+const bearerPrefix = "Bearer "
+
+// Error is the type of errors returned by this package.
+type Error string
+
+// Error returns the error string.
+func (err Error) Error() string {
+	return string(err)
+}
+
+// Returned by LoadCredentials() if the config file is malformed.
+const ErrorBadConfig = Error("bad credentials config")
+
+// GenerateToken generates a fresh, random bearer token and returns it.
+func GenerateToken() string {
+	token := make([]byte, TokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(token)
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of token. The server stores
+// only the hash of each user's token.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Credentials stores the token hash of each user.
+type Credentials struct {
+	users map[string][]byte // Maps a user to the hash of its token.
+}
+
+// The format of the credentials config file. For example:
+//
+//	{"users": {"cjpatton": "<output of HashToken()>"}}
+type credentialsConfig struct {
+	Users map[string]string `json:"users"`
+}
+
+// LoadCredentials loads the credentials from a config file.
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCredentials(data)
+}
+
+// ParseCredentials parses the contents of a credentials config file.
+func ParseCredentials(data []byte) (*Credentials, error) {
+	var config credentialsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, ErrorBadConfig
+	}
+	c := &Credentials{users: make(map[string][]byte)}
+	for user, tokenHash := range config.Users {
+		h, err := hex.DecodeString(tokenHash)
+		if err != nil || len(h) != sha256.Size {
+			return nil, ErrorBadConfig
+		}
+		c.users[user] = h
+	}
+	return c, nil
+}
+
+// authenticate returns the user whose token is presented in the metadata of
+// the incoming context. It returns false if the token is missing or invalid.
+func (c *Credentials) authenticate(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, v := range md.Get(authorizationKey) {
+		if !strings.HasPrefix(v, bearerPrefix) {
+			continue
+		}
+		h := sha256.Sum256([]byte(strings.TrimPrefix(v, bearerPrefix)))
+		for user, tokenHash := range c.users {
+			if subtle.ConstantTimeCompare(h[:], tokenHash) == 1 {
+				return user, true
+			}
+		}
+	}
+	return "", false
+}
+
+// The key under which the authenticated user is stored in the context.
+type userKey struct{}
+
+// UnaryInterceptor checks the bearer token of each request. If the token is
+// valid, then the corresponding user is recorded in the context passed to the
+// handler; see Authorized().
+//
+// The interceptor does not reject requests with a missing or invalid token.
+// Instead, the handler reports StoreProviderError_UNAUTHENTICATED.
+func (c *Credentials) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if user, ok := c.authenticate(ctx); ok {
+		ctx = context.WithValue(ctx, userKey{}, user)
+	}
+	return handler(ctx, req)
+}
+
+// AuthenticatedUser returns the user authenticated by the interceptor.
+func AuthenticatedUser(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok
+}
+
+// Authorized returns true if the request was authenticated as user.
+func Authorized(ctx context.Context, user string) bool {
+	authUser, ok := AuthenticatedUser(ctx)
+	return ok && authUser == user
+}
+
+// BearerToken implements credentials.PerRPCCredentials. It is used by the
+// client to present its token to the server, e.g.:
+//
+//	conn, err := grpc.Dial(address, grpc.WithPerRPCCredentials(
+//		provider.BearerToken{Token: token}), ...)
+type BearerToken struct {
+	Token string
+
+	// If set, then the token may be sent over an insecure connection. This
+	// exposes the token to anyone who can observe the connection.
+	AllowInsecure bool
+}
+
+// GetRequestMetadata returns the metadata containing the token.
+func (t BearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: bearerPrefix + t.Token}, nil
+}
+
+// RequireTransportSecurity returns true unless t.AllowInsecure is set.
+func (t BearerToken) RequireTransportSecurity() bool {
+	return !t.AllowInsecure
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// authUser calls the interceptor with the token and returns the user recorded
+// in the context passed to the handler.
+func authUser(t *testing.T, c *Credentials, token string) (string, bool) {
+	ctx := context.Background()
+	if token != "" {
+		md, err := BearerToken{Token: token}.GetRequestMetadata(ctx)
+		if err != nil {
+			t.Fatalf("GetRequestMetadata() fails: %s", err)
+		}
+		ctx = metadata.NewIncomingContext(ctx, metadata.New(md))
+	}
+	var user string
+	var ok bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		user, ok = AuthenticatedUser(ctx)
+		return nil, nil
+	}
+	if _, err := c.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("UnaryInterceptor() fails: %s", err)
+	}
+	return user, ok
+}
+
+func TestCredentials(t *testing.T) {
+	alice, bob := GenerateToken(), GenerateToken()
+	config := fmt.Sprintf(`{"users": {"alice": %q, "bob": %q}}`,
+		HashToken(alice), HashToken(bob))
+	c, err := ParseCredentials([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseCredentials() fails: %s", err)
+	}
+
+	for _, test := range []struct {
+		token, user string
+		ok          bool
+	}{
+		{alice, "alice", true},
+		{bob, "bob", true},
+		{GenerateToken(), "", false},
+		{"", "", false},
+	} {
+		user, ok := authUser(t, c, test.token)
+		if user != test.user || ok != test.ok {
+			t.Errorf("authUser(%q) = (%q, %v), expected (%q, %v)",
+				test.token, user, ok, test.user, test.ok)
+		}
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+	if !Authorized(ctx, "alice") {
+		t.Error("Authorized(ctx, \"alice\") = false, expected true")
+	}
+	if Authorized(ctx, "bob") {
+		t.Error("Authorized(ctx, \"bob\") = true, expected false")
+	}
+	if Authorized(context.Background(), "alice") {
+		t.Error("Authorized(context.Background(), \"alice\") = true, expected false")
+	}
+}
+
+func TestParseCredentialsBadConfig(t *testing.T) {
+	for _, config := range []string{
+		`not json`,
+		`{"users": {"alice": "not hex"}}`,
+		`{"users": {"alice": "abcd"}}`,
+	} {
+		if _, err := ParseCredentials([]byte(config)); err != ErrorBadConfig {
+			t.Errorf("ParseCredentials(%q) returns %v, expected %q", config, err, ErrorBadConfig)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	if !(BearerToken{Token: "t"}).RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = false, expected true")
+	}
+	if (BearerToken{Token: "t", AllowInsecure: true}).RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = true, expected false")
+	}
+}
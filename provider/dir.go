@@ -0,0 +1,839 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package provider
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// Returned by DirProvider if the user is not valid or has no store.
+const ErrorBadUser = Error("no store for user")
+
+// Returned by DirProvider if the version of the user's store can't be read.
+const ErrorBadVersion = Error("can't read the version of the store")
+
+// The maximum number of indices in a GetShares request.
+const MaxShares = 4096
+
+// The suffix of the file containing a user's public store.
+const PubSuffix = ".pub"
+
+// The suffix of the file containing a user's OPRF key. (See
+// store.GenerateOprfKey().)
+const OprfSuffix = ".oprf"
+
+// The suffix of the file containing a user's key envelope. (See
+// store.WrapKey().)
+const EnvelopeSuffix = ".envelope"
+
+// The suffix of the file containing the version of a user's store, as
+// provisioned by PutStore. The file is kept when the store is deleted.
+const VersionSuffix = ".version"
+
+// DirProvider implements the StoreProvider RPC for the users whose stores are
+// in a directory. The public store of user is read from file <user>.pub and,
+// if the user's password is hardened with an OPRF, the OPRF key is read from
+// <user>.oprf. The user's key envelope, if any, is read from <user>.envelope.
+//
+// Stores are loaded when they are first requested and are evicted in
+// least-recently-used order when the total size exceeds the memory budget. If
+// a store's file changes, then the store is reloaded on the next request.
+//
+// Stores may also be provisioned remotely via the PutStore and DeleteStore
+// RPCs. A new store is written to a temporary file, which is then renamed to
+// <user>.pub, so that the store is replaced atomically. Each store has a
+// version that is incremented each time the store is replaced; a request to
+// replace or delete the store succeeds only if it names the current version.
+// The version is also written to <user>.version, which is kept when the store
+// is deleted, so that a version is never reused for a different store.
+//
+// Requests that change a user's files (PutStore, DeleteStore and
+// PutKeyEnvelope) must be authenticated as the user. If the provider has no
+// credentials, then they are refused unless AllowInsecureWrites is set.
+//
+// The methods of DirProvider are safe for concurrent use.
+type DirProvider struct {
+	// If set, then requests that change a user's files are allowed even if
+	// the provider has no credentials, i.e., anyone may replace or delete any
+	// store. This must be set before p is used.
+	AllowInsecureWrites bool
+
+	dir   string
+	creds *Credentials // If nil, then requests are not authenticated.
+
+	// Logs changes to the stores. If nil, then nothing is logged.
+	logf func(format string, args ...interface{})
+
+	// The (approximate) number of bytes of memory available for the stores.
+	// If 0, then stores are never evicted.
+	budget int64
+
+	// The minimum time between OPRF evaluations for a user.
+	oprfInterval time.Duration
+
+	// Serializes the requests that read or write the version of a store, so
+	// that checking the version and writing the store is atomic.
+	putMu sync.Mutex
+
+	mu       sync.Mutex // Protects the fields below.
+	stores   map[string]*entry
+	lru      *list.List // Most recently used entry is at the front.
+	size     int64      // The total size of the loaded entries.
+	lastOprf map[string]time.Time
+}
+
+// An entry in the cache of stores.
+type entry struct {
+	pub       *store.PubStore
+	params    *pb.Params
+	kdfHeader *pb.KdfHeader     // nil if the store has no KDF header.
+	oprf      *store.OprfServer // nil if there is no OPRF key.
+	fi        os.FileInfo       // The file the store was loaded from.
+
+	// The (approximate) number of bytes of memory used by the entry: the size
+	// of the file, the Merkle tree of the store, and the single-server PIR
+	// server, once it is created.
+	size int64
+
+	// The single-server PIR server, created on first use.
+	simplePirOnce sync.Once
+	simplePir     *store.SimplePirServer
+	simplePirErr  error
+
+	refs    int  // The number of requests using pub.
+	evicted bool // Set once the entry is removed from the cache.
+	elem    *list.Element
+}
+
+// NewDirProvider creates a new provider for the stores in dir. If budget is
+// positive, then it is the (approximate) number of bytes of memory available
+// for the stores; the size of each store is estimated by the size of its file,
+// plus the size of its Merkle tree and, if it answers single-server PIR
+// queries, the size of the PIR database and hint.
+// OPRF evaluations for each user are limited to one per oprfInterval. If creds
+// is not nil, then each request must be authenticated as the user it names;
+// the server must be created with creds.UnaryInterceptor. If logf is not nil,
+// then it is called to log the stores that are installed, deleted, or evicted,
+// e.g., with log.Printf.
+//
+// You should call p.Close() when you are done with p.
+func NewDirProvider(dir string, budget int64, oprfInterval time.Duration, creds *Credentials, logf func(format string, args ...interface{})) *DirProvider {
+	return &DirProvider{
+		dir:          dir,
+		creds:        creds,
+		logf:         logf,
+		budget:       budget,
+		oprfInterval: oprfInterval,
+		stores:       make(map[string]*entry),
+		lru:          list.New(),
+		lastOprf:     make(map[string]time.Time),
+	}
+}
+
+// logPrintf logs the message with p.logf, if it is set.
+func (p *DirProvider) logPrintf(format string, args ...interface{}) {
+	if p.logf != nil {
+		p.logf(format, args...)
+	}
+}
+
+// validUser returns true if user names a file in the directory.
+func validUser(user string) bool {
+	return user != "" && !strings.HasPrefix(user, ".") &&
+		!strings.ContainsAny(user, "/\\")
+}
+
+// path returns the path of the user's file with the given suffix.
+func (p *DirProvider) path(user, suffix string) string {
+	return filepath.Join(p.dir, user+suffix)
+}
+
+// load reads the user's store from its file. It returns store.ErrorBadStore if
+// the store is malformed. (See store.ValidateStoreProto().)
+func (p *DirProvider) load(user string, fi os.FileInfo) (*entry, error) {
+	data, err := ioutil.ReadFile(p.path(user, PubSuffix))
+	if err != nil {
+		return nil, err
+	}
+	table := new(pb.Store)
+	if err = proto.Unmarshal(data, table); err != nil {
+		return nil, store.ErrorBadStore
+	} else if err = store.ValidateStoreProto(table); err != nil {
+		return nil, err
+	}
+	e := &entry{
+		params:    table.GetDict().GetParams(),
+		kdfHeader: table.GetKdfHeader(),
+		fi:        fi,
+	}
+
+	oprfKey, err := ioutil.ReadFile(p.path(user, OprfSuffix))
+	if err == nil {
+		if e.oprf, err = store.NewOprfServer(oprfKey); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	e.pub = store.NewPubStoreFromProto(table)
+	e.size = fi.Size() + e.pub.TreeSize()
+	return e, nil
+}
+
+// acquire returns the entry for user, loading the store if necessary. The
+// caller must call p.release() when it is done with the entry.
+func (p *DirProvider) acquire(user string) (*entry, error) {
+	if !validUser(user) {
+		return nil, ErrorBadUser
+	}
+	fi, err := os.Stat(p.path(user, PubSuffix))
+	if os.IsNotExist(err) {
+		p.mu.Lock()
+		if e, ok := p.stores[user]; ok {
+			p.remove(user, e) // The store was deleted.
+		}
+		p.mu.Unlock()
+		return nil, ErrorBadUser
+	} else if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if e, ok := p.stores[user]; ok {
+		if unchanged(e.fi, fi) {
+			p.lru.MoveToFront(e.elem)
+			e.refs++
+			p.mu.Unlock()
+			return e, nil
+		}
+		p.remove(user, e) // The file changed.
+	}
+	p.mu.Unlock()
+
+	// Don't hold the lock while the store is being loaded.
+	e, err := p.load(user, fi)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.stores[user]; ok {
+		// Another request loaded the store in the meantime.
+		p.remove(user, old)
+	}
+	e.elem = p.lru.PushFront(user)
+	e.refs = 1
+	p.stores[user] = e
+	p.size += e.size
+	p.evict()
+	return e, nil
+}
+
+// unchanged returns true if the file described by fi is the same as the file
+// described by old.
+func unchanged(old, fi os.FileInfo) bool {
+	return os.SameFile(old, fi) && old.ModTime().Equal(fi.ModTime()) &&
+		old.Size() == fi.Size()
+}
+
+// release releases an entry acquired by p.acquire().
+func (p *DirProvider) release(e *entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.refs--
+	if e.evicted && e.refs == 0 {
+		e.pub.Close()
+	}
+}
+
+// remove removes the entry from the cache. The store is closed once it is no
+// longer in use. The caller must hold p.mu.
+func (p *DirProvider) remove(user string, e *entry) {
+	delete(p.stores, user)
+	p.lru.Remove(e.elem)
+	p.size -= e.size
+	e.evicted = true
+	if e.refs == 0 {
+		e.pub.Close()
+	}
+}
+
+// evict removes the least recently used entries until the stores fit in the
+// budget. The most recently used entry is never evicted. The caller must hold
+// p.mu.
+func (p *DirProvider) evict() {
+	for p.budget > 0 && p.size > p.budget && p.lru.Len() > 1 {
+		user := p.lru.Back().Value.(string)
+		p.logPrintf("evicting store for %q", user)
+		p.remove(user, p.stores[user])
+	}
+}
+
+// invalidate removes the user's store from the cache.
+func (p *DirProvider) invalidate(user string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.stores[user]; ok {
+		p.remove(user, e)
+	}
+}
+
+// currentVersion returns the version of the user's store, or of its last store
+// if it was deleted. This is read from <user>.version, or, if there is no such
+// file, from <user>.pub; the store isn't loaded. It returns 0 if the user has
+// never had a store, and ErrorBadVersion if the version can't be read. The
+// caller must hold p.putMu.
+func (p *DirProvider) currentVersion(user string) (int64, error) {
+	data, err := ioutil.ReadFile(p.path(user, VersionSuffix))
+	if err == nil {
+		version, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, ErrorBadVersion
+		}
+		return version, nil
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	// The store was not provisioned by PutStore.
+	data, err = ioutil.ReadFile(p.path(user, PubSuffix))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	table := new(pb.Store)
+	if err = proto.Unmarshal(data, table); err != nil {
+		return 0, ErrorBadVersion
+	}
+	return table.GetVersion(), nil
+}
+
+// writeVersion records the version of the user's store.
+func (p *DirProvider) writeVersion(user string, version int64) error {
+	return p.writeFile(user, VersionSuffix, []byte(strconv.FormatInt(version, 10)+"\n"))
+}
+
+// writeMessage atomically replaces the user's file with the given suffix with
+// msg.
+func (p *DirProvider) writeMessage(user, suffix string, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return p.writeFile(user, suffix, data)
+}
+
+// writeFile atomically replaces the user's file with the given suffix with data.
+func (p *DirProvider) writeFile(user, suffix string, data []byte) error {
+	// The name of the temporary file begins with ".", so that it is not
+	// mistaken for a store.
+	f, err := ioutil.TempFile(p.dir, "."+user+suffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // Fails harmlessly once the file is renamed.
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p.path(user, suffix))
+}
+
+// readEnvelope reads the user's key envelope. It returns nil if the user has
+// no envelope.
+func (p *DirProvider) readEnvelope(user string) (*pb.KeyEnvelope, error) {
+	data, err := ioutil.ReadFile(p.path(user, EnvelopeSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	env := new(pb.KeyEnvelope)
+	if err = proto.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Loaded returns the number of stores currently loaded.
+func (p *DirProvider) Loaded() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stores)
+}
+
+// Close closes each of the loaded stores. Stores that are in use are closed
+// once the requests using them are finished.
+func (p *DirProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for user, e := range p.stores {
+		p.remove(user, e)
+	}
+}
+
+// authorized returns true if the request may access user's store.
+func (p *DirProvider) authorized(ctx context.Context, user string) bool {
+	return p.creds == nil || Authorized(ctx, user)
+}
+
+// writable returns true if the request may change user's files. Unlike
+// authorized(), this requires credentials unless p.AllowInsecureWrites is set.
+func (p *DirProvider) writable(ctx context.Context, user string) bool {
+	if p.creds == nil {
+		return p.AllowInsecureWrites
+	}
+	return Authorized(ctx, user)
+}
+
+// GetShare implements the GetShare RPC.
+func (p *DirProvider) GetShare(ctx context.Context, in *pb.ShareRequest) (*pb.ShareReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.ShareReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.ShareReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+
+	var pubShare []byte
+	if in.GetVerifiable() {
+		pubShare, err = e.pub.GetVerifiableShare(int(in.GetX()), int(in.GetY()))
+	} else {
+		pubShare, err = e.pub.GetShare(int(in.GetX()), int(in.GetY()))
+	}
+	code, err := shareError(err)
+	if err != nil {
+		return nil, err // Unexpected error!
+	}
+	return &pb.ShareReply{Error: code, PubShare: pubShare}, nil
+}
+
+// GetShares implements the GetShares RPC. The request may contain at most
+// MaxShares indices.
+func (p *DirProvider) GetShares(ctx context.Context, in *pb.SharesRequest) (*pb.SharesReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.SharesReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if len(in.GetIndex()) > MaxShares {
+		return &pb.SharesReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.SharesReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+
+	idx := make([]store.Index, len(in.GetIndex()))
+	for i, index := range in.GetIndex() {
+		idx[i] = store.Index{X: int(index.GetX()), Y: int(index.GetY())}
+	}
+	var pubShares [][]byte
+	var errs []error
+	if in.GetVerifiable() {
+		pubShares, errs = e.pub.GetVerifiableShares(idx)
+	} else {
+		pubShares, errs = e.pub.GetShares(idx)
+	}
+	reply := &pb.SharesReply{
+		Error: pb.StoreProviderError_OK,
+		Share: make([]*pb.SharesReply_Share, len(idx)),
+	}
+	for i := range idx {
+		code, err := shareError(errs[i])
+		if err != nil {
+			return nil, err // Unexpected error!
+		}
+		reply.Share[i] = &pb.SharesReply_Share{PubShare: pubShares[i], Error: code}
+	}
+	return reply, nil
+}
+
+// GetPirShare computes the provider's share of the response to a two-server
+// PIR query. If the user's store is not padded to a fixed length, then the
+// error is BAD_REQUEST.
+func (p *DirProvider) GetPirShare(ctx context.Context, in *pb.PirRequest) (*pb.PirReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.PirReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if len(in.GetQuery()) > MaxShares {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+
+	pubShares, err := e.pub.GetPirShare(in.GetQuery())
+	if err == store.ErrorPirQuery || err == store.ErrorPirSealed {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.PirReply{PubShare: pubShares, Error: pb.StoreProviderError_OK}, nil
+}
+
+// getSimplePir returns the single-server PIR server for the entry's store,
+// creating it if necessary. The size of the server counts towards the memory
+// budget.
+func (p *DirProvider) getSimplePir(e *entry) (*store.SimplePirServer, error) {
+	e.simplePirOnce.Do(func() {
+		e.simplePir, e.simplePirErr = store.NewSimplePirServer(e.pub)
+		if e.simplePirErr != nil {
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !e.evicted {
+			e.size += e.simplePir.Size()
+			p.size += e.simplePir.Size()
+			p.evict()
+		}
+	})
+	return e.simplePir, e.simplePirErr
+}
+
+// GetSimplePirHint returns the hint for single-server PIR queries for the user's
+// store. The hint is computed the first time it is requested after the store is
+// loaded. If the store is not padded to a fixed length, then the error is
+// BAD_REQUEST.
+func (p *DirProvider) GetSimplePirHint(ctx context.Context, in *pb.SimplePirHintRequest) (*pb.SimplePirHintReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.SimplePirHintReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.SimplePirHintReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+
+	s, err := p.getSimplePir(e)
+	if err == store.ErrorPirSealed || err == store.ErrorPirTooLarge {
+		return &pb.SimplePirHintReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.SimplePirHintReply{Hint: s.Hint(), Error: pb.StoreProviderError_OK}, nil
+}
+
+// GetSimplePirShare answers single-server PIR queries for the user's store.
+func (p *DirProvider) GetSimplePirShare(ctx context.Context, in *pb.PirRequest) (*pb.PirReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.PirReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if len(in.GetQuery()) > MaxShares {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+
+	s, err := p.getSimplePir(e)
+	if err == store.ErrorPirSealed || err == store.ErrorPirTooLarge {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	answers, err := s.Answer(in.GetQuery())
+	if err == store.ErrorPirQuery {
+		return &pb.PirReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.PirReply{PubShare: answers, Error: pb.StoreProviderError_OK}, nil
+}
+
+// shareError returns the StoreProviderError corresponding to an error returned
+// by pub.GetShare(). If the error is unexpected, then it is returned as is.
+func shareError(err error) (pb.StoreProviderError, error) {
+	switch err {
+	case nil:
+		return pb.StoreProviderError_OK, nil
+	case store.ErrorIdx:
+		return pb.StoreProviderError_INDEX, nil
+	case store.ItemNotFound:
+		return pb.StoreProviderError_ITEM_NOT_FOUND, nil
+	}
+	return pb.StoreProviderError_OK, err
+}
+
+// GetParams implements the GetParams RPC.
+func (p *DirProvider) GetParams(ctx context.Context, in *pb.ParamsRequest) (*pb.ParamsReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.ParamsReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.ParamsReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+	return &pb.ParamsReply{
+		Error:     pb.StoreProviderError_OK,
+		Params:    e.params,
+		KdfHeader: e.kdfHeader,
+	}, nil
+}
+
+// EvaluateOprf implements the EvaluateOprf RPC.
+func (p *DirProvider) EvaluateOprf(ctx context.Context, in *pb.OprfRequest) (*pb.OprfReply, error) {
+	user := in.GetUserId()
+	if !p.authorized(ctx, user) {
+		return &pb.OprfReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	e, err := p.acquire(user)
+	if err == ErrorBadUser {
+		return &pb.OprfReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+	if e.oprf == nil {
+		return &pb.OprfReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	if last, ok := p.lastOprf[user]; ok && now.Sub(last) < p.oprfInterval {
+		p.mu.Unlock()
+		return &pb.OprfReply{Error: pb.StoreProviderError_RATE_LIMITED}, nil
+	}
+	p.lastOprf[user] = now
+	p.mu.Unlock()
+
+	evaluated, err := e.oprf.Evaluate(in.GetBlindedElement())
+	if err == store.ErrorOprfInput {
+		return &pb.OprfReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	} else if err != nil {
+		return nil, err // Unexpected error!
+	}
+	return &pb.OprfReply{Error: pb.StoreProviderError_OK, EvaluatedElement: evaluated}, nil
+}
+
+// PutStore implements the PutStore RPC.
+func (p *DirProvider) PutStore(ctx context.Context, in *pb.PutStoreRequest) (*pb.PutStoreReply, error) {
+	user := in.GetUserId()
+	if !p.writable(ctx, user) {
+		return &pb.PutStoreReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if !validUser(user) {
+		return &pb.PutStoreReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	if in.GetStore() == nil || store.ValidateStoreProto(in.GetStore()) != nil {
+		return &pb.PutStoreReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+
+	p.putMu.Lock()
+	defer p.putMu.Unlock()
+	version, err := p.currentVersion(user)
+	if err == ErrorBadVersion {
+		return &pb.PutStoreReply{Error: pb.StoreProviderError_VERSION_MISMATCH}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if version != in.GetExpectedVersion() {
+		return &pb.PutStoreReply{Error: pb.StoreProviderError_VERSION_MISMATCH, Version: version}, nil
+	}
+
+	// The version is written first, so that it is not reused even if writing
+	// the store fails.
+	table := proto.Clone(in.GetStore()).(*pb.Store)
+	table.Version = version + 1
+	if err = p.writeVersion(user, table.Version); err != nil {
+		return nil, err
+	}
+	if err = p.writeMessage(user, PubSuffix, table); err != nil {
+		return nil, err
+	}
+	p.invalidate(user)
+	p.logPrintf("installed store for %q (version %d)", user, table.Version)
+	return &pb.PutStoreReply{Error: pb.StoreProviderError_OK, Version: table.Version}, nil
+}
+
+// DeleteStore implements the DeleteStore RPC. The user's OPRF key, if any, is
+// not deleted, nor is <user>.version: the next store provisioned for the user
+// must name the version of the deleted store, and its version is one more.
+func (p *DirProvider) DeleteStore(ctx context.Context, in *pb.DeleteStoreRequest) (*pb.DeleteStoreReply, error) {
+	user := in.GetUserId()
+	if !p.writable(ctx, user) {
+		return &pb.DeleteStoreReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if !validUser(user) {
+		return &pb.DeleteStoreReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+
+	p.putMu.Lock()
+	defer p.putMu.Unlock()
+	version, err := p.currentVersion(user)
+	if err == ErrorBadVersion {
+		return &pb.DeleteStoreReply{Error: pb.StoreProviderError_VERSION_MISMATCH}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if version != in.GetExpectedVersion() {
+		return &pb.DeleteStoreReply{Error: pb.StoreProviderError_VERSION_MISMATCH, Version: version}, nil
+	}
+
+	if _, err = os.Stat(p.path(user, PubSuffix)); os.IsNotExist(err) {
+		return &pb.DeleteStoreReply{Error: pb.StoreProviderError_BAD_USER, Version: version}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Record the version in case the store was installed by hand.
+	if err = p.writeVersion(user, version); err != nil {
+		return nil, err
+	}
+	if err = os.Remove(p.path(user, PubSuffix)); err != nil {
+		return nil, err
+	}
+	p.invalidate(user)
+	p.logPrintf("deleted store for %q (version %d)", user, version)
+	return &pb.DeleteStoreReply{Error: pb.StoreProviderError_OK, Version: version}, nil
+}
+
+// GetStoreVersion implements the GetStoreVersion RPC. The version is the one
+// checked by PutStore and DeleteStore. (See currentVersion().) If the user has
+// no store, then the reply is BAD_USER; if the store was deleted, then it
+// includes the version of the deleted store.
+func (p *DirProvider) GetStoreVersion(ctx context.Context, in *pb.StoreVersionRequest) (*pb.StoreVersionReply, error) {
+	user := in.GetUserId()
+	if !p.authorized(ctx, user) {
+		return &pb.StoreVersionReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if !validUser(user) {
+		return &pb.StoreVersionReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+
+	p.putMu.Lock()
+	defer p.putMu.Unlock()
+	version, err := p.currentVersion(user)
+	if err == ErrorBadVersion {
+		return &pb.StoreVersionReply{Error: pb.StoreProviderError_VERSION_MISMATCH}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(p.path(user, PubSuffix)); os.IsNotExist(err) {
+		return &pb.StoreVersionReply{Error: pb.StoreProviderError_BAD_USER, Version: version}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.StoreVersionReply{Error: pb.StoreProviderError_OK, Version: version}, nil
+}
+
+// GetKeyEnvelope implements the GetKeyEnvelope RPC.
+func (p *DirProvider) GetKeyEnvelope(ctx context.Context, in *pb.KeyEnvelopeRequest) (*pb.KeyEnvelopeReply, error) {
+	user := in.GetUserId()
+	if !p.authorized(ctx, user) {
+		return &pb.KeyEnvelopeReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if !validUser(user) {
+		return &pb.KeyEnvelopeReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	env, err := p.readEnvelope(user)
+	if err != nil {
+		return nil, err
+	} else if env == nil {
+		return &pb.KeyEnvelopeReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	return &pb.KeyEnvelopeReply{Error: pb.StoreProviderError_OK, Envelope: env}, nil
+}
+
+// PutKeyEnvelope implements the PutKeyEnvelope RPC. The envelope is written
+// the same way as a store (see PutStore), but it doesn't depend on the store:
+// the user may have an envelope before it has a store.
+func (p *DirProvider) PutKeyEnvelope(ctx context.Context, in *pb.PutKeyEnvelopeRequest) (*pb.PutKeyEnvelopeReply, error) {
+	user := in.GetUserId()
+	if !p.writable(ctx, user) {
+		return &pb.PutKeyEnvelopeReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	if !validUser(user) {
+		return &pb.PutKeyEnvelopeReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	}
+	if in.GetEnvelope() == nil || store.ValidateKeyEnvelope(in.GetEnvelope()) != nil {
+		return &pb.PutKeyEnvelopeReply{Error: pb.StoreProviderError_BAD_REQUEST}, nil
+	}
+
+	p.putMu.Lock()
+	defer p.putMu.Unlock()
+	env, err := p.readEnvelope(user)
+	if err != nil {
+		return nil, err
+	}
+	version := env.GetVersion()
+	if version != in.GetExpectedVersion() {
+		return &pb.PutKeyEnvelopeReply{Error: pb.StoreProviderError_VERSION_MISMATCH, Version: version}, nil
+	}
+
+	env = proto.Clone(in.GetEnvelope()).(*pb.KeyEnvelope)
+	env.Version = version + 1
+	if err = p.writeMessage(user, EnvelopeSuffix, env); err != nil {
+		return nil, err
+	}
+	p.logPrintf("installed key envelope for %q (version %d)", user, env.Version)
+	return &pb.PutKeyEnvelopeReply{Error: pb.StoreProviderError_OK, Version: env.Version}, nil
+}
+
+// GetIndexPage implements the GetIndexPage RPC.
+func (p *DirProvider) GetIndexPage(ctx context.Context, in *pb.IndexPageRequest) (*pb.IndexPageReply, error) {
+	if !p.authorized(ctx, in.GetUserId()) {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_UNAUTHENTICATED}, nil
+	}
+	e, err := p.acquire(in.GetUserId())
+	if err == ErrorBadUser {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_BAD_USER}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer p.release(e)
+	page, pageCt, err := e.pub.GetIndexPage(int(in.GetPage()))
+	if err == store.ErrorNoIndex {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_ITEM_NOT_FOUND}, nil
+	} else if err == store.ErrorIdx {
+		return &pb.IndexPageReply{Error: pb.StoreProviderError_INDEX, PageCt: int32(pageCt)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pb.IndexPageReply{Error: pb.StoreProviderError_OK, Page: page, PageCt: int32(pageCt)}, nil
+}
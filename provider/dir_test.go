@@ -0,0 +1,767 @@
+// Copyright (c) 2017, Christopher Patton.
+// All rights reserved.
+
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cjpatton/store"
+	"github.com/cjpatton/store/client"
+	"github.com/cjpatton/store/pb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var testM = map[string]string{
+	"hello": "world",
+	"hip":   "hop",
+	"merry": "christmas",
+}
+
+// writeStore creates a store for M and writes it to the user's file in dir.
+// The file's modification time is set to mtime.
+func writeStore(t *testing.T, dir, user string, M map[string]string, mtime time.Time, opts ...store.Option) *store.PrivStore {
+	pub, priv, err := store.NewStore(store.GenerateKey(), M, opts...)
+	if err != nil {
+		t.Fatalf("store.NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	data, err := proto.Marshal(pub.GetProto())
+	if err != nil {
+		t.Fatalf("proto.Marshal() fails: %s", err)
+	}
+	path := filepath.Join(dir, user+PubSuffix)
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() fails: %s", err)
+	}
+	if err = os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("os.Chtimes() fails: %s", err)
+	}
+	return priv
+}
+
+// get looks up input in the user's store via p.
+func get(t *testing.T, p *DirProvider, priv *store.PrivStore, user, input string) (string, pb.StoreProviderError) {
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		t.Fatalf("priv.GetIdx() fails: %s", err)
+	}
+	reply, err := p.GetShare(context.Background(),
+		&pb.ShareRequest{UserId: user, X: int32(x), Y: int32(y)})
+	if err != nil {
+		t.Fatalf("p.GetShare() fails: %s", err)
+	}
+	if reply.GetError() != pb.StoreProviderError_OK {
+		return "", reply.GetError()
+	}
+	output, err := priv.GetOutput(input, reply.GetPubShare())
+	if err != nil {
+		t.Fatalf("priv.GetOutput() fails: %s", err)
+	}
+	return output, pb.StoreProviderError_OK
+}
+
+func TestDirProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+
+	mtime := time.Now().Add(-time.Hour)
+	priv := writeStore(t, dir, "alice", testM, mtime)
+	defer priv.Close()
+	for in, out := range testM {
+		if output, code := get(t, p, priv, "alice", in); code != pb.StoreProviderError_OK || output != out {
+			t.Errorf("get(%q) = (%q, %s), expected (%q, OK)", in, output, code, out)
+		}
+	}
+
+	paramsReply, err := p.GetParams(context.Background(), &pb.ParamsRequest{UserId: "alice"})
+	if err != nil {
+		t.Fatalf("p.GetParams() fails: %s", err)
+	}
+	if !proto.Equal(paramsReply.GetParams(), priv.GetParams()) {
+		t.Errorf("p.GetParams() = %v, expected %v", paramsReply.GetParams(), priv.GetParams())
+	}
+
+	for _, user := range []string{"bob", "", "../alice", ".hidden"} {
+		reply, err := p.GetParams(context.Background(), &pb.ParamsRequest{UserId: user})
+		if err != nil {
+			t.Fatalf("p.GetParams() fails: %s", err)
+		}
+		if reply.GetError() != pb.StoreProviderError_BAD_USER {
+			t.Errorf("p.GetParams(%q) returns %s, expected BAD_USER", user, reply.GetError())
+		}
+	}
+
+	// The store is reloaded when the file changes.
+	M := map[string]string{"hello": "goodbye"}
+	priv2 := writeStore(t, dir, "alice", M, mtime.Add(time.Minute))
+	defer priv2.Close()
+	if output, code := get(t, p, priv2, "alice", "hello"); code != pb.StoreProviderError_OK || output != "goodbye" {
+		t.Errorf("get(\"hello\") = (%q, %s), expected (\"goodbye\", OK)", output, code)
+	}
+
+	// A malformed store is not loaded.
+	data, err := ioutil.ReadFile(filepath.Join(dir, "alice"+PubSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range [][]byte{data[:len(data)/2], data[:len(data)-1]} {
+		if err = ioutil.WriteFile(filepath.Join(dir, "carol"+PubSuffix), table, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = p.GetShare(context.Background(), &pb.ShareRequest{UserId: "carol"}); err != store.ErrorBadStore {
+			t.Errorf("p.GetShare() returns %v for a truncated store, expected %q", err, store.ErrorBadStore)
+		}
+	}
+	if err = os.Remove(filepath.Join(dir, "carol"+PubSuffix)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store is unloaded when the file is removed.
+	if err = os.Remove(filepath.Join(dir, "alice"+PubSuffix)); err != nil {
+		t.Fatal(err)
+	}
+	if _, code := get(t, p, priv2, "alice", "hello"); code != pb.StoreProviderError_BAD_USER {
+		t.Errorf("get(\"hello\") returns %s, expected BAD_USER", code)
+	}
+	if p.Loaded() != 0 {
+		t.Errorf("p.Loaded() = %d, expected 0", p.Loaded())
+	}
+}
+
+func TestDirProviderEvict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The budget is only big enough for one store at a time.
+	var evictions int
+	logf := func(format string, args ...interface{}) {
+		if strings.HasPrefix(format, "evicting") {
+			evictions++
+		}
+	}
+	p := NewDirProvider(dir, 1, time.Second, nil, logf)
+	defer p.Close()
+
+	users := []string{"alice", "bob", "carol"}
+	privs := make([]*store.PrivStore, len(users))
+	for i, user := range users {
+		privs[i] = writeStore(t, dir, user, testM, time.Now())
+		defer privs[i].Close()
+	}
+	for round := 0; round < 2; round++ {
+		for i, user := range users {
+			if output, code := get(t, p, privs[i], user, "hip"); code != pb.StoreProviderError_OK || output != "hop" {
+				t.Errorf("get(%q, \"hip\") = (%q, %s), expected (\"hop\", OK)", user, output, code)
+			}
+			if p.Loaded() != 1 {
+				t.Errorf("p.Loaded() = %d, expected 1", p.Loaded())
+			}
+		}
+	}
+	if evictions != 2*len(users)-1 {
+		t.Errorf("logged %d evictions, expected %d", evictions, 2*len(users)-1)
+	}
+}
+
+func TestDirProviderOprf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Hour, nil, nil)
+	defer p.Close()
+
+	priv := writeStore(t, dir, "alice", testM, time.Now())
+	defer priv.Close()
+	req := &pb.OprfRequest{UserId: "alice"}
+	if reply, _ := p.EvaluateOprf(context.Background(), req); reply.GetError() != pb.StoreProviderError_BAD_USER {
+		t.Errorf("p.EvaluateOprf() returns %s, expected BAD_USER", reply.GetError())
+	}
+
+	oprfKey := store.GenerateOprfKey()
+	if err = ioutil.WriteFile(filepath.Join(dir, "alice"+OprfSuffix), oprfKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Force the store to be reloaded.
+	writeStore(t, dir, "alice", testM, time.Now().Add(time.Minute)).Close()
+
+	blind, blinded, err := store.BlindPassword([]byte("password"), nil)
+	if err != nil {
+		t.Fatalf("store.BlindPassword() fails: %s", err)
+	}
+	req.BlindedElement = blinded
+	reply, err := p.EvaluateOprf(context.Background(), req)
+	if err != nil || reply.GetError() != pb.StoreProviderError_OK {
+		t.Fatalf("p.EvaluateOprf() returns (%v, %v), expected OK", reply.GetError(), err)
+	}
+	K, err := blind.Finalize(reply.GetEvaluatedElement())
+	if err != nil {
+		t.Fatalf("blind.Finalize() fails: %s", err)
+	}
+	server, err := store.NewOprfServer(oprfKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := server.DeriveKey([]byte("password"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(K) != string(expected) {
+		t.Error("blind.Finalize() returns the wrong key")
+	}
+
+	// The second evaluation is rate-limited.
+	if reply, _ = p.EvaluateOprf(context.Background(), req); reply.GetError() != pb.StoreProviderError_RATE_LIMITED {
+		t.Errorf("p.EvaluateOprf() returns %s, expected RATE_LIMITED", reply.GetError())
+	}
+}
+
+func TestDirProviderAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, new(Credentials), nil)
+	defer p.Close()
+
+	writeStore(t, dir, "alice", testM, time.Now()).Close()
+	req := &pb.ParamsRequest{UserId: "alice"}
+	if reply, _ := p.GetParams(context.Background(), req); reply.GetError() != pb.StoreProviderError_UNAUTHENTICATED {
+		t.Errorf("p.GetParams() returns %s, expected UNAUTHENTICATED", reply.GetError())
+	}
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+	if reply, _ := p.GetParams(ctx, req); reply.GetError() != pb.StoreProviderError_OK {
+		t.Errorf("p.GetParams() returns %s, expected OK", reply.GetError())
+	}
+
+	// Bob may not replace Alice's store.
+	bob := context.WithValue(context.Background(), userKey{}, "bob")
+	del := &pb.DeleteStoreRequest{UserId: "alice"}
+	if reply, _ := p.DeleteStore(bob, del); reply.GetError() != pb.StoreProviderError_UNAUTHENTICATED {
+		t.Errorf("p.DeleteStore() returns %s, expected UNAUTHENTICATED", reply.GetError())
+	}
+	if reply, _ := p.DeleteStore(ctx, del); reply.GetError() != pb.StoreProviderError_OK {
+		t.Errorf("p.DeleteStore() returns %s, expected OK", reply.GetError())
+	}
+}
+
+func TestDirProviderWritesNeedCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+	ctx := context.Background()
+
+	pub, priv, err := store.NewStore(store.GenerateKey(), testM)
+	if err != nil {
+		t.Fatalf("store.NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if reply, _ := p.PutStore(ctx, &pb.PutStoreRequest{UserId: "alice", Store: pub.GetProto()}); reply.GetError() != pb.StoreProviderError_UNAUTHENTICATED {
+		t.Errorf("p.PutStore() returns %s, expected UNAUTHENTICATED", reply.GetError())
+	}
+	env := &pb.PutKeyEnvelopeRequest{UserId: "alice", Envelope: &pb.KeyEnvelope{}}
+	if reply, _ := p.PutKeyEnvelope(ctx, env); reply.GetError() != pb.StoreProviderError_UNAUTHENTICATED {
+		t.Errorf("p.PutKeyEnvelope() returns %s, expected UNAUTHENTICATED", reply.GetError())
+	}
+
+	writeStore(t, dir, "alice", testM, time.Now()).Close()
+	if reply, _ := p.DeleteStore(ctx, &pb.DeleteStoreRequest{UserId: "alice"}); reply.GetError() != pb.StoreProviderError_UNAUTHENTICATED {
+		t.Errorf("p.DeleteStore() returns %s, expected UNAUTHENTICATED", reply.GetError())
+	}
+
+	// Reads are not affected.
+	if reply, _ := p.GetParams(ctx, &pb.ParamsRequest{UserId: "alice"}); reply.GetError() != pb.StoreProviderError_OK {
+		t.Errorf("p.GetParams() returns %s, expected OK", reply.GetError())
+	}
+}
+
+// directClient implements pb.StoreProviderClient by calling the provider
+// directly.
+type directClient struct {
+	p *DirProvider
+}
+
+func (c directClient) GetShare(ctx context.Context, in *pb.ShareRequest, opts ...grpc.CallOption) (*pb.ShareReply, error) {
+	return c.p.GetShare(ctx, in)
+}
+
+func (c directClient) GetShares(ctx context.Context, in *pb.SharesRequest, opts ...grpc.CallOption) (*pb.SharesReply, error) {
+	return c.p.GetShares(ctx, in)
+}
+
+func (c directClient) GetPirShare(ctx context.Context, in *pb.PirRequest, opts ...grpc.CallOption) (*pb.PirReply, error) {
+	return c.p.GetPirShare(ctx, in)
+}
+
+func (c directClient) GetSimplePirHint(ctx context.Context, in *pb.SimplePirHintRequest, opts ...grpc.CallOption) (*pb.SimplePirHintReply, error) {
+	return c.p.GetSimplePirHint(ctx, in)
+}
+
+func (c directClient) GetSimplePirShare(ctx context.Context, in *pb.PirRequest, opts ...grpc.CallOption) (*pb.PirReply, error) {
+	return c.p.GetSimplePirShare(ctx, in)
+}
+
+func (c directClient) GetParams(ctx context.Context, in *pb.ParamsRequest, opts ...grpc.CallOption) (*pb.ParamsReply, error) {
+	return c.p.GetParams(ctx, in)
+}
+
+func (c directClient) EvaluateOprf(ctx context.Context, in *pb.OprfRequest, opts ...grpc.CallOption) (*pb.OprfReply, error) {
+	return c.p.EvaluateOprf(ctx, in)
+}
+
+func (c directClient) PutStore(ctx context.Context, in *pb.PutStoreRequest, opts ...grpc.CallOption) (*pb.PutStoreReply, error) {
+	return c.p.PutStore(ctx, in)
+}
+
+func (c directClient) DeleteStore(ctx context.Context, in *pb.DeleteStoreRequest, opts ...grpc.CallOption) (*pb.DeleteStoreReply, error) {
+	return c.p.DeleteStore(ctx, in)
+}
+
+func (c directClient) GetStoreVersion(ctx context.Context, in *pb.StoreVersionRequest, opts ...grpc.CallOption) (*pb.StoreVersionReply, error) {
+	return c.p.GetStoreVersion(ctx, in)
+}
+
+func (c directClient) GetKeyEnvelope(ctx context.Context, in *pb.KeyEnvelopeRequest, opts ...grpc.CallOption) (*pb.KeyEnvelopeReply, error) {
+	return c.p.GetKeyEnvelope(ctx, in)
+}
+
+func (c directClient) PutKeyEnvelope(ctx context.Context, in *pb.PutKeyEnvelopeRequest, opts ...grpc.CallOption) (*pb.PutKeyEnvelopeReply, error) {
+	return c.p.PutKeyEnvelope(ctx, in)
+}
+
+func (c directClient) GetIndexPage(ctx context.Context, in *pb.IndexPageRequest, opts ...grpc.CallOption) (*pb.IndexPageReply, error) {
+	return c.p.GetIndexPage(ctx, in)
+}
+
+func TestDirProviderPutStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	p.AllowInsecureWrites = true
+	defer p.Close()
+	c := directClient{p}
+	ctx := context.Background()
+
+	if _, err = client.GetStoreVersion(ctx, c, "alice"); err == nil {
+		t.Error("client.GetStoreVersion() succeeds for a missing store, expected failure")
+	}
+
+	pub, priv, err := store.NewStore(store.GenerateKey(), testM)
+	if err != nil {
+		t.Fatalf("store.NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+	version, err := client.PutStore(ctx, c, "alice", pub, 0)
+	if err != nil {
+		t.Fatalf("client.PutStore() fails: %s", err)
+	}
+	if version != 1 {
+		t.Errorf("client.PutStore() returns version %d, expected 1", version)
+	}
+	if output, code := get(t, p, priv, "alice", "hip"); code != pb.StoreProviderError_OK || output != "hop" {
+		t.Errorf("get(\"hip\") = (%q, %s), expected (\"hop\", OK)", output, code)
+	}
+
+	// Another client tries to create the store.
+	version, err = client.PutStore(ctx, c, "alice", pub, 0)
+	if err != client.ErrorVersionMismatch || version != 1 {
+		t.Errorf("client.PutStore() returns (%d, %v), expected (1, %q)", version, err, client.ErrorVersionMismatch)
+	}
+
+	// Replace the store.
+	update, err := priv.Update(pub, "hip", "hooray")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	if err = pub.ApplyUpdate(update); err != nil {
+		t.Fatalf("pub.ApplyUpdate() fails: %s", err)
+	}
+	next, err := priv.ApplyUpdate(update)
+	if err != nil {
+		t.Fatalf("priv.ApplyUpdate() fails: %s", err)
+	}
+	priv.Close()
+	priv = next
+	if version, err = client.PutStore(ctx, c, "alice", pub, 1); err != nil || version != 2 {
+		t.Fatalf("client.PutStore() returns (%d, %v), expected (2, nil)", version, err)
+	}
+	if output, code := get(t, p, priv, "alice", "hip"); code != pb.StoreProviderError_OK || output != "hooray" {
+		t.Errorf("get(\"hip\") = (%q, %s), expected (\"hooray\", OK)", output, code)
+	}
+	if version, err = client.GetStoreVersion(ctx, c, "alice"); err != nil || version != 2 {
+		t.Errorf("client.GetStoreVersion() returns (%d, %v), expected (2, nil)", version, err)
+	}
+
+	// Malformed stores are rejected.
+	reply, err := p.PutStore(ctx, &pb.PutStoreRequest{UserId: "alice", Store: &pb.Store{}, ExpectedVersion: 2})
+	if err != nil || reply.GetError() != pb.StoreProviderError_BAD_REQUEST {
+		t.Errorf("p.PutStore() returns (%v, %v), expected BAD_REQUEST", reply.GetError(), err)
+	}
+	reply, err = p.PutStore(ctx, &pb.PutStoreRequest{UserId: "../alice", Store: pub.GetProto()})
+	if err != nil || reply.GetError() != pb.StoreProviderError_BAD_USER {
+		t.Errorf("p.PutStore() returns (%v, %v), expected BAD_USER", reply.GetError(), err)
+	}
+
+	// Delete the store.
+	if version, err = client.DeleteStore(ctx, c, "alice", 1); err != client.ErrorVersionMismatch || version != 2 {
+		t.Errorf("client.DeleteStore() returns (%d, %v), expected (2, %q)", version, err, client.ErrorVersionMismatch)
+	}
+	if version, err = client.DeleteStore(ctx, c, "alice", 2); err != nil || version != 2 {
+		t.Fatalf("client.DeleteStore() returns (%d, %v), expected (2, nil)", version, err)
+	}
+	if _, code := get(t, p, priv, "alice", "hip"); code != pb.StoreProviderError_BAD_USER {
+		t.Errorf("get(\"hip\") returns %s, expected BAD_USER", code)
+	}
+	if _, err = client.DeleteStore(ctx, c, "alice", 2); err == nil {
+		t.Error("client.DeleteStore() succeeds for a missing store, expected failure")
+	}
+	versionReply, err := p.GetStoreVersion(ctx, &pb.StoreVersionRequest{UserId: "alice"})
+	if err != nil || versionReply.GetError() != pb.StoreProviderError_BAD_USER || versionReply.GetVersion() != 2 {
+		t.Errorf("p.GetStoreVersion() returns (%v, %d, %v), expected (BAD_USER, 2, nil)",
+			versionReply.GetError(), versionReply.GetVersion(), err)
+	}
+
+	// The version of the deleted store is not reused.
+	if version, err = client.PutStore(ctx, c, "alice", pub, 0); err != client.ErrorVersionMismatch || version != 2 {
+		t.Errorf("client.PutStore() returns (%d, %v), expected (2, %q)", version, err, client.ErrorVersionMismatch)
+	}
+	if version, err = client.PutStore(ctx, c, "alice", pub, 2); err != nil || version != 3 {
+		t.Fatalf("client.PutStore() returns (%d, %v), expected (3, nil)", version, err)
+	}
+
+	// The version of a store installed by hand is the one PutStore checks.
+	writeStore(t, dir, "alice", testM, time.Now()).Close()
+	if version, err = client.GetStoreVersion(ctx, c, "alice"); err != nil || version != 3 {
+		t.Errorf("client.GetStoreVersion() returns (%d, %v), expected (3, nil)", version, err)
+	}
+
+	// A corrupt store may be replaced.
+	if err = ioutil.WriteFile(filepath.Join(dir, "alice"+PubSuffix), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if version, err = client.PutStore(ctx, c, "alice", pub, 3); err != nil || version != 4 {
+		t.Errorf("client.PutStore() returns (%d, %v), expected (4, nil)", version, err)
+	}
+	if _, err = client.DeleteStore(ctx, c, "alice", 4); err != nil {
+		t.Fatalf("client.DeleteStore() fails: %s", err)
+	}
+	if err = os.Remove(filepath.Join(dir, "alice"+VersionSuffix)); err != nil {
+		t.Fatal(err)
+	}
+
+	// If the version can't be read, then the request is rejected.
+	if err = ioutil.WriteFile(filepath.Join(dir, "bob"+PubSuffix), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.PutStore(ctx, c, "bob", pub, 0); err != client.ErrorVersionMismatch {
+		t.Errorf("client.PutStore() returns %v for a corrupt store, expected %q", err, client.ErrorVersionMismatch)
+	}
+	if err = os.Remove(filepath.Join(dir, "bob"+PubSuffix)); err != nil {
+		t.Fatal(err)
+	}
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("%d files left in the directory, expected 0", len(files))
+	}
+}
+
+func TestDirProviderGetShares(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+
+	priv := writeStore(t, dir, "alice", testM, time.Now())
+	defer priv.Close()
+	inputs := []string{"hip", "not an input", "hello"}
+	idx, err := priv.GetIdxMany(inputs)
+	if err != nil {
+		t.Fatalf("priv.GetIdxMany() fails: %s", err)
+	}
+	in := &pb.SharesRequest{UserId: "alice"}
+	for _, index := range idx {
+		in.Index = append(in.Index, &pb.SharesRequest_Index{X: int32(index.X), Y: int32(index.Y)})
+	}
+	in.Index = append(in.Index, &pb.SharesRequest_Index{X: -1, Y: 0})
+
+	reply, err := p.GetShares(context.Background(), in)
+	if err != nil || reply.GetError() != pb.StoreProviderError_OK {
+		t.Fatalf("p.GetShares() returns (%v, %v), expected OK", reply.GetError(), err)
+	}
+	if len(reply.GetShare()) != len(in.Index) {
+		t.Fatalf("p.GetShares() returns %d shares, expected %d", len(reply.GetShare()), len(in.Index))
+	}
+	if code := reply.Share[3].GetError(); code != pb.StoreProviderError_INDEX {
+		t.Errorf("share 3 has error %s, expected INDEX", code)
+	}
+
+	pubShares := make([][]byte, len(inputs))
+	for i := range inputs {
+		pubShares[i] = reply.Share[i].GetPubShare()
+	}
+	outputs, errs := priv.GetOutputMany(inputs, pubShares)
+	for i, input := range inputs {
+		out, ok := testM[input]
+		if ok && (errs[i] != nil || outputs[i] != out) {
+			t.Errorf("output %d = (%q, %v), expected (%q, nil)", i, outputs[i], errs[i], out)
+		} else if !ok && errs[i] != store.ItemNotFound {
+			t.Errorf("output %d has error %v, expected %q", i, errs[i], store.ItemNotFound)
+		}
+	}
+
+	if client.MaxShares != MaxShares {
+		t.Errorf("client.MaxShares = %d, expected %d", client.MaxShares, MaxShares)
+	}
+	in.Index = make([]*pb.SharesRequest_Index, MaxShares+1)
+	if reply, _ = p.GetShares(context.Background(), in); reply.GetError() != pb.StoreProviderError_BAD_REQUEST {
+		t.Errorf("p.GetShares() returns %s, expected BAD_REQUEST", reply.GetError())
+	}
+}
+
+func TestDirProviderGetPirShare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p0 := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p0.Close()
+	p1 := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p1.Close()
+	ctx := context.Background()
+
+	priv := writeStore(t, dir, "alice", testM, time.Now(), store.WithPadding(32))
+	defer priv.Close()
+	for in, out := range testM {
+		output, err := client.GetFromPirProviders(ctx, priv, directClient{p0}, directClient{p1}, "alice", in)
+		if err != nil || output != out {
+			t.Errorf("client.GetFromPirProviders(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	if _, err = client.GetFromPirProviders(ctx, priv, directClient{p0}, directClient{p1}, "alice", "not an input"); err != store.ItemNotFound {
+		t.Errorf("client.GetFromPirProviders() returns %v, expected %q", err, store.ItemNotFound)
+	}
+
+	// The store must be padded to a fixed length.
+	unpadded := writeStore(t, dir, "bob", testM, time.Now())
+	defer unpadded.Close()
+	query0, _, err := unpadded.GetPirQuery("hip")
+	if err != nil {
+		t.Fatalf("priv.GetPirQuery() fails: %s", err)
+	}
+	reply, err := p0.GetPirShare(ctx, &pb.PirRequest{UserId: "bob", Query: query0})
+	if err != nil || reply.GetError() != pb.StoreProviderError_BAD_REQUEST {
+		t.Errorf("p.GetPirShare() returns (%v, %v), expected BAD_REQUEST", reply.GetError(), err)
+	}
+}
+
+func TestDirProviderSimplePir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+	ctx := context.Background()
+
+	priv := writeStore(t, dir, "alice", testM, time.Now(), store.WithPadding(32))
+	defer priv.Close()
+	hint, err := client.GetSimplePirHint(ctx, directClient{p}, "alice")
+	if err != nil {
+		t.Fatalf("client.GetSimplePirHint() fails: %s", err)
+	}
+
+	// The PIR server and the Merkle tree count towards the memory budget.
+	p.mu.Lock()
+	e := p.stores["alice"]
+	if size := e.fi.Size() + e.pub.TreeSize() + e.simplePir.Size(); e.size != size || p.size != size {
+		t.Errorf("(e.size, p.size) = (%d, %d), expected (%d, %d)", e.size, p.size, size, size)
+	}
+	p.mu.Unlock()
+	c, err := store.NewSimplePirClient(priv, hint)
+	if err != nil {
+		t.Fatalf("store.NewSimplePirClient() fails: %s", err)
+	}
+	for in, out := range testM {
+		if output, err := client.GetFromSimplePirProvider(ctx, c, directClient{p}, "alice", in); err != nil || output != out {
+			t.Errorf("client.GetFromSimplePirProvider(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+
+	unpadded := writeStore(t, dir, "bob", testM, time.Now())
+	defer unpadded.Close()
+	reply, err := p.GetSimplePirHint(ctx, &pb.SimplePirHintRequest{UserId: "bob"})
+	if err != nil || reply.GetError() != pb.StoreProviderError_BAD_REQUEST {
+		t.Errorf("p.GetSimplePirHint() returns (%v, %v), expected BAD_REQUEST", reply.GetError(), err)
+	}
+
+	p.Close()
+	if p.size != 0 {
+		t.Errorf("p.size = %d after p.Close(), expected 0", p.size)
+	}
+}
+
+func TestDirProviderKdfHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+	ctx := context.Background()
+
+	header, err := store.NewKdfHeader(pb.PasswordKdf_ARGON2ID)
+	if err != nil {
+		t.Fatalf("store.NewKdfHeader() fails: %s", err)
+	}
+	priv := writeStore(t, dir, "alice", testM, time.Now(), store.WithKdfHeader(header))
+	defer priv.Close()
+	got, err := client.GetKdfHeader(ctx, directClient{p}, "alice")
+	if err != nil {
+		t.Fatalf("client.GetKdfHeader() fails: %s", err)
+	}
+	if !proto.Equal(got, header) {
+		t.Errorf("client.GetKdfHeader() = %v, expected %v", got, header)
+	}
+
+	// A store without a header.
+	priv = writeStore(t, dir, "bob", testM, time.Now())
+	defer priv.Close()
+	if got, err = client.GetKdfHeader(ctx, directClient{p}, "bob"); err != nil || got != nil {
+		t.Errorf("client.GetKdfHeader() = (%v, %v), expected (nil, nil)", got, err)
+	}
+}
+
+func TestDirProviderKeyEnvelope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	p.AllowInsecureWrites = true
+	defer p.Close()
+	ctx := context.Background()
+	c := directClient{p}
+
+	if _, err = client.GetKeyEnvelope(ctx, c, "alice"); err == nil {
+		t.Error("client.GetKeyEnvelope() succeeds without an envelope, expected failure")
+	}
+
+	K, deviceKey := store.GenerateKey(), store.GenerateKey()
+	env := new(pb.KeyEnvelope)
+	if err = store.WrapKey(env, "laptop", K, deviceKey); err != nil {
+		t.Fatalf("store.WrapKey() fails: %s", err)
+	}
+	version, err := client.PutKeyEnvelope(ctx, c, "alice", env, 0)
+	if err != nil || version != 1 {
+		t.Fatalf("client.PutKeyEnvelope() = (%d, %v), expected (1, nil)", version, err)
+	}
+
+	got, err := client.GetKeyEnvelope(ctx, c, "alice")
+	if err != nil {
+		t.Fatalf("client.GetKeyEnvelope() fails: %s", err)
+	}
+	if got.GetVersion() != 1 {
+		t.Errorf("got.GetVersion() = %d, expected 1", got.GetVersion())
+	}
+	if dataKey, err := store.UnwrapKey(got, "laptop", deviceKey); err != nil || !bytes.Equal(dataKey, K) {
+		t.Errorf("store.UnwrapKey() = (%x, %v), expected (%x, nil)", dataKey, err, K)
+	}
+
+	// A concurrent writer that read the old version loses.
+	if err = store.RemoveWrappedKey(got, "laptop"); err != nil {
+		t.Fatalf("store.RemoveWrappedKey() fails: %s", err)
+	}
+	if version, err = client.PutKeyEnvelope(ctx, c, "alice", got, 0); err != client.ErrorVersionMismatch || version != 1 {
+		t.Errorf("client.PutKeyEnvelope() = (%d, %v), expected (1, %q)", version, err, client.ErrorVersionMismatch)
+	}
+	if version, err = client.PutKeyEnvelope(ctx, c, "alice", got, got.GetVersion()); err != nil || version != 2 {
+		t.Errorf("client.PutKeyEnvelope() = (%d, %v), expected (2, nil)", version, err)
+	}
+
+	// Malformed envelopes are rejected.
+	bad := &pb.KeyEnvelope{Key: []*pb.WrappedKey{{Id: "phone"}}}
+	if _, err = client.PutKeyEnvelope(ctx, c, "alice", bad, 2); err == nil {
+		t.Error("client.PutKeyEnvelope() succeeds for a malformed envelope, expected failure")
+	}
+	if _, err = client.PutKeyEnvelope(ctx, c, "../alice", env, 0); err == nil {
+		t.Error("client.PutKeyEnvelope() succeeds for a bad user, expected failure")
+	}
+}
+
+func TestDirProviderGetIndexPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewDirProvider(dir, 0, time.Second, nil, nil)
+	defer p.Close()
+	ctx := context.Background()
+
+	priv := writeStore(t, dir, "alice", testM, time.Now(), store.WithIndex())
+	defer priv.Close()
+	reply, err := p.GetIndexPage(ctx, &pb.IndexPageRequest{UserId: "alice"})
+	if err != nil || reply.GetError() != pb.StoreProviderError_OK {
+		t.Fatalf("p.GetIndexPage() = (%v, %v), expected OK", reply, err)
+	}
+	if reply.GetPageCt() != 1 {
+		t.Errorf("reply.GetPageCt() = %d, expected 1", reply.GetPageCt())
+	}
+	inputs, err := priv.OpenIndexPage(0, reply.GetPage())
+	if err != nil {
+		t.Fatalf("priv.OpenIndexPage() fails: %s", err)
+	}
+	if len(inputs) != len(testM) {
+		t.Errorf("len(inputs) = %d, expected %d", len(inputs), len(testM))
+	}
+	if reply, err = p.GetIndexPage(ctx, &pb.IndexPageRequest{UserId: "alice", Page: 1}); err != nil || reply.GetError() != pb.StoreProviderError_INDEX {
+		t.Errorf("p.GetIndexPage(1) = (%v, %v), expected INDEX", reply, err)
+	}
+
+	// A store without an index.
+	priv = writeStore(t, dir, "bob", testM, time.Now())
+	defer priv.Close()
+	if reply, err = p.GetIndexPage(ctx, &pb.IndexPageRequest{UserId: "bob"}); err != nil || reply.GetError() != pb.StoreProviderError_ITEM_NOT_FOUND {
+		t.Errorf("p.GetIndexPage() = (%v, %v) without an index, expected ITEM_NOT_FOUND", reply, err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+// WithProgress sets a function that priv.Rekey() calls after it reads each page
+// of the index, with the number of edges read so far and the total number of
+// edges. This is useful for reporting the progress of re-keying a large store.
+func WithProgress(progress func(done, total int)) Option {
+	return func(priv *PrivStore) {
+		priv.progress = progress
+	}
+}
+
+// Rekey rebuilds the store under newKey. Rather than the map, it uses the index
+// of the inputs (see WithIndex()) to enumerate the inputs in oldPub and priv to
+// look up their outputs. It returns ErrorNoIndex if oldPub has no index and
+// ErrorBadIndex if the index is not authentic or doesn't match oldPub.
+//
+// The new store has the padding, key schedule, and index of the old one, and
+// its version is one more than the old one's; these may be overridden by opts.
+// (The KDF header is not carried over, since it describes the old key. Pass
+// WithKdfHeader() if the new key is derived from a password.)
+//
+// Rekey doesn't modify oldPub or priv. The old store and its parameters remain
+// valid until the StoreProvider replaces it with the new one, so during the
+// transition, clients with the old key continue to use the old store while the
+// new key is distributed. Once every client has the new key, clients should
+// require the new version (see NewPrivStore()), so that the old store is
+// rejected if the server replays it.
+func (priv *PrivStore) Rekey(oldPub *PubStore, newKey []byte, opts ...Option) (*PubStore, *PrivStore, error) {
+	cfg := new(PrivStore)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	inputs, outputs, err := priv.readIndex(oldPub, cfg.progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newStore(newKey, inputs, outputs, append([]Option{
+		func(newPriv *PrivStore) {
+			newPriv.padding, newPriv.paddedBytes = priv.padding, priv.paddedBytes
+		},
+		WithKeySchedule(priv.schedule),
+		WithVersion(priv.version + 1),
+		WithIndex(),
+	}, opts...)...)
+}
+
+// readIndex enumerates the inputs in the index of pub that were not deleted, in
+// the order they were inserted, and looks up their outputs. If progress != nil,
+// then it is called after each page.
+func (priv *PrivStore) readIndex(pub *PubStore, progress func(done, total int)) (inputs, outputs [][]byte, err error) {
+	done := 0
+	err = priv.forEachPage(pub, func(total int, entries []indexEntry) error {
+		for _, input := range liveInputs(entries) {
+			output, err := priv.Get(pub, input)
+			if err == ItemNotFound {
+				return ErrorBadIndex
+			} else if err != nil {
+				return err
+			}
+			inputs = append(inputs, []byte(input))
+			outputs = append(outputs, []byte(output))
+		}
+		done += len(entries)
+		if progress != nil {
+			progress(done, total)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if checkDistinct(inputs) != nil {
+		return nil, nil, ErrorBadIndex
+	}
+	return inputs, outputs, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+)
+
+func TestRekey(t *testing.T) {
+	oldKey := GenerateKey()
+	M := bigM(IndexPageSize + 10)
+	pub, priv, err := NewStore(oldKey, M, WithIndex(), WithVersion(3), WithBucketPadding())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	var calls, lastDone, lastTotal int
+	newKey := GenerateKey()
+	newPub, newPriv, err := priv.Rekey(pub, newKey, WithProgress(func(done, total int) {
+		if done < lastDone {
+			t.Errorf("progress went from %d to %d", lastDone, done)
+		}
+		calls++
+		lastDone, lastTotal = done, total
+	}))
+	if err != nil {
+		t.Fatalf("priv.Rekey() fails: %s", err)
+	}
+	defer newPub.Close()
+	defer newPriv.Close()
+	if calls != 2 || lastDone != len(M) || lastTotal != len(M) {
+		t.Errorf("progress called %d times, last with (%d, %d), expected 2 times with (%d, %d)",
+			calls, lastDone, lastTotal, len(M), len(M))
+	}
+	checkStore(t, newPub, newPriv, M)
+
+	// The new store inherits the parameters of the old one and is one
+	// version newer.
+	params := newPub.GetProto().GetDict().GetParams()
+	if params.GetVersion() != 4 {
+		t.Errorf("params.GetVersion() = %d, expected 4", params.GetVersion())
+	}
+	if params.GetOutputPadding() != pb.OutputPadding_POWER_OF_TWO {
+		t.Errorf("params.GetOutputPadding() = %s, expected %s", params.GetOutputPadding(), pb.OutputPadding_POWER_OF_TWO)
+	}
+	if len(newPub.index) == 0 {
+		t.Error("the new store has no index")
+	}
+
+	// During the transition, both keys open their stores, and a client that
+	// requires the new version rejects the old store.
+	oldParams := pub.GetProto().GetDict().GetParams()
+	for _, tc := range []struct {
+		K      []byte
+		pub    *PubStore
+		params *pb.Params
+	}{
+		{oldKey, pub, oldParams},
+		{newKey, newPub, params},
+	} {
+		priv2, err := NewPrivStore(tc.K, tc.params, 3)
+		if err != nil {
+			t.Fatalf("NewPrivStore() fails: %s", err)
+		}
+		checkStore(t, tc.pub, priv2, M)
+		priv2.Close()
+	}
+	if _, err = NewPrivStore(oldKey, oldParams, 4); err != ErrorStaleStore {
+		t.Errorf("NewPrivStore() returns %v for the old store, expected %q", err, ErrorStaleStore)
+	}
+	if _, err = NewPrivStore(oldKey, params, 0); err != ErrorBadParams {
+		t.Errorf("NewPrivStore() returns %v for the old key, expected %q", err, ErrorBadParams)
+	}
+
+	// The new store can be re-keyed again.
+	pub3, priv3, err := newPriv.Rekey(newPub, GenerateKey(), WithVersion(10))
+	if err != nil {
+		t.Fatalf("newPriv.Rekey() fails: %s", err)
+	}
+	defer pub3.Close()
+	defer priv3.Close()
+	checkStore(t, pub3, priv3, M)
+	if v := pub3.GetProto().GetDict().GetParams().GetVersion(); v != 10 {
+		t.Errorf("version = %d, expected 10", v)
+	}
+}
+
+func TestRekeyNoIndex(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if _, _, err = priv.Rekey(pub, GenerateKey()); err != ErrorNoIndex {
+		t.Errorf("priv.Rekey() returns %v, expected %q", err, ErrorNoIndex)
+	}
+}
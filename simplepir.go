@@ -0,0 +1,334 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// The parameters of single-server PIR. The scheme is SimplePIR, as described
+// in "One Server for the Price of Two: Simple and Fast Single-Server Private
+// Information Retrieval" (Henzinger et al., USENIX Security 2023). The database
+// is a matrix over Z_p with one column per record, where p = 2^8, and its
+// security follows from LWE with secret dimension 1024, modulus q = 2^32, and
+// error of standard deviation about 6.6.
+const (
+	// The secret dimension of LWE.
+	simplePirDim = 1024
+
+	// The log of q/p, the scaling factor of the selection vector.
+	simplePirLogDelta = 24
+
+	// The number of random bytes used to sample each coordinate of the error.
+	// The error is sampled from the centered binomial distribution, which has
+	// variance 8*simplePirNoiseBytes/2.
+	simplePirNoiseBytes = 11
+
+	// The largest table for which the error is small enough to decrypt.
+	simplePirMaxTableLen = 1 << 20
+
+	// The length of the seed of the public matrix.
+	simplePirSeedBytes = 16
+)
+
+// Returned by NewSimplePirServer() if the table is too large.
+const ErrorPirTooLarge = Error("table is too large for PIR")
+
+// Returned by NewSimplePirClient() if the hint is malformed or doesn't match
+// the parameters of the store.
+const ErrorPirHint = Error("bad PIR hint")
+
+// SimplePirServer answers single-server PIR queries for the rows of a store.
+// Unlike two-server PIR (see pub.GetPirShare()), this requires only one server,
+// at the cost of a larger response and a hint that the client downloads once.
+//
+// The database consists of the same records as for two-server PIR. The server
+// preprocesses the database by multiplying it by a public matrix derived from
+// a seed; the product is the hint. A query for the record of row x is an LWE
+// encryption of the x-th unit vector, and the server answers it by multiplying
+// the database by the query. The client decrypts the answer using the hint.
+//
+// The server is a snapshot of the store; if the store is updated, then a new
+// server must be created, and clients must download its hint.
+type SimplePirServer struct {
+	seed        []byte
+	recordBytes int
+	tableLen    int
+	db          [][]byte // db[i][x] is the i-th byte of the record of row x.
+	hint        []uint32 // The product of db and the public matrix.
+}
+
+// NewSimplePirServer preprocesses pub for single-server PIR. It takes time
+// proportional to the size of pub times the secret dimension of LWE (1024). It
+// returns ErrorPirSealed unless the outputs are padded with WithPadding().
+func NewSimplePirServer(pub *PubStore) (*SimplePirServer, error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return nil, ErrorClosed
+	}
+	sealedBytes, maxDegree, err := pub.pirLayout()
+	if err != nil {
+		return nil, err
+	}
+	table := pub.dict.getTable()
+	rowBytes := pub.dict.rowBytes()
+	s := &SimplePirServer{
+		seed:        make([]byte, simplePirSeedBytes),
+		recordBytes: rowBytes + maxDegree*sealedBytes,
+		tableLen:    len(table) / rowBytes,
+	}
+	if s.tableLen > simplePirMaxTableLen {
+		return nil, ErrorPirTooLarge
+	}
+	if _, err = rand.Read(s.seed); err != nil {
+		return nil, err
+	}
+
+	s.db = make([][]byte, s.recordBytes)
+	for i := range s.db {
+		s.db[i] = make([]byte, s.tableLen)
+	}
+	record := make([]byte, s.recordBytes)
+	for x := 0; x < s.tableLen; x++ {
+		for i := range record {
+			record[i] = 0
+		}
+		pub.xorRecord(record, table, x, sealedBytes)
+		for i := range record {
+			s.db[i][x] = record[i]
+		}
+	}
+
+	// Compute the hint, one row of the public matrix at a time.
+	a, err := newLweMatrix(s.seed)
+	if err != nil {
+		return nil, err
+	}
+	s.hint = make([]uint32, s.recordBytes*simplePirDim)
+	row := make([]uint32, simplePirDim)
+	for x := 0; x < s.tableLen; x++ {
+		a.row(row, x)
+		for i := 0; i < s.recordBytes; i++ {
+			d := uint32(s.db[i][x])
+			if d == 0 {
+				continue
+			}
+			h := s.hint[i*simplePirDim : (i+1)*simplePirDim]
+			for k := range row {
+				h[k] += d * row[k]
+			}
+		}
+	}
+	return s, nil
+}
+
+// Size returns the (approximate) number of bytes of memory used by s, i.e., the
+// size of the database and the hint.
+func (s *SimplePirServer) Size() int64 {
+	return int64(s.recordBytes)*int64(s.tableLen) + 4*int64(len(s.hint))
+}
+
+// Hint returns the hint that the client needs in order to make queries.
+func (s *SimplePirServer) Hint() *pb.SimplePirHint {
+	return &pb.SimplePirHint{
+		Seed:        append([]byte(nil), s.seed...),
+		RecordBytes: int32(s.recordBytes),
+		TableLen:    int32(s.tableLen),
+		Hint:        encodeUint32s(s.hint),
+	}
+}
+
+// Answer computes the answer to each query computed by client.Query(). It
+// returns ErrorPirQuery if a query has the wrong length.
+func (s *SimplePirServer) Answer(query [][]byte) ([][]byte, error) {
+	answers := make([][]byte, len(query))
+	for j := range query {
+		if len(query[j]) != 4*s.tableLen {
+			return nil, ErrorPirQuery
+		}
+		qu := decodeUint32s(query[j])
+		ans := make([]uint32, s.recordBytes)
+		for i := range ans {
+			var sum uint32
+			for x, d := range s.db[i] {
+				sum += uint32(d) * qu[x]
+			}
+			ans[i] = sum
+		}
+		answers[j] = encodeUint32s(ans)
+	}
+	return answers, nil
+}
+
+// SimplePirClient makes single-server PIR queries for the rows of a store. (See
+// SimplePirServer.)
+type SimplePirClient struct {
+	priv        *PrivStore
+	a           *lweMatrix
+	recordBytes int
+	tableLen    int
+	hint        []uint32
+}
+
+// SimplePirQuery is the state of the client between making a query and
+// computing the output from the answer.
+type SimplePirQuery struct {
+	input   string
+	secrets [2][]uint32
+}
+
+// NewSimplePirClient creates a client for the store of priv from the hint
+// downloaded from the server.
+func NewSimplePirClient(priv *PrivStore, hint *pb.SimplePirHint) (*SimplePirClient, error) {
+	params := priv.dict.GetParams()
+	if params == nil {
+		return nil, ErrorClosed
+	}
+	c := &SimplePirClient{
+		priv:        priv,
+		recordBytes: int(hint.GetRecordBytes()),
+		tableLen:    int(hint.GetTableLen()),
+	}
+	if len(hint.GetSeed()) != simplePirSeedBytes ||
+		c.tableLen != int(params.GetTableLen()) ||
+		c.recordBytes < int(params.GetRowBytes()) ||
+		len(hint.GetHint()) != 4*c.recordBytes*simplePirDim {
+		return nil, ErrorPirHint
+	}
+	var err error
+	if c.a, err = newLweMatrix(hint.GetSeed()); err != nil {
+		return nil, err
+	}
+	c.hint = decodeUint32s(hint.GetHint())
+	return c, nil
+}
+
+// Query computes the query for input. It returns the state needed to compute
+// the output and the query, which consists of an encrypted query for each of
+// x and y, where (x, y) is the index of input.
+//
+// Computing the query takes time proportional to the length of the table times
+// the secret dimension of LWE (1024).
+func (c *SimplePirClient) Query(input string) (*SimplePirQuery, [][]byte, error) {
+	x, y, err := c.priv.GetIdx(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	noise := bufio.NewReader(rand.Reader)
+	q := &SimplePirQuery{input: input}
+	query := make([][]byte, 2)
+	row := make([]uint32, simplePirDim)
+	e := make([]byte, 2*simplePirNoiseBytes)
+	for j, sel := range []int{x, y} {
+		// The query is A*s + e + delta*u, where u is the sel-th unit vector.
+		secret := make([]byte, 4*simplePirDim)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		q.secrets[j] = decodeUint32s(secret)
+		qu := make([]uint32, c.tableLen)
+		for i := range qu {
+			c.a.row(row, i)
+			for k := range row {
+				qu[i] += row[k] * q.secrets[j][k]
+			}
+			if _, err = io.ReadFull(noise, e); err != nil {
+				return nil, nil, err
+			}
+			qu[i] += noiseSample(e)
+		}
+		qu[sel] += 1 << simplePirLogDelta
+		query[j] = encodeUint32s(qu)
+	}
+	return q, query, nil
+}
+
+// Output computes the output from the answer to the query. It returns
+// ItemNotFound if the input is not in the map, and ErrorPirCommitment if the
+// commitment of the store is set.
+func (c *SimplePirClient) Output(q *SimplePirQuery, answer [][]byte) (string, error) {
+	if len(answer) != 2 {
+		return "", ErrorPirShare
+	}
+	records := make([][]byte, 2)
+	for j := range records {
+		if len(answer[j]) != 4*c.recordBytes {
+			return "", ErrorPirShare
+		}
+		ans := decodeUint32s(answer[j])
+		records[j] = make([]byte, c.recordBytes)
+		for i := range ans {
+			h := c.hint[i*simplePirDim : (i+1)*simplePirDim]
+			v := ans[i]
+			for k := range h {
+				v -= h[k] * q.secrets[j][k]
+			}
+			// Round to the nearest multiple of delta.
+			records[j][i] = byte((v + 1<<(simplePirLogDelta-1)) >> simplePirLogDelta)
+		}
+	}
+	return c.priv.pirOutput(q.input, records[0], records[1])
+}
+
+// lweMatrix is the public matrix of LWE. Its rows are derived from the seed
+// using AES in counter mode, so they can be computed on the fly.
+type lweMatrix struct {
+	block cipher.Block
+}
+
+func newLweMatrix(seed []byte) (*lweMatrix, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, err
+	}
+	return &lweMatrix{block}, nil
+}
+
+// row sets dst to the x-th row of the matrix.
+func (a *lweMatrix) row(dst []uint32, x int) {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(x)*4*simplePirDim/aes.BlockSize)
+	buf := make([]byte, 4*simplePirDim)
+	cipher.NewCTR(a.block, iv).XORKeyStream(buf, buf)
+	for k := range dst {
+		dst[k] = binary.LittleEndian.Uint32(buf[4*k:])
+	}
+}
+
+// noiseSample maps 2*simplePirNoiseBytes random bytes to a sample of the
+// centered binomial distribution.
+func noiseSample(e []byte) uint32 {
+	var n int
+	for i := 0; i < simplePirNoiseBytes; i++ {
+		n += bits.OnesCount8(e[i]) - bits.OnesCount8(e[simplePirNoiseBytes+i])
+	}
+	return uint32(n)
+}
+
+// encodeUint32s encodes v as a string of bytes in little-endian order.
+func encodeUint32s(v []uint32) []byte {
+	b := make([]byte, 4*len(v))
+	for i := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], v[i])
+	}
+	return b
+}
+
+// decodeUint32s is the inverse of encodeUint32s().
+func decodeUint32s(b []byte) []uint32 {
+	v := make([]uint32, len(b)/4)
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return v
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// simplePirGet looks up input using single-server PIR.
+func simplePirGet(t *testing.T, s *SimplePirServer, c *SimplePirClient, input string) (string, error) {
+	q, query, err := c.Query(input)
+	if err != nil {
+		t.Fatalf("c.Query() fails: %s", err)
+	}
+	answer, err := s.Answer(query)
+	if err != nil {
+		t.Fatalf("s.Answer() fails: %s", err)
+	}
+	return c.Output(q, answer)
+}
+
+func TestSimplePir(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithPadding(32))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	s, err := NewSimplePirServer(pub)
+	if err != nil {
+		t.Fatalf("NewSimplePirServer() fails: %s", err)
+	}
+	c, err := NewSimplePirClient(priv, s.Hint())
+	if err != nil {
+		t.Fatalf("NewSimplePirClient() fails: %s", err)
+	}
+	for in, out := range goodM {
+		if output, err := simplePirGet(t, s, c, in); err != nil || output != out {
+			t.Errorf("simplePirGet(%q) = (%q, %v), expected (%q, nil)", in, output, err, out)
+		}
+	}
+	if _, err := simplePirGet(t, s, c, "not an input"); err != ItemNotFound {
+		t.Errorf("simplePirGet() returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// Each query is encrypted under a fresh secret, and the answer can only be
+	// decrypted with the secret of its query.
+	q0, query0, err := c.Query("hip")
+	if err != nil {
+		t.Fatalf("c.Query() fails: %s", err)
+	}
+	_, query1, err := c.Query("hip")
+	if err != nil {
+		t.Fatalf("c.Query() fails: %s", err)
+	}
+	if string(query0[0]) == string(query1[0]) {
+		t.Error("c.Query() returns the same query twice")
+	}
+	answer, err := s.Answer(query1)
+	if err != nil {
+		t.Fatalf("s.Answer() fails: %s", err)
+	}
+	if _, err = c.Output(q0, answer); err != ItemNotFound {
+		t.Errorf("c.Output() returns %v for the wrong secret, expected %q", err, ItemNotFound)
+	}
+}
+
+func TestBadSimplePir(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	if _, err = NewSimplePirServer(pub); err != ErrorPirSealed {
+		t.Errorf("NewSimplePirServer() returns %v for an unpadded store, expected %q", err, ErrorPirSealed)
+	}
+
+	pub, priv, err = NewStore(GenerateKey(), goodM, WithPadding(32))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	s, err := NewSimplePirServer(pub)
+	if err != nil {
+		t.Fatalf("NewSimplePirServer() fails: %s", err)
+	}
+	if _, err = s.Answer([][]byte{{1, 2, 3}}); err != ErrorPirQuery {
+		t.Errorf("s.Answer() returns %v for a short query, expected %q", err, ErrorPirQuery)
+	}
+
+	hint := s.Hint()
+	hint.TableLen++
+	if _, err = NewSimplePirClient(priv, hint); err != ErrorPirHint {
+		t.Errorf("NewSimplePirClient() returns %v for the wrong table length, expected %q", err, ErrorPirHint)
+	}
+	hint = s.Hint()
+	hint.Hint = hint.Hint[1:]
+	if _, err = NewSimplePirClient(priv, hint); err != ErrorPirHint {
+		t.Errorf("NewSimplePirClient() returns %v for a short hint, expected %q", err, ErrorPirHint)
+	}
+
+	c, err := NewSimplePirClient(priv, s.Hint())
+	if err != nil {
+		t.Fatalf("NewSimplePirClient() fails: %s", err)
+	}
+	q, query, err := c.Query("hip")
+	if err != nil {
+		t.Fatalf("c.Query() fails: %s", err)
+	}
+	answer, err := s.Answer(query)
+	if err != nil {
+		t.Fatalf("s.Answer() fails: %s", err)
+	}
+	if _, err = c.Output(q, answer[:1]); err != ErrorPirShare {
+		t.Errorf("c.Output() returns %v for a missing answer, expected %q", err, ErrorPirShare)
+	}
+}
+
+// benchmarkMap returns a map with n inputs.
+func benchmarkMap(n int) map[string]string {
+	M := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		M[fmt.Sprintf("input %d", i)] = fmt.Sprintf("output %d", i)
+	}
+	return M
+}
+
+const benchmarkInputs = 256
+
+func BenchmarkGetShare(b *testing.B) {
+	pub, priv, err := NewStore(GenerateKey(), benchmarkMap(benchmarkInputs), WithPadding(32))
+	if err != nil {
+		b.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	x, y, err := priv.GetIdx("input 0")
+	if err != nil {
+		b.Fatalf("priv.GetIdx() fails: %s", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = pub.GetShare(x, y); err != nil {
+			b.Fatalf("pub.GetShare() fails: %s", err)
+		}
+	}
+}
+
+func BenchmarkSimplePirAnswer(b *testing.B) {
+	pub, priv, err := NewStore(GenerateKey(), benchmarkMap(benchmarkInputs), WithPadding(32))
+	if err != nil {
+		b.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	s, err := NewSimplePirServer(pub)
+	if err != nil {
+		b.Fatalf("NewSimplePirServer() fails: %s", err)
+	}
+	c, err := NewSimplePirClient(priv, s.Hint())
+	if err != nil {
+		b.Fatalf("NewSimplePirClient() fails: %s", err)
+	}
+	_, query, err := c.Query("input 0")
+	if err != nil {
+		b.Fatalf("c.Query() fails: %s", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = s.Answer(query); err != nil {
+			b.Fatalf("s.Answer() fails: %s", err)
+		}
+	}
+}
+
+func BenchmarkSimplePirClient(b *testing.B) {
+	pub, priv, err := NewStore(GenerateKey(), benchmarkMap(benchmarkInputs), WithPadding(32))
+	if err != nil {
+		b.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	s, err := NewSimplePirServer(pub)
+	if err != nil {
+		b.Fatalf("NewSimplePirServer() fails: %s", err)
+	}
+	c, err := NewSimplePirClient(priv, s.Hint())
+	if err != nil {
+		b.Fatalf("NewSimplePirClient() fails: %s", err)
+	}
+	_, query, err := c.Query("input 0")
+	if err != nil {
+		b.Fatalf("c.Query() fails: %s", err)
+	}
+	answer, err := s.Answer(query)
+	if err != nil {
+		b.Fatalf("s.Answer() fails: %s", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q, _, err := c.Query("input 0")
+		if err != nil {
+			b.Fatalf("c.Query() fails: %s", err)
+		}
+		// The answer is for a different secret, so only the time matters.
+		c.Output(q, answer)
+	}
+}
+
+func BenchmarkSimplePirPreprocess(b *testing.B) {
+	pub, priv, err := NewStore(GenerateKey(), benchmarkMap(benchmarkInputs), WithPadding(32))
+	if err != nil {
+		b.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = NewSimplePirServer(pub); err != nil {
+			b.Fatalf("NewSimplePirServer() fails: %s", err)
+		}
+	}
+}
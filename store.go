@@ -4,13 +4,14 @@
 package store
 
 import (
-	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/cjpatton/store/pb"
 	"golang.org/x/crypto/pbkdf2"
@@ -19,13 +20,21 @@ import (
 // Length of key for sealing the outputs. (AEAD is AES128-GCM.)
 const SealKeyBytes = 16
 
-// Length of the store key.
+// Length of key for sealing the outputs with the HKDF_SHA256_AES256 key
+// schedule. (AEAD is AES256-GCM.)
+const SealKey256Bytes = 32
+
+// Length of the store key. The keys of the store are derived from it by the key
+// schedule. (See WithKeySchedule().)
 const KeyBytes = DictKeyBytes + SealKeyBytes
 
 // Returned by NewStore() in case the number of elements in the input exceeds
 // the number of unique counters.
 const ErrorMapTooLarge = Error("input map is too large")
 
+// Returned by ValidateStoreProto() if the store is malformed.
+const ErrorBadStore = Error("malformed store")
+
 // GenerateKey generates a fresh, random key and returns it.
 func GenerateKey() []byte {
 	K := make([]byte, KeyBytes)
@@ -37,7 +46,9 @@ func GenerateKey() []byte {
 }
 
 // DeriveKeyFromPassword derives a key from a password and (optional) salt and
-// returns it.
+// returns it. The KDF is PBKDF2-SHA256 with 4096 iterations; new stores should
+// use DeriveKeyFromHeader() instead, which supports stronger KDFs and records
+// their parameters in the store.
 //
 // If salt == nil, then no salt is used. Note that the salt is not the same as
 // pb.Params.Salt. pb.Params.Salt is generated by NewDict(), which in turn
@@ -47,37 +58,148 @@ func DeriveKeyFromPassword(password, salt []byte) []byte {
 }
 
 // Stores the public representation of the map.
+//
+// The methods of PubStore are safe for concurrent use. Queries may be served
+// while an update is being applied.
 type PubStore struct {
+	mu     sync.RWMutex // Protects the fields below.
 	dict   *PubDict
 	sealed [][]byte
 	g      graph
+	ctr    int // The next unused nonce counter.
+
+	// The padding of the outputs. This is not used by pub, but it is needed
+	// by the client to unpad the outputs.
+	padding     pb.OutputPadding
+	paddedBytes int
+
+	// The key schedule and the header of the password KDF. These are not
+	// used by pub, but they are needed by the client to derive the keys.
+	schedule  pb.KeySchedule
+	kdfHeader *pb.KdfHeader
+
+	// The version, creation time, and MAC of the store. These are not used by
+	// pub, but they are needed by the client. (See WithVersion().)
+	version int64
+	created int64
+	mac     []byte
+
+	// The sealed pages of the index of the inputs, if any. See WithIndex().
+	index [][]byte
+
+	// The Merkle tree of the rows and sealed outputs, and the commitment of
+	// the store, or nil if they haven't been computed yet. (See
+	// pub.getTree().) treeMu protects them while pub.mu is held for reading.
+	treeMu     sync.Mutex
+	tree       merkleTree
+	commitment []byte
 }
 
 // Stores the private context used to query the map.
+//
+// The methods of PrivStore are safe for concurrent use, except Close().
 type PrivStore struct {
-	dict *PrivDict
-	aead cipher.AEAD
+	dict      *PrivDict
+	aead      cipher.AEAD
+	indexAead cipher.AEAD // Seals the index of the inputs. See WithIndex().
+
+	// The keys derived from the store key. The updates computed by priv
+	// authenticate the parameters of the store with them. (See priv.Insert().)
+	keys *storeKeys
+
+	// Protects nextCtr, the next counter that may be reserved by an update.
+	// (See priv.reserveCtrs().)
+	ctrMu   sync.Mutex
+	nextCtr int
+
+	// The padding of the outputs. See WithPadding() and WithBucketPadding().
+	padding     pb.OutputPadding
+	paddedBytes int
+
+	// The key schedule. See WithKeySchedule().
+	schedule pb.KeySchedule
+
+	// The header of the password KDF, if any. This is only used by NewStore().
+	// See WithKdfHeader().
+	kdfHeader *pb.KdfHeader
+
+	// The version, creation time, and MAC of the store. See WithVersion().
+	version int64
+	created int64
+	mac     []byte
+
+	// The commitment of the store, if set. See priv.SetCommitment().
+	commitment []byte
+
+	// Whether to build an index of the inputs, and the function that reports
+	// the progress of priv.Rekey(). These are only used by NewStore() and
+	// priv.Rekey(). See WithIndex() and WithProgress().
+	indexed  bool
+	progress func(done, total int)
 }
 
-// NewStore creates a new store for key K and map M.
+// NewStore creates a new store for key K and map M. By default, the length of
+// each sealed output is the length of the output plus 16 bytes; the outputs
+// may be padded by passing WithPadding() or WithBucketPadding(). The keys of the
+// store are derived from K using DefaultKeySchedule, unless WithKeySchedule()
+// is passed.
 //
-// You must call pub.Free() and priv.Free() before these variables go out of
-// scope. This is necessary because these structures contain memory allocated
-// from the heap in C.
-func NewStore(K []byte, M map[string]string) (pub *PubStore, priv *PrivStore, err error) {
-
-	pub = new(PubStore)
-	priv = new(PrivStore)
+// You should call pub.Close() and priv.Close() when you are done with these
+// variables. These structures may contain memory allocated from the heap in C;
+// if they are not closed, then the memory is released when the garbage
+// collector finalizes them.
+func NewStore(K []byte, M map[string]string, opts ...Option) (pub *PubStore, priv *PrivStore, err error) {
+	inputs := make([][]byte, 0, len(M))
+	outputs := make([][]byte, 0, len(M))
+	for in, out := range M {
+		inputs = append(inputs, []byte(in))
+		outputs = append(outputs, []byte(out))
+	}
+	return newStore(K, inputs, outputs, opts...)
+}
 
-	// Set up context for AEAD.
-	block, err := aes.NewCipher(K[:DictKeyBytes])
+// newStore creates a new store for key K and the map of each inputs[i] to
+// outputs[i]. The inputs must be distinct.
+func newStore(K []byte, inputs, outputs [][]byte, opts ...Option) (*PubStore, *PrivStore, error) {
+	priv, err := newPrivStore(K, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	priv.aead, err = cipher.NewGCM(block)
+	pub, err := priv.build(inputs, outputs)
 	if err != nil {
 		return nil, nil, err
 	}
+	return pub, priv, nil
+}
+
+// newPrivStore applies the options, checks them, and derives the keys of a new
+// store from K. The dictionary of priv is set by priv.build().
+func newPrivStore(K []byte, opts []Option) (*PrivStore, error) {
+	priv := &PrivStore{schedule: DefaultKeySchedule}
+	for _, opt := range opts {
+		opt(priv)
+	}
+	if err := priv.checkPadding(); err != nil {
+		return nil, err
+	}
+	if priv.kdfHeader != nil && checkKdfHeader(priv.kdfHeader) != nil {
+		return nil, ErrorBadKdf
+	}
+
+	// Derive the keys and set up context for AEAD.
+	if _, err := priv.setKeys(K); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// build creates the public store for the map of each inputs[i] to outputs[i]
+// and sets the dictionary, creation time, and MAC of priv. The inputs must be
+// distinct.
+func (priv *PrivStore) build(inputs, outputs [][]byte) (*PubStore, error) {
+	pub := new(PubStore)
+	pub.padding, pub.paddedBytes = priv.padding, priv.paddedBytes
+	pub.schedule, pub.kdfHeader = priv.schedule, priv.kdfHeader
 
 	// AEAD nonce is the dictionary salt plus a counter.
 	//
@@ -85,42 +207,55 @@ func NewStore(K []byte, M map[string]string) (pub *PubStore, priv *PrivStore, er
 	// ensure that it is long enough to uniquely encode each input/output pair
 	// in the map.
 	ctrBytes := priv.aead.NonceSize() - SaltBytes
-	if len(M) > (1 << (8 * uint(ctrBytes))) {
-		return nil, nil, ErrorMapTooLarge
+	itemCt := len(inputs)
+	if itemCt > maxCtr(ctrBytes) {
+		return nil, ErrorMapTooLarge
 	}
 
-	inputs := make([][]byte, len(M))
-	outputs := make([][]byte, len(M))
-	i := 0
-	for in, out := range M {
-		inputs[i] = []byte(in)
-		outputs[i] = []byte(out)
-		i++
+	// Map each input to a counter. This is what will actually be stored by
+	// pub.dict.
+	padded := make([][]byte, itemCt)
+	ctrs := make([][]byte, itemCt)
+	for i := 0; i < itemCt; i++ {
+		var err error
+		if padded[i], err = priv.pad(outputs[i]); err != nil {
+			return nil, err
+		}
+		ctrs[i] = make([]byte, ctrBytes)
+		binary.LittleEndian.PutUint32(ctrs[i], uint32(i))
 	}
 
-	// Create a *cMap for inputs to counters. This is what will actually be
-	// stored by pub.dict.
-	cN := newCtrCMap(inputs, ctrBytes)
-	defer cN.free()
-
 	// Construct the graph.
+	var err error
 	pub.dict, priv.dict, pub.g, err = newDictAndGraph(
-		K[DictKeyBytes:], cN, 0, false)
+		priv.keys.prf, inputs, ctrs, 0, false)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Encrypt each output and store in pub.sealed.
-	nonce := cBytesToBytes(priv.dict.params.salt, priv.dict.params.salt_bytes)
-	ctr := make([]byte, ctrBytes)
-	pub.sealed = make([][]byte, len(M))
-	for i := 0; i < len(M); i++ {
-		binary.LittleEndian.PutUint32(ctr, uint32(i))
-		pub.sealed[i] = priv.aead.Seal(nil, append(nonce, ctr...),
-			outputs[i], inputs[i])
+	nonce := priv.dict.salt()
+	pub.sealed = make([][]byte, itemCt)
+	for i := 0; i < itemCt; i++ {
+		pub.sealed[i] = priv.aead.Seal(nil, append(nonce, ctrs[i]...),
+			padded[i], priv.ad(string(inputs[i])))
 	}
+	pub.ctr = itemCt
 
-	return pub, priv, nil
+	if priv.indexed {
+		if pub.index, err = priv.buildIndex(inputs); err != nil {
+			pub.Close()
+			priv.dict.Close()
+			return nil, err
+		}
+	}
+
+	// Authenticate the parameters.
+	priv.created = time.Now().Unix()
+	priv.mac = paramsMac(priv.keys.commit, priv.GetParams())
+	pub.version, pub.created, pub.mac = priv.version, priv.created, priv.mac
+
+	return pub, nil
 }
 
 // GetIdx computes the index corresponding to the input.
@@ -134,6 +269,13 @@ func (priv *PrivStore) GetIdx(input string) (int, int, error) {
 // sealed output corresponding to the share. This function returns ItemNotFound
 // if there is no such sealed output.
 func (pub *PubStore) GetShare(x, y int) ([]byte, error) {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	return pub.getShare(x, y)
+}
+
+// getShare is the same as GetShare, except that the caller must hold pub.mu.
+func (pub *PubStore) getShare(x, y int) ([]byte, error) {
 	// Get counter share.
 	ctrShare, err := pub.dict.GetShare(x, y)
 	if err != nil {
@@ -155,20 +297,46 @@ func (pub *PubStore) GetShare(x, y int) ([]byte, error) {
 //
 // The nonce is the constructed from combining the table public share with the
 // private share and concatenating the result to the salt. The associated data
-// is the input. Returns ItemNotFound if unsealing the output fails.
+// is the input (and the padding parameters, if the outputs are padded). Returns
+// ItemNotFound if unsealing or unpadding the output fails.
+//
+// If the commitment of the store is set, then pubShare must be computed by
+// pub.GetVerifiableShare(), and GetOutput returns ErrorBadProof if it doesn't
+// match the commitment. (See priv.SetCommitment().)
 func (priv *PrivStore) GetOutput(input string, pubShare []byte) (string, error) {
-	ctrShareBytes := priv.dict.params.row_bytes
+	if priv.commitment != nil {
+		return priv.verifyOutput(input, pubShare)
+	}
+	return priv.getOutput(input, pubShare)
+}
+
+// getOutput is the same as GetOutput, except that pubShare is not verified.
+func (priv *PrivStore) getOutput(input string, pubShare []byte) (string, error) {
+	if priv.dict.closed() {
+		return "", ErrorClosed
+	}
+	ctrShareBytes := priv.dict.rowBytes()
+	if len(pubShare) < ctrShareBytes {
+		return "", ItemNotFound
+	}
 	ctr, err := priv.dict.GetOutput(input, pubShare[:ctrShareBytes])
 	if err != nil {
 		return "", err
 	}
+	if isTombstone([]byte(ctr)) {
+		return "", ItemNotFound
+	}
 
-	nonce := cBytesToBytes(priv.dict.params.salt, priv.dict.params.salt_bytes)
+	nonce := priv.dict.salt()
 	output, err := priv.aead.Open(
-		nil, append(nonce, []byte(ctr)...), pubShare[ctrShareBytes:], []byte(input))
+		nil, append(nonce, []byte(ctr)...), pubShare[ctrShareBytes:], priv.ad(input))
 	if err != nil {
 		return "", ItemNotFound
 	}
+	output, err = priv.unpad(output)
+	if err != nil {
+		return "", err
+	}
 	return string(output), nil
 }
 
@@ -179,7 +347,12 @@ func (priv *PrivStore) Get(pub *PubStore, input string) (string, error) {
 		return "", err
 	}
 
-	pubShare, err := pub.GetShare(x, y)
+	var pubShare []byte
+	if priv.commitment != nil {
+		pubShare, err = pub.GetVerifiableShare(x, y)
+	} else {
+		pubShare, err = pub.GetShare(x, y)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -187,35 +360,161 @@ func (priv *PrivStore) Get(pub *PubStore, input string) (string, error) {
 	return priv.GetOutput(input, pubShare)
 }
 
-// Free releases memory allocated to the public store's internal representation.
+// Close releases memory allocated to the public store's internal
+// representation. It is safe to call Close() more than once. After pub is
+// closed, its methods return ErrorClosed.
+func (pub *PubStore) Close() error {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	return pub.dict.Close()
+}
+
+// Free is equivalent to pub.Close().
+//
+// Deprecated: Use pub.Close() instead.
 func (pub *PubStore) Free() {
-	pub.dict.Free()
+	pub.Close()
 }
 
-// Free releases memory allocated to the private context's internal
-// representation.
+// Close releases memory allocated to the private context's internal
+// representation. It is safe to call Close() more than once, but not
+// concurrently with the other methods of priv. After priv is closed, its
+// methods return ErrorClosed.
+func (priv *PrivStore) Close() error {
+	return priv.dict.Close()
+}
+
+// Free is equivalent to priv.Close().
+//
+// Deprecated: Use priv.Close() instead.
 func (priv *PrivStore) Free() {
-	priv.dict.Free()
+	priv.Close()
 }
 
 // NewPubStoreFromProto creates a public store from its protobuf representation.
 //
-// You must call pub.Free() before pub goes out of scope.
+// You should call pub.Close() when you are done with pub.
 func NewPubStoreFromProto(table *pb.Store) (pub *PubStore) {
 	pub = new(PubStore)
+	pub.setProto(table)
+	return pub
+}
+
+// setProto sets pub to the store represented by table. The caller must hold
+// pub.mu (or have the only reference to pub).
+func (pub *PubStore) setProto(table *pb.Store) {
 	pub.dict = NewPubDictFromProto(table.GetDict())
 	pub.sealed = table.GetSealed()
 	pub.g = make(graph, table.GetNodeCt())
 	for i := 0; i < len(table.Node); i++ {
 		pub.g[table.Node[i]] = table.AdjList[i].Edge
 	}
-	return pub
+	// Stores generated before updates were supported don't set the counter.
+	pub.ctr = int(table.GetCtr())
+	if pub.ctr < len(pub.sealed) {
+		pub.ctr = len(pub.sealed)
+	}
+	params := table.GetDict().GetParams()
+	pub.padding = params.GetOutputPadding()
+	pub.paddedBytes = int(params.GetPaddedOutputBytes())
+	pub.version = params.GetVersion()
+	pub.created = params.GetCreated()
+	pub.mac = params.GetMac()
+	pub.schedule = params.GetKeySchedule()
+	pub.kdfHeader = table.GetKdfHeader()
+	pub.index = table.GetIndex()
+}
+
+// ValidateStoreProto checks that the protobuf representation of a public store
+// is well-formed, so that it is safe to pass it to NewPubStoreFromProto(). It
+// returns ErrorBadStore if not. This is meant for servers that accept stores
+// from untrusted clients; it does not check that the store was computed
+// correctly.
+func ValidateStoreProto(table *pb.Store) error {
+	params := table.GetDict().GetParams()
+	if params == nil {
+		return ErrorBadStore
+	}
+	rowBytes := int(params.GetRowBytes())
+	tableLen := int(params.GetTableLen())
+	if len(params.GetSalt()) != SaltBytes ||
+		rowBytes < 1 || rowBytes > MaxRowBytes ||
+		params.GetTagBytes() < 0 || int(params.GetTagBytes()) > rowBytes {
+		return ErrorBadStore
+	}
+	priv := new(PrivStore)
+	priv.setPadding(params)
+	if priv.checkPadding() != nil {
+		return ErrorBadStore
+	}
+	if _, ok := pb.KeySchedule_name[int32(params.GetKeySchedule())]; !ok {
+		return ErrorBadStore
+	}
+	if table.GetKdfHeader() != nil && checkKdfHeader(table.GetKdfHeader()) != nil {
+		return ErrorBadStore
+	}
+
+	// The table has fewer than 3 nodes per edge. (See NewDict().)
+	sealedCt := len(table.GetSealed())
+	if tableLen < 2 || tableLen > 3*sealedCt || int(table.GetNodeCt()) != tableLen {
+		return ErrorBadStore
+	}
+
+	// The index, if any, has a page for each IndexPageSize edges.
+	if n := len(table.GetIndex()); n > 0 && n != indexPages(sealedCt) {
+		return ErrorBadStore
+	}
+
+	// Check the compressed table.
+	idx := table.GetDict().GetIdx()
+	if len(table.GetDict().GetTable()) != len(idx)*rowBytes || len(idx) > tableLen {
+		return ErrorBadStore
+	}
+	for _, x := range idx {
+		if x < 0 || int(x) >= tableLen {
+			return ErrorBadStore
+		}
+	}
+
+	// Each edge is adjacent to exactly two nodes.
+	if len(table.GetNode()) != len(table.GetAdjList()) {
+		return ErrorBadStore
+	}
+	degree := make([]int, sealedCt)
+	seen := make([]bool, tableLen)
+	for i, x := range table.GetNode() {
+		if x < 0 || int(x) >= tableLen || seen[x] {
+			return ErrorBadStore
+		}
+		seen[x] = true
+		for _, e := range table.GetAdjList()[i].GetEdge() {
+			if e < 0 || int(e) >= sealedCt {
+				return ErrorBadStore
+			}
+			degree[e]++
+		}
+	}
+	for e := range degree {
+		if degree[e] != 2 {
+			return ErrorBadStore
+		}
+	}
+	return nil
 }
 
 // GetProto creates a protobuf representation of the public store.
 //
-// This is a compact representation suitable for transmission.
+// This is a compact representation suitable for transmission. It returns nil if
+// pub is closed.
 func (pub *PubStore) GetProto() *pb.Store {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() {
+		return nil
+	}
+	dict := pub.dict.GetProto()
+	pub.setParams(dict.Params)
+
 	adjList := make([]*pb.Store_AdjList, 0)
 	node := make([]int32, 0)
 	for i := 0; i < len(pub.g); i++ {
@@ -226,16 +525,21 @@ func (pub *PubStore) GetProto() *pb.Store {
 		}
 	}
 	return &pb.Store{
-		Dict:    pub.dict.GetProto(),
-		Sealed:  pub.sealed,
-		Node:    node,
-		AdjList: adjList,
-		NodeCt:  int32(len(pub.g)),
+		Dict:      dict,
+		Sealed:    pub.sealed,
+		Node:      node,
+		AdjList:   adjList,
+		NodeCt:    int32(len(pub.g)),
+		Ctr:       int32(pub.ctr),
+		KdfHeader: pub.kdfHeader,
+		Index:     pub.index,
 	}
 }
 
 // String returns a string representing the public storage.
 func (pub *PubStore) String() string {
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
 	str := pub.dict.String()
 	for i := 0; i < len(pub.sealed); i++ {
 		str += fmt.Sprintf("%d: %s\n", i, hex.EncodeToString(pub.sealed[i]))
@@ -243,29 +547,64 @@ func (pub *PubStore) String() string {
 	return str
 }
 
+// setParams sets the fields of params that are held by pub rather than by
+// pub.dict.
+func (pub *PubStore) setParams(params *pb.Params) {
+	params.OutputPadding = pub.padding
+	params.PaddedOutputBytes = int32(pub.paddedBytes)
+	params.Version = pub.version
+	params.Created = pub.created
+	params.Mac = pub.mac
+	params.KeySchedule = pub.schedule
+}
+
 // NewPrivStore creates a new private store context from a key and parameters.
 //
-// You must call priv.Free() before priv goes out of scope.
-func NewPrivStore(K []byte, params *pb.Params) (priv *PrivStore, err error) {
-	priv = new(PrivStore)
-
-	block, err := aes.NewCipher(K[:DictKeyBytes])
+// The parameters are authenticated: NewPrivStore returns ErrorBadParams if they
+// were not created with K, and ErrorStaleStore if the version of the store is
+// less than minVersion. (See WithVersion().) This way a server can't pass off
+// an older store as the current one. The MAC may be missing only if the key
+// schedule is LEGACY_SPLIT, i.e., the store was created before versions were
+// supported; such stores are accepted only if minVersion <= 0.
+//
+// The keys are derived from K using the key schedule recorded in params, so
+// stores created before key schedules were supported still open.
+//
+// You should call priv.Close() when you are done with priv.
+func NewPrivStore(K []byte, params *pb.Params, minVersion int64) (priv *PrivStore, err error) {
+	priv = &PrivStore{schedule: params.GetKeySchedule()}
+	keys, err := priv.setKeys(K)
 	if err != nil {
 		return nil, err
 	}
-	priv.aead, err = cipher.NewGCM(block)
-	if err != nil {
+	if err = checkVersion(keys.commit, params, minVersion); err != nil {
 		return nil, err
 	}
+	priv.setVersion(params)
 
-	priv.dict, err = NewPrivDict(K[DictKeyBytes:], params)
+	priv.setPadding(params)
+	if err = priv.checkPadding(); err != nil {
+		return nil, err
+	}
+
+	priv.dict, err = NewPrivDict(keys.prf, params)
 	if err != nil {
 		return nil, err
 	}
 	return priv, nil
 }
 
-// GetParams returns the public parameters of the store.
+// GetParams returns the public parameters of the store. It returns nil if priv
+// is closed.
 func (priv *PrivStore) GetParams() *pb.Params {
-	return priv.dict.GetParams()
+	params := priv.dict.GetParams()
+	if params != nil {
+		params.OutputPadding = priv.padding
+		params.PaddedOutputBytes = int32(priv.paddedBytes)
+		params.Version = priv.version
+		params.Created = priv.created
+		params.Mac = priv.mac
+		params.KeySchedule = priv.schedule
+	}
+	return params
 }
@@ -4,9 +4,12 @@
 package store
 
 import (
+	"sync"
 	"testing"
 
 	"encoding/binary"
+
+	"github.com/cjpatton/store/pb"
 )
 
 func TestNewStore(t *testing.T) {
@@ -88,7 +91,7 @@ func TestNewPrivStore(t *testing.T) {
 	defer pub1.Free()
 	defer priv1.Free()
 
-	priv2, err := NewPrivStore(K, priv1.GetParams())
+	priv2, err := NewPrivStore(K, priv1.GetParams(), 0)
 	if err != nil {
 		t.Fatalf("NewPrivStore() fails: %s", err)
 	}
@@ -160,3 +163,155 @@ func TestBadGetShare(t *testing.T) {
 	}
 	t.Logf("%d / %d", ct, trials)
 }
+
+// Test that closed stores return ErrorClosed.
+func TestStoreClose(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	update, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = pub.Close(); err != nil {
+			t.Errorf("pub.Close() fails: %s", err)
+		}
+	}
+	if _, err = priv.Get(pub, "hip"); err != ErrorClosed {
+		t.Errorf("priv.Get(pub, \"hip\") returns %v, expected %q", err, ErrorClosed)
+	}
+	if err = pub.ApplyUpdate(update); err != ErrorClosed {
+		t.Errorf("pub.ApplyUpdate() returns %v, expected %q", err, ErrorClosed)
+	}
+	if pub.GetProto() != nil {
+		t.Error("pub.GetProto() != nil, expected nil")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = priv.Close(); err != nil {
+			t.Errorf("priv.Close() fails: %s", err)
+		}
+	}
+	if _, err = priv.GetOutput("hip", make([]byte, MaxRowBytes)); err != ErrorClosed {
+		t.Errorf("priv.GetOutput() returns %v, expected %q", err, ErrorClosed)
+	}
+}
+
+// Test that queries may be evaluated concurrently with each other and with
+// updates. Run with "go test -race".
+func TestStoreConcurrent(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	// The writer rewrites the output of "hip", and each update re-randomizes
+	// the trees of other inputs, too. The readers must get either the old or
+	// the new output of "hip" and exactly the outputs of the other inputs.
+	const newOutput = "hooray"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for in, val := range goodM {
+					out, err := priv.Get(pub, in)
+					if err != nil {
+						t.Errorf("priv.Get(pub, %q) fails: %s", in, err)
+					} else if in == "hip" && out != val && out != newOutput {
+						t.Errorf("priv.Get(pub, %q) = %q, expected %q or %q", in, out, val, newOutput)
+					} else if in != "hip" && out != val {
+						t.Errorf("priv.Get(pub, %q) = %q, expected %q", in, out, val)
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cur := priv
+		output := goodM["hip"]
+		for j := 0; j < 20; j++ {
+			if output == newOutput {
+				output = goodM["hip"]
+			} else {
+				output = newOutput
+			}
+			update, err := cur.Update(pub, "hip", output)
+			if err != nil {
+				t.Errorf("priv.Update() fails: %s", err)
+				return
+			}
+			next, err := cur.ApplyUpdate(update)
+			if err != nil {
+				t.Errorf("priv.ApplyUpdate() fails: %s", err)
+				return
+			}
+			if err = pub.ApplyUpdate(update); err != nil {
+				t.Errorf("pub.ApplyUpdate() fails: %s", err)
+				return
+			}
+			if cur != priv {
+				cur.Close()
+			}
+			cur = next
+		}
+		checkStore(t, pub, cur, goodM)
+		cur.Close()
+	}()
+	wg.Wait()
+}
+
+func TestValidateStoreProto(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	// Stores computed by NewStore() and updated by pub.ApplyUpdate() are valid.
+	if err = ValidateStoreProto(pub.GetProto()); err != nil {
+		t.Errorf("ValidateStoreProto() fails: %s", err)
+	}
+	update, err := priv.Delete(pub, "hip")
+	if err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	if err = pub.ApplyUpdate(update); err != nil {
+		t.Fatalf("pub.ApplyUpdate() fails: %s", err)
+	}
+	if err = ValidateStoreProto(pub.GetProto()); err != nil {
+		t.Errorf("ValidateStoreProto() fails after update: %s", err)
+	}
+
+	for name, mutate := range map[string]func(table *pb.Store){
+		"no dict":       func(table *pb.Store) { table.Dict = nil },
+		"bad salt":      func(table *pb.Store) { table.Dict.Params.Salt = nil },
+		"bad row bytes": func(table *pb.Store) { table.Dict.Params.RowBytes = MaxRowBytes + 1 },
+		"bad padding":   func(table *pb.Store) { table.Dict.Params.OutputPadding = pb.OutputPadding_FIXED },
+		"big table":     func(table *pb.Store) { table.Dict.Params.TableLen = 1 << 30 },
+		"bad node ct":   func(table *pb.Store) { table.NodeCt++ },
+		"short table":   func(table *pb.Store) { table.Dict.Table = table.Dict.Table[1:] },
+		"bad idx":       func(table *pb.Store) { table.Dict.Idx[0] = table.NodeCt },
+		"bad node":      func(table *pb.Store) { table.Node[0] = -1 },
+		"dup node":      func(table *pb.Store) { table.Node[1] = table.Node[0] },
+		"bad edge":      func(table *pb.Store) { table.AdjList[0].Edge[0] = int32(len(table.Sealed)) },
+		"no adj list":   func(table *pb.Store) { table.AdjList = table.AdjList[1:] },
+		"missing edge":  func(table *pb.Store) { table.Sealed = append(table.Sealed, nil) },
+	} {
+		table := pub.GetProto()
+		mutate(table)
+		if err = ValidateStoreProto(table); err != ErrorBadStore {
+			t.Errorf("ValidateStoreProto() returns %v for %s, expected %q", err, name, ErrorBadStore)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import "github.com/cjpatton/store/pb"
+
+// Returned (wrapped) by the methods of TypedStore if an output, or an input
+// listed by the index, can't be decoded. Use errors.Is(err, ErrorDecode) to
+// check for it; the error also wraps the error returned by the codec. It is
+// distinct from ItemNotFound: the output was found and authenticated, but it
+// is not a valid encoding of a value.
+const ErrorDecode = Error("cannot decode value")
+
+// Returned (wrapped) by NewTypedStore(), NewTypedStoreSlices(), and the methods
+// of TypedStore if an input or output can't be encoded.
+const ErrorEncode = Error("cannot encode value")
+
+// codecError is an error returned by a codec. It matches ErrorDecode or
+// ErrorEncode and wraps the error of the codec.
+type codecError struct {
+	kind Error
+	err  error
+}
+
+func (err *codecError) Error() string { return string(err.kind) + ": " + err.err.Error() }
+
+func (err *codecError) Is(target error) bool { return target == err.kind }
+
+func (err *codecError) Unwrap() error { return err.err }
+
+// TypedStore is a store that maps inputs of type K to outputs of type V. The
+// inputs and outputs are encoded by codecs and stored in a PubStore, which may
+// be served and updated as usual (see Pub()).
+type TypedStore[K, V any] struct {
+	pub      *PubStore
+	priv     *PrivStore
+	inCodec  Codec[K]
+	outCodec Codec[V]
+}
+
+// NewTypedStore creates a store for the store key and map M, encoding the
+// inputs with inCodec and the outputs with outCodec. The options are passed to
+// NewStore().
+//
+// You should call ts.Close() when you are done with ts.
+func NewTypedStore[K comparable, V any](key []byte, M map[K]V, inCodec Codec[K], outCodec Codec[V], opts ...Option) (*TypedStore[K, V], error) {
+	inputs := make([]K, 0, len(M))
+	outputs := make([]V, 0, len(M))
+	for in, out := range M {
+		inputs = append(inputs, in)
+		outputs = append(outputs, out)
+	}
+	return NewTypedStoreSlices(key, inputs, outputs, inCodec, outCodec, opts...)
+}
+
+// NewTypedStoreSlices is like NewTypedStore(), except that the map is given by
+// a slice of inputs and a slice of outputs. (See NewStoreBytes().) The inputs
+// need not be comparable, but their encodings must be distinct.
+func NewTypedStoreSlices[K, V any](key []byte, inputs []K, outputs []V, inCodec Codec[K], outCodec Codec[V], opts ...Option) (*TypedStore[K, V], error) {
+	if len(inputs) != len(outputs) {
+		return nil, ErrorInputOutputMismatch
+	}
+	encodedInputs := make([][]byte, len(inputs))
+	encodedOutputs := make([][]byte, len(outputs))
+	for i := range inputs {
+		var err error
+		if encodedInputs[i], err = inCodec.Encode(inputs[i]); err != nil {
+			return nil, &codecError{ErrorEncode, err}
+		}
+		if encodedOutputs[i], err = outCodec.Encode(outputs[i]); err != nil {
+			return nil, &codecError{ErrorEncode, err}
+		}
+	}
+	pub, priv, err := NewStoreBytes(key, encodedInputs, encodedOutputs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return WrapStore(pub, priv, inCodec, outCodec), nil
+}
+
+// WrapStore returns a TypedStore for pub and priv, e.g., for a store received
+// from the server and opened with NewPrivStore(). The codecs must be the ones
+// the store was created with. ts.Close() closes pub and priv.
+func WrapStore[K, V any](pub *PubStore, priv *PrivStore, inCodec Codec[K], outCodec Codec[V]) *TypedStore[K, V] {
+	return &TypedStore[K, V]{pub: pub, priv: priv, inCodec: inCodec, outCodec: outCodec}
+}
+
+// Get looks up the input and returns the decoded output. It returns
+// ItemNotFound if the input is not in the map and an error matching
+// ErrorDecode if the output can't be decoded.
+func (ts *TypedStore[K, V]) Get(input K) (V, error) {
+	var value V
+	in, err := ts.inCodec.Encode(input)
+	if err != nil {
+		return value, &codecError{ErrorEncode, err}
+	}
+	out, err := ts.priv.GetBytes(ts.pub, in)
+	if err != nil {
+		return value, err
+	}
+	return ts.decode(out)
+}
+
+// GetOutput computes the decoded output from the input and the public share
+// computed by the server. (See priv.GetOutput().)
+func (ts *TypedStore[K, V]) GetOutput(input K, pubShare []byte) (V, error) {
+	var value V
+	in, err := ts.inCodec.Encode(input)
+	if err != nil {
+		return value, &codecError{ErrorEncode, err}
+	}
+	out, err := ts.priv.GetOutputBytes(in, pubShare)
+	if err != nil {
+		return value, err
+	}
+	return ts.decode(out)
+}
+
+// Insert computes an update that adds (input, output) to the map. (See
+// priv.Insert().)
+func (ts *TypedStore[K, V]) Insert(input K, output V) (*pb.StoreUpdate, error) {
+	in, out, err := ts.encode(input, output)
+	if err != nil {
+		return nil, err
+	}
+	return ts.priv.Insert(ts.pub, string(in), string(out))
+}
+
+// Update computes an update that changes the output associated with input.
+// (See priv.Update().)
+func (ts *TypedStore[K, V]) Update(input K, output V) (*pb.StoreUpdate, error) {
+	in, out, err := ts.encode(input, output)
+	if err != nil {
+		return nil, err
+	}
+	return ts.priv.Update(ts.pub, string(in), string(out))
+}
+
+// Delete computes an update that removes input from the map. (See
+// priv.Delete().)
+func (ts *TypedStore[K, V]) Delete(input K) (*pb.StoreUpdate, error) {
+	in, err := ts.inCodec.Encode(input)
+	if err != nil {
+		return nil, &codecError{ErrorEncode, err}
+	}
+	return ts.priv.Delete(ts.pub, string(in))
+}
+
+// ApplyUpdate applies an update computed by ts.Insert(), ts.Update(), or
+// ts.Delete() to the public store and the private context. (See
+// pub.ApplyUpdate() and priv.ApplyUpdate().) It must not be called
+// concurrently with the other methods of ts.
+func (ts *TypedStore[K, V]) ApplyUpdate(update *pb.StoreUpdate) error {
+	priv, err := ts.priv.ApplyUpdate(update)
+	if err != nil {
+		return err
+	}
+	if err = ts.pub.ApplyUpdate(update); err != nil {
+		priv.Close()
+		return err
+	}
+	ts.priv.Close()
+	ts.priv = priv
+	return nil
+}
+
+// Keys returns the decoded inputs of the store. The store must have an index.
+// (See priv.Keys().)
+func (ts *TypedStore[K, V]) Keys() ([]K, error) {
+	var inputs []K
+	err := ts.priv.List(ts.pub, func(in string) error {
+		input, err := ts.inCodec.Decode([]byte(in))
+		if err != nil {
+			return &codecError{ErrorDecode, err}
+		}
+		inputs = append(inputs, input)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+// Pub returns the public store.
+func (ts *TypedStore[K, V]) Pub() *PubStore {
+	return ts.pub
+}
+
+// Priv returns the private context of the store.
+func (ts *TypedStore[K, V]) Priv() *PrivStore {
+	return ts.priv
+}
+
+// Close closes the public store and the private context.
+func (ts *TypedStore[K, V]) Close() error {
+	ts.pub.Close()
+	return ts.priv.Close()
+}
+
+// encode encodes the input and output.
+func (ts *TypedStore[K, V]) encode(input K, output V) ([]byte, []byte, error) {
+	in, err := ts.inCodec.Encode(input)
+	if err != nil {
+		return nil, nil, &codecError{ErrorEncode, err}
+	}
+	out, err := ts.outCodec.Encode(output)
+	if err != nil {
+		return nil, nil, &codecError{ErrorEncode, err}
+	}
+	return in, out, nil
+}
+
+// decode decodes the output.
+func (ts *TypedStore[K, V]) decode(out []byte) (V, error) {
+	value, err := ts.outCodec.Decode(out)
+	if err != nil {
+		var zero V
+		return zero, &codecError{ErrorDecode, err}
+	}
+	return value, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTypedStore(t *testing.T) {
+	M := map[string]testRecord{
+		"rex":   {"Rex", 1, nil},
+		"fido":  {"Fido", 2, []string{"fetch"}},
+		"spot":  {"Spot", 3, []string{"sit", "stay"}},
+		"buddy": {"Buddy", 4, []string{}},
+	}
+	ts, err := NewTypedStore[string, testRecord](GenerateKey(), M, StringCodec{}, JSONCodec[testRecord]{}, WithIndex())
+	if err != nil {
+		t.Fatalf("NewTypedStore() fails: %s", err)
+	}
+	defer ts.Close()
+	for in, out := range M {
+		if got, err := ts.Get(in); err != nil || !reflect.DeepEqual(got, out) {
+			t.Errorf("ts.Get(%q) = (%v, %v), expected (%v, nil)", in, got, err, out)
+		}
+	}
+	if _, err = ts.Get("lassie"); err != ItemNotFound {
+		t.Errorf("ts.Get(\"lassie\") returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// Typed updates.
+	update, err := ts.Update("rex", testRecord{"Rex", 5, []string{"roll over"}})
+	if err != nil {
+		t.Fatalf("ts.Update() fails: %s", err)
+	}
+	if err = ts.ApplyUpdate(update); err != nil {
+		t.Fatalf("ts.ApplyUpdate() fails: %s", err)
+	}
+	if got, err := ts.Get("rex"); err != nil || got.Count != 5 {
+		t.Errorf("ts.Get(\"rex\") = (%v, %v) after the update", got, err)
+	}
+
+	keys, err := ts.Keys()
+	if err != nil {
+		t.Fatalf("ts.Keys() fails: %s", err)
+	}
+	sort.Strings(keys)
+	if expected := []string{"buddy", "fido", "rex", "spot"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ts.Keys() = %v, expected %v", keys, expected)
+	}
+}
+
+func TestTypedStoreDecodeError(t *testing.T) {
+	// A store whose outputs are not JSON.
+	K := GenerateKey()
+	raw, err := NewTypedStore[string, string](K, map[string]string{"rex": "not json"}, StringCodec{}, StringCodec{})
+	if err != nil {
+		t.Fatalf("NewTypedStore() fails: %s", err)
+	}
+	defer raw.Close()
+	ts := WrapStore[string, testRecord](raw.Pub(), raw.Priv(), StringCodec{}, JSONCodec[testRecord]{})
+
+	// The output is found, but it can't be decoded.
+	_, err = ts.Get("rex")
+	if !errors.Is(err, ErrorDecode) {
+		t.Errorf("ts.Get() returns %v, expected %q", err, ErrorDecode)
+	}
+	if errors.Is(err, ItemNotFound) {
+		t.Error("ts.Get() returns an error matching ItemNotFound for a decode failure")
+	}
+	if errors.Unwrap(err) == nil {
+		t.Error("the decode error doesn't wrap the error of the codec")
+	}
+	if _, err = ts.Get("fido"); err != ItemNotFound {
+		t.Errorf("ts.Get(\"fido\") returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// Values that can't be encoded.
+	_, err = NewTypedStore[string, chan int](K, map[string]chan int{"c": nil}, StringCodec{}, JSONCodec[chan int]{})
+	if !errors.Is(err, ErrorEncode) {
+		t.Errorf("NewTypedStore() returns %v, expected %q", err, ErrorEncode)
+	}
+}
+
+func TestTypedStoreSlices(t *testing.T) {
+	inputs := [][]byte{{0}, {0, 0}, {1}}
+	outputs := []testRecord{{"a", 1, nil}, {"b", 2, nil}, {"c", 3, nil}}
+	ts, err := NewTypedStoreSlices[[]byte, testRecord](GenerateKey(), inputs, outputs, BytesCodec{}, GobCodec[testRecord]{})
+	if err != nil {
+		t.Fatalf("NewTypedStoreSlices() fails: %s", err)
+	}
+	defer ts.Close()
+	for i, in := range inputs {
+		if got, err := ts.Get(in); err != nil || !reflect.DeepEqual(got, outputs[i]) {
+			t.Errorf("ts.Get(%x) = (%v, %v), expected (%v, nil)", in, got, err, outputs[i])
+		}
+	}
+	if _, err = NewTypedStoreSlices[[]byte, testRecord](GenerateKey(), inputs, outputs[1:], BytesCodec{}, GobCodec[testRecord]{}); err != ErrorInputOutputMismatch {
+		t.Errorf("NewTypedStoreSlices() returns %v, expected %q", err, ErrorInputOutputMismatch)
+	}
+}
@@ -0,0 +1,552 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// Returned by priv.Insert() if the edge corresponding to the input would create
+// a cycle in the graph. When this happens, the store must be rebuilt with
+// NewStore().
+const ErrorUpdateCycle = Error("update creates a cycle in the graph")
+
+// Returned by priv.Insert() if the input is already in the map.
+const ErrorItemExists = Error("item already exists")
+
+// Returned by pub.ApplyUpdate() if the update was not computed from the current
+// state of the store.
+const ErrorStaleUpdate = Error("update does not match the store")
+
+// The high bit of the counter marks an input/output pair as deleted.
+const tombstoneBit = 0x80
+
+// maxCtr returns the number of distinct counters that fit in ctrBytes bytes.
+// The high bit of the counter is reserved for the tombstone.
+func maxCtr(ctrBytes int) int {
+	return 1 << (8*uint(ctrBytes) - 1)
+}
+
+// isTombstone returns true if the counter marks a deleted input/output pair.
+func isTombstone(ctr []byte) bool {
+	return ctr[len(ctr)-1]&tombstoneBit != 0
+}
+
+// Insert computes an update that adds (input, output) to the map.
+//
+// The update is computed from pub, which must be the current state of the
+// store. Apply it to pub (and to any copy of pub held by the server) with
+// pub.ApplyUpdate(), and to priv with priv.ApplyUpdate(). Returns
+// ErrorItemExists if the input is already in the map and ErrorUpdateCycle if
+// the store must be rebuilt.
+//
+// The update carries only the parts of the store that change. The output is
+// sealed under a fresh counter, which is encoded in the table by re-randomizing
+// the trees of the graph that contain the input's rows: each tree is masked
+// with a fresh, random string, so every row the update carries is new, and the
+// server can't tell which of them encode the counter. If the store has an
+// index, then the update also seals other outputs, chosen at random, again, so
+// the server can't tell which of them changed (see WithIndex()); otherwise, it
+// learns which sealed output was written. An insertion also reveals the rows of
+// the new input, just as looking it up would. Pass WithPadding() or
+// WithBucketPadding() so that the length of the sealed output doesn't reveal
+// anything about the new output.
+//
+// The version of the store after the update is one more than priv's, so that
+// clients that require it reject the store from before the update. (See
+// NewPrivStore().)
+func (priv *PrivStore) Insert(pub *PubStore, input, output string) (*pb.StoreUpdate, error) {
+	return priv.newUpdate(pub, input, []byte(output), true)
+}
+
+// Update computes an update that changes the output associated with input. It
+// returns ItemNotFound if the input is not in the map. (See priv.Insert().)
+func (priv *PrivStore) Update(pub *PubStore, input, output string) (*pb.StoreUpdate, error) {
+	return priv.newUpdate(pub, input, []byte(output), false)
+}
+
+// Delete computes an update that removes input from the map. It returns
+// ItemNotFound if the input is not in the map. (See priv.Insert().)
+//
+// The edge corresponding to the input is left in the graph, and its sealed
+// output is replaced by a tombstone of the same length. Hence, deletions and
+// updates look the same to the server.
+func (priv *PrivStore) Delete(pub *PubStore, input string) (*pb.StoreUpdate, error) {
+	return priv.newUpdate(pub, input, nil, false)
+}
+
+// An updateEntry is an edge whose sealed output is written by an update.
+type updateEntry struct {
+	e      int
+	x, y   int
+	input  string
+	output []byte // The padded output, or the zeros sealed by a tombstone.
+	live   bool   // Whether the input is in the map after the update.
+}
+
+// newUpdate computes an update to pub for the given input. If output == nil,
+// then the input is deleted.
+func (priv *PrivStore) newUpdate(pub *PubStore, input string, output []byte, insert bool) (*pb.StoreUpdate, error) {
+	if output != nil {
+		var err error
+		if output, err = priv.pad(output); err != nil {
+			return nil, err
+		}
+	}
+	pub.mu.RLock()
+	defer pub.mu.RUnlock()
+	if pub.dict.closed() || priv.dict.closed() {
+		return nil, ErrorClosed
+	}
+	x, y, err := priv.GetIdx(input)
+	if err != nil {
+		return nil, err
+	}
+	rowBytes := priv.dict.rowBytes()
+
+	// Look up the edge corresponding to the input.
+	e := pub.g.edge(x, y)
+	if e >= 0 {
+		pubShare, err := pub.getShare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		ctr, err := priv.dict.GetOutput(input, pubShare[:rowBytes])
+		if err != nil {
+			return nil, err
+		}
+		if isTombstone([]byte(ctr)) && priv.isDeleted(input, []byte(ctr), pub.sealed[e]) {
+			// The input was deleted, and so it may be reinserted.
+			if !insert {
+				return nil, ItemNotFound
+			}
+			insert = false
+		} else if _, err := priv.getOutput(input, pubShare); err == nil {
+			if insert {
+				return nil, ErrorItemExists
+			}
+		} else if insert {
+			return nil, ErrorUpdateCycle
+		} else {
+			return nil, ItemNotFound
+		}
+	} else if !insert {
+		return nil, ItemNotFound
+	} else if x == y {
+		return nil, ErrorUpdateCycle
+	} else {
+		e = len(pub.sealed)
+	}
+
+	// If the input is being deleted, then seal a string of zeros whose length
+	// matches the current sealed output.
+	entry := updateEntry{e: e, x: x, y: y, input: input, output: output, live: output != nil}
+	if !entry.live {
+		entry.output = make([]byte, len(pub.sealed[e])-priv.aead.Overhead())
+	}
+	entries := []updateEntry{entry}
+
+	// If the store has an index, then hide the entry among others chosen at
+	// random, whose outputs are sealed again. (See WithIndex().)
+	if len(pub.index) > 0 {
+		cover, err := priv.coverEntries(pub, e, UpdateCover-1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cover...)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].e < entries[j].e })
+	}
+
+	// An inserted edge must join two trees.
+	ends := pub.g.ends(len(pub.sealed))
+	if insert {
+		for _, z := range pub.g.component(int32(x), ends, -1, nil) {
+			if int(z) == y {
+				return nil, ErrorUpdateCycle
+			}
+		}
+	}
+
+	first, err := priv.reserveCtrs(pub.ctr, len(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	// Mask each tree containing an entry with a fresh, random string. This
+	// doesn't change the bitwise-XOR of the rows of any edge.
+	table := pub.dict.getTable()
+	visited := make([]bool, len(pub.g))
+	mask := make([]byte, rowBytes)
+	var idx []int32
+	for _, entry := range entries {
+		for _, z := range []int{entry.x, entry.y} {
+			if visited[z] {
+				continue
+			}
+			if _, err := rand.Read(mask); err != nil {
+				return nil, err
+			}
+			for _, w := range pub.g.component(int32(z), ends, -1, visited) {
+				xorRow(table, int(w), mask, rowBytes)
+				idx = append(idx, w)
+			}
+		}
+	}
+
+	sealedIdx := make([]int32, len(entries))
+	sealed := make([][]byte, len(entries))
+	for i, entry := range entries {
+		// The new counter. If the input is being deleted, then set the
+		// tombstone.
+		ctr := make([]byte, rowBytes)
+		binary.LittleEndian.PutUint32(ctr, uint32(first+i))
+		if !entry.live {
+			ctr[rowBytes-1] |= tombstoneBit
+		}
+
+		// Add the delta between the current and new counter to the rows of
+		// the tree containing y, but not x.
+		cur, err := priv.dict.GetOutput(entry.input, xorRows(table, entry.x, entry.y, rowBytes))
+		if err != nil {
+			return nil, err
+		}
+		delta := []byte(cur)
+		for j := 0; j < rowBytes; j++ {
+			delta[j] ^= ctr[j]
+		}
+		for _, z := range pub.g.component(int32(entry.y), ends, int32(entry.e), nil) {
+			xorRow(table, int(z), delta, rowBytes)
+		}
+
+		nonce := priv.dict.salt()
+		sealedIdx[i] = int32(entry.e)
+		sealed[i] = priv.aead.Seal(nil, append(nonce, ctr...), entry.output, priv.ad(entry.input))
+	}
+
+	// Update the entries of the edges in the index.
+	var indexPage []int32
+	var index [][]byte
+	if len(pub.index) > 0 {
+		indexEntries := make(map[int]indexEntry, len(entries))
+		for _, entry := range entries {
+			indexEntries[entry.e] = indexEntry{entry.input, entry.live}
+		}
+		if indexPage, index, err = priv.updateIndex(pub, indexEntries); err != nil {
+			return nil, err
+		}
+	}
+
+	// Send the rows of the masked trees.
+	sort.Slice(idx, func(i, j int) bool { return idx[i] < idx[j] })
+	rows := make([]byte, 0, len(idx)*rowBytes)
+	for _, z := range idx {
+		rows = append(rows, table[int(z)*rowBytes:int(z+1)*rowBytes]...)
+	}
+
+	// Authenticate the parameters of the store after the update.
+	params := priv.GetParams()
+	params.Version = priv.version + 1
+	params.Created = time.Now().Unix()
+	params.Mac = paramsMac(priv.keys.commit, params)
+
+	update := &pb.StoreUpdate{
+		Ctr:       int32(pub.ctr),
+		NextCtr:   int32(first + len(entries)),
+		Dict:      &pb.Dict{Params: params, Table: rows, Idx: idx},
+		Insert:    insert,
+		SealedIdx: sealedIdx,
+		Sealed:    sealed,
+		IndexPage: indexPage,
+		Index:     index,
+	}
+	if insert {
+		update.X, update.Y = int32(x), int32(y)
+	}
+	return update, nil
+}
+
+// reserveCtrs reserves n fresh counters and returns the first. The counters are
+// at least ctr, the next unused counter of the store, and greater than every
+// counter reserved by priv before. This way no nonce is used twice, even if the
+// server rejects an update and the client computes another one from the same
+// state of the store.
+func (priv *PrivStore) reserveCtrs(ctr, n int) (int, error) {
+	priv.ctrMu.Lock()
+	defer priv.ctrMu.Unlock()
+	if ctr < priv.nextCtr {
+		ctr = priv.nextCtr
+	}
+	if ctr+n > maxCtr(priv.dict.rowBytes()) {
+		return 0, ErrorMapTooLarge
+	}
+	priv.nextCtr = ctr + n
+	return ctr, nil
+}
+
+// isDeleted returns true if sealed is the tombstone for input.
+func (priv *PrivStore) isDeleted(input string, ctr, sealed []byte) bool {
+	nonce := priv.dict.salt()
+	_, err := priv.aead.Open(nil, append(nonce, ctr...), sealed, priv.ad(input))
+	return err == nil
+}
+
+// ApplyUpdate applies an update computed by priv.Insert(), priv.Update(), or
+// priv.Delete() to the store. It returns ErrorStaleUpdate if the update was
+// computed from a different state of the store.
+func (pub *PubStore) ApplyUpdate(update *pb.StoreUpdate) error {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if pub.dict.closed() {
+		return ErrorClosed
+	}
+	if err := pub.checkUpdate(update); err != nil {
+		return err
+	}
+
+	// The slices of pub may be shared with its protobuf representation (see
+	// pub.GetProto()), so they are copied rather than modified.
+	edgeCt := len(pub.sealed)
+	sealed := append(make([][]byte, 0, edgeCt+1), pub.sealed...)
+	g := append(graph(nil), pub.g...)
+	if update.GetInsert() {
+		x, y := update.GetX(), update.GetY()
+		g[x] = append(append([]int32(nil), g[x]...), int32(edgeCt))
+		g[y] = append(append([]int32(nil), g[y]...), int32(edgeCt))
+		sealed = append(sealed, nil)
+	}
+	for i, e := range update.GetSealedIdx() {
+		sealed[e] = update.GetSealed()[i]
+	}
+	var index [][]byte
+	if len(pub.index) > 0 {
+		index = append(index, pub.index...)
+		for len(index) < indexPages(len(sealed)) {
+			index = append(index, nil)
+		}
+		for i, page := range update.GetIndexPage() {
+			index[page] = update.GetIndex()[i]
+		}
+	}
+
+	rowBytes := pub.dict.rowBytes()
+	table := pub.dict.getTable()
+	rows := update.GetDict().GetTable()
+	for i, z := range update.GetDict().GetIdx() {
+		copy(table[int(z)*rowBytes:int(z+1)*rowBytes], rows[i*rowBytes:(i+1)*rowBytes])
+	}
+	idx := make([]int32, len(pub.g))
+	for i := range idx {
+		idx[i] = int32(i)
+	}
+	dict := NewPubDictFromProto(&pb.Dict{
+		Params: pub.dict.getParams(),
+		Table:  table,
+		Idx:    idx,
+	})
+
+	pub.dict.Close()
+	pub.dict, pub.sealed, pub.g, pub.index = dict, sealed, g, index
+	params := update.GetDict().GetParams()
+	pub.version, pub.created, pub.mac = params.GetVersion(), params.GetCreated(), params.GetMac()
+	pub.ctr = int(update.GetNextCtr())
+	pub.resetTree()
+	return nil
+}
+
+// checkUpdate checks that the update was computed from the current state of pub
+// and that it is well-formed. The caller must hold pub.mu.
+func (pub *PubStore) checkUpdate(update *pb.StoreUpdate) error {
+	rowBytes := pub.dict.rowBytes()
+	params, cur := update.GetDict().GetParams(), pub.dict.getParams()
+	if int(update.GetCtr()) != pub.ctr ||
+		int(update.GetNextCtr()) <= pub.ctr || int(update.GetNextCtr()) > maxCtr(rowBytes) ||
+		!bytes.Equal(params.GetSalt(), cur.GetSalt()) ||
+		params.GetTableLen() != cur.GetTableLen() ||
+		params.GetRowBytes() != cur.GetRowBytes() {
+		return ErrorStaleUpdate
+	}
+
+	// The rows of the table.
+	if len(update.GetDict().GetTable()) != len(update.GetDict().GetIdx())*rowBytes {
+		return ErrorStaleUpdate
+	}
+	for _, z := range update.GetDict().GetIdx() {
+		if z < 0 || int(z) >= len(pub.g) {
+			return ErrorIdx
+		}
+	}
+
+	// The inserted edge, if any, must be new.
+	edgeCt := len(pub.sealed)
+	if update.GetInsert() {
+		x, y := int(update.GetX()), int(update.GetY())
+		if x < 0 || x >= len(pub.g) || y < 0 || y >= len(pub.g) {
+			return ErrorIdx
+		} else if x == y || pub.g.edge(x, y) >= 0 {
+			return ErrorStaleUpdate
+		}
+		edgeCt++
+	}
+
+	// Each sealed output is written at most once, and the inserted edge is
+	// written.
+	if len(update.GetSealedIdx()) != len(update.GetSealed()) {
+		return ErrorStaleUpdate
+	}
+	written := make(map[int32]bool)
+	for _, e := range update.GetSealedIdx() {
+		if e < 0 || int(e) >= edgeCt || written[e] {
+			return ErrorStaleUpdate
+		}
+		written[e] = true
+	}
+	if update.GetInsert() && !written[int32(edgeCt-1)] {
+		return ErrorStaleUpdate
+	}
+
+	// The update changes the page of the index containing each edge it
+	// writes if and only if the store has an index.
+	if len(update.GetIndexPage()) != len(update.GetIndex()) ||
+		(len(pub.index) == 0 && len(update.GetIndex()) > 0) {
+		return ErrorStaleUpdate
+	}
+	pages := make(map[int32]bool)
+	for _, page := range update.GetIndexPage() {
+		if page < 0 || int(page) >= indexPages(edgeCt) || pages[page] {
+			return ErrorStaleUpdate
+		}
+		pages[page] = true
+	}
+	if len(pub.index) > 0 {
+		for e := range written {
+			if !pages[e/IndexPageSize] {
+				return ErrorStaleUpdate
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyUpdate returns the private context of the store after the update, which
+// was computed by priv.Insert(), priv.Update(), or priv.Delete(), is applied.
+// priv is not modified, and it remains valid for the store before the update.
+// It returns ErrorBadParams if the update was not computed with the store key
+// and ErrorStaleStore if its version is less than priv's.
+//
+// If the commitment of priv is set, then the new context has the same
+// commitment, so the shares of the store after the update don't verify until
+// the client sets its commitment. (See priv.SetCommitment().)
+func (priv *PrivStore) ApplyUpdate(update *pb.StoreUpdate) (*PrivStore, error) {
+	if priv.dict.closed() {
+		return nil, ErrorClosed
+	}
+	params := update.GetDict().GetParams()
+	if params.GetKeySchedule() != priv.schedule || len(params.GetMac()) == 0 {
+		return nil, ErrorBadParams
+	}
+	if err := checkVersion(priv.keys.commit, params, priv.version); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(params.GetSalt(), priv.dict.salt()) {
+		return nil, ErrorStaleUpdate
+	}
+
+	next := &PrivStore{
+		aead:       priv.aead,
+		indexAead:  priv.indexAead,
+		keys:       priv.keys,
+		schedule:   priv.schedule,
+		commitment: priv.commitment,
+	}
+	next.setVersion(params)
+	next.setPadding(params)
+	var err error
+	if next.dict, err = NewPrivDict(priv.keys.prf, params); err != nil {
+		return nil, err
+	}
+	priv.ctrMu.Lock()
+	next.nextCtr = priv.nextCtr
+	priv.ctrMu.Unlock()
+	if next.nextCtr < int(update.GetNextCtr()) {
+		next.nextCtr = int(update.GetNextCtr())
+	}
+	return next, nil
+}
+
+// edge returns the edge adjacent to both x and y, or -1 if there is none.
+func (g graph) edge(x, y int) int {
+	for i := 0; i < len(g[x]); i++ {
+		for j := 0; j < len(g[y]); j++ {
+			if g[x][i] == g[y][j] {
+				return int(g[x][i])
+			}
+		}
+	}
+	return -1
+}
+
+// ends returns the end points of each of the edgeCt edges of the graph.
+func (g graph) ends(edgeCt int) [][2]int32 {
+	ends := make([][2]int32, edgeCt)
+	seen := make([]bool, edgeCt)
+	for x := range g {
+		for _, e := range g[x] {
+			if seen[e] {
+				ends[e][1] = int32(x)
+			} else {
+				ends[e][0] = int32(x)
+				seen[e] = true
+			}
+		}
+	}
+	return ends
+}
+
+// component returns the nodes reachable from x without traversing edge skip.
+// If visited != nil, then it is used to mark the nodes of the component.
+func (g graph) component(x int32, ends [][2]int32, skip int32, visited []bool) []int32 {
+	if visited == nil {
+		visited = make([]bool, len(g))
+	}
+	visited[x] = true
+	nodes := []int32{x}
+	for i := 0; i < len(nodes); i++ {
+		for _, e := range g[nodes[i]] {
+			if e == skip {
+				continue
+			}
+			z := ends[e][0]
+			if z == nodes[i] {
+				z = ends[e][1]
+			}
+			if !visited[z] {
+				visited[z] = true
+				nodes = append(nodes, z)
+			}
+		}
+	}
+	return nodes
+}
+
+// xorRows returns the bitwise-XOR of the x-th and y-th rows of table.
+func xorRows(table []byte, x, y, rowBytes int) []byte {
+	row := make([]byte, rowBytes)
+	for i := 0; i < rowBytes; i++ {
+		row[i] = table[x*rowBytes+i] ^ table[y*rowBytes+i]
+	}
+	return row
+}
+
+// xorRow adds delta to the x-th row of table.
+func xorRow(table []byte, x int, delta []byte, rowBytes int) {
+	for i := 0; i < rowBytes; i++ {
+		table[x*rowBytes+i] ^= delta[i]
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// applyUpdate applies the update to pub and to *priv, which it replaces with
+// the private context after the update.
+func applyUpdate(t *testing.T, pub *PubStore, priv **PrivStore, update *pb.StoreUpdate) {
+	next, err := (*priv).ApplyUpdate(update)
+	if err != nil {
+		t.Fatalf("priv.ApplyUpdate() fails: %s", err)
+	}
+	if err = pub.ApplyUpdate(update); err != nil {
+		next.Close()
+		t.Fatalf("pub.ApplyUpdate() fails: %s", err)
+	}
+	(*priv).Close()
+	*priv = next
+}
+
+// insertAny inserts the first input of the form "new i" that is not in the map
+// and whose insertion doesn't create a cycle. It returns the input.
+func insertAny(t *testing.T, pub *PubStore, priv **PrivStore, output string) string {
+	for i := 0; i < 100; i++ {
+		in := fmt.Sprintf("new %d", i)
+		update, err := (*priv).Insert(pub, in, output)
+		if err == ErrorUpdateCycle || err == ErrorItemExists {
+			continue
+		} else if err != nil {
+			t.Fatalf("priv.Insert(pub, %q, %q) fails: %s", in, output, err)
+		}
+		applyUpdate(t, pub, priv, update)
+		return in
+	}
+	t.Fatal("priv.Insert() creates a cycle for every input")
+	return ""
+}
+
+// checkStore checks that pub represents M.
+func checkStore(t *testing.T, pub *PubStore, priv *PrivStore, M map[string]string) {
+	for in, val := range M {
+		out, err := priv.Get(pub, in)
+		if err != nil {
+			t.Errorf("priv.Get(pub, %q) fails: %s", in, err)
+		} else if out != val {
+			t.Errorf("priv.Get(pub, %q) = %q, expected %q", in, out, val)
+		}
+	}
+}
+
+// copyMap returns a copy of M.
+func copyMap(M map[string]string) map[string]string {
+	C := make(map[string]string, len(M))
+	for in, out := range M {
+		C[in] = out
+	}
+	return C
+}
+
+func TestInsert(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+
+	M := copyMap(goodM)
+	for i := 0; i < 3; i++ {
+		out := fmt.Sprintf("inserted %d", i)
+		in := insertAny(t, pub, &priv, out)
+		M[in] = out
+		checkStore(t, pub, priv, M)
+	}
+	AssertIntEqError(t, "len(pub.sealed)", len(pub.sealed), len(M))
+
+	if _, err := priv.Insert(pub, "hip", "burger"); err != ErrorItemExists {
+		t.Errorf("priv.Insert(pub, \"hip\", \"burger\") returns %v, expected %q", err, ErrorItemExists)
+	}
+}
+
+// Test that an update carries only the rows of the trees it re-randomizes, that
+// each of these rows changes, and that the rest of the table doesn't.
+func TestUpdate(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithVersion(1))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+
+	before := pub.dict.getTable()
+	update, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	if update.GetInsert() || update.GetX() != 0 || update.GetY() != 0 {
+		t.Error("the update reveals the rows of the input")
+	}
+	AssertIntEqError(t, "len(update.Sealed)", len(update.GetSealed()), 1)
+	applyUpdate(t, pub, &priv, update)
+
+	after := pub.dict.getTable()
+	rowBytes := pub.dict.rowBytes()
+	changed := make(map[int]bool)
+	for _, x := range update.GetDict().GetIdx() {
+		changed[int(x)] = true
+	}
+	x, y, _ := priv.GetIdx("hip")
+	if !changed[x] || !changed[y] {
+		t.Error("the update doesn't carry the rows of the input")
+	}
+	for x := 0; x < len(pub.g); x++ {
+		same := bytes.Equal(before[x*rowBytes:(x+1)*rowBytes], after[x*rowBytes:(x+1)*rowBytes])
+		if changed[x] && same {
+			t.Errorf("row %d is sent, but unchanged by the update", x)
+		} else if !changed[x] && !same {
+			t.Errorf("row %d is changed, but not sent by the update", x)
+		}
+	}
+
+	M := copyMap(goodM)
+	M["hip"] = "burger"
+	checkStore(t, pub, priv, M)
+	if priv.version != 2 {
+		t.Errorf("priv.version = %d after the update, expected 2", priv.version)
+	}
+
+	if _, err := priv.Update(pub, "Nooooooo", "burger"); err != ItemNotFound {
+		t.Errorf("priv.Update(pub, \"Nooooooo\", \"burger\") returns %v, expected %q", err, ItemNotFound)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer func() { priv.Close() }()
+
+	x, y, _ := priv.GetIdx("hip")
+	before, _ := pub.GetShare(x, y)
+	update, err := priv.Delete(pub, "hip")
+	if err != nil {
+		t.Fatalf("priv.Delete() fails: %s", err)
+	}
+	applyUpdate(t, pub, &priv, update)
+	after, _ := pub.GetShare(x, y)
+	AssertIntEqError(t, "len(after)", len(after), len(before))
+
+	if _, err := priv.Get(pub, "hip"); err != ItemNotFound {
+		t.Errorf("priv.Get(pub, \"hip\") returns %v, expected %q", err, ItemNotFound)
+	}
+	if _, err := priv.Delete(pub, "hip"); err != ItemNotFound {
+		t.Errorf("priv.Delete(pub, \"hip\") returns %v, expected %q", err, ItemNotFound)
+	}
+
+	// Re-insert the deleted input.
+	if update, err = priv.Insert(pub, "hip", "pizza"); err != nil {
+		t.Fatalf("priv.Insert() fails: %s", err)
+	}
+	if update.GetInsert() {
+		t.Error("update.GetInsert() = true, expected false")
+	}
+	applyUpdate(t, pub, &priv, update)
+	checkStore(t, pub, priv, goodM)
+}
+
+func TestApplyStaleUpdate(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	update1, err := priv.Update(pub, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	update2, err := priv.Update(pub, "this", "that")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+
+	// Updates computed from the same state use distinct counters, and thus
+	// distinct nonces, even if one of them is rejected.
+	if update2.GetNextCtr()-int32(len(update2.GetSealed())) < update1.GetNextCtr() {
+		t.Error("updates computed from the same state reuse a counter")
+	}
+
+	if err = pub.ApplyUpdate(update1); err != nil {
+		t.Fatalf("pub.ApplyUpdate(update1) fails: %s", err)
+	}
+	if err = pub.ApplyUpdate(update2); err != ErrorStaleUpdate {
+		t.Errorf("pub.ApplyUpdate(update2) returns %v, expected %q", err, ErrorStaleUpdate)
+	}
+}
+
+func TestApplyBadUpdate(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM, WithIndex())
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	for _, tc := range []struct {
+		desc   string
+		tamper func(update *pb.StoreUpdate)
+	}{
+		{"no index", func(update *pb.StoreUpdate) {
+			update.IndexPage, update.Index = nil, nil
+		}},
+		{"sealed output out of range", func(update *pb.StoreUpdate) {
+			update.SealedIdx[0] = int32(len(pub.sealed))
+		}},
+		{"missing row", func(update *pb.StoreUpdate) {
+			update.Dict.Idx = update.Dict.Idx[1:]
+		}},
+		{"old counter", func(update *pb.StoreUpdate) {
+			update.NextCtr = update.Ctr
+		}},
+	} {
+		update, err := priv.Update(pub, "hip", "burger")
+		if err != nil {
+			t.Fatalf("priv.Update() fails: %s", err)
+		}
+		tc.tamper(update)
+		if err = pub.ApplyUpdate(update); err != ErrorStaleUpdate {
+			t.Errorf("%s: pub.ApplyUpdate() returns %v, expected %q", tc.desc, err, ErrorStaleUpdate)
+		}
+	}
+	checkStore(t, pub, priv, goodM)
+}
+
+func TestApplyUpdateBadKey(t *testing.T) {
+	pub, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+	pub2, priv2, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub2.Close()
+	defer priv2.Close()
+
+	update, err := priv2.Update(pub2, "hip", "burger")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	if _, err = priv.ApplyUpdate(update); err != ErrorBadParams {
+		t.Errorf("priv.ApplyUpdate() returns %v for another key, expected %q", err, ErrorBadParams)
+	}
+}
+
+// Test that updates are preserved by the protobuf representation.
+func TestUpdateProto(t *testing.T) {
+	pub1, priv, err := NewStore(GenerateKey(), goodM)
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub1.Close()
+	defer func() { priv.Close() }()
+
+	in := insertAny(t, pub1, &priv, "cool")
+	pub2 := NewPubStoreFromProto(pub1.GetProto())
+	defer pub2.Close()
+	AssertStringEqError(t, "pub2.String()", pub2.String(), pub1.String())
+
+	update, err := priv.Update(pub2, in, "cooler")
+	if err != nil {
+		t.Fatalf("priv.Update() fails: %s", err)
+	}
+	if err = pub1.ApplyUpdate(update); err != nil {
+		t.Fatalf("pub1.ApplyUpdate() fails: %s", err)
+	}
+	applyUpdate(t, pub2, &priv, update)
+	AssertStringEqError(t, "pub2.String()", pub2.String(), pub1.String())
+	checkStore(t, pub2, priv, map[string]string{in: "cooler", "hip": "pizza"})
+}
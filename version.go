@@ -0,0 +1,115 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	"github.com/cjpatton/store/pb"
+)
+
+// Returned by NewPrivStore() if the version of the store is less than the
+// minimum version. This means the parameters come from an older store, e.g.,
+// because the server replayed it.
+const ErrorStaleStore = Error("store is older than the minimum version")
+
+// Returned by NewPrivStore() if the MAC of the parameters is invalid, i.e., the
+// parameters were not created with the store key or were modified since.
+const ErrorBadParams = Error("parameters are not authenticated")
+
+// WithVersion sets the version of the store. Each time a client rebuilds its
+// store, it should increase the version, so that clients that require the new
+// version (see NewPrivStore()) reject the old store. The version, the time the
+// store was created, and the rest of the parameters are authenticated with a
+// MAC under a key derived from the store key.
+func WithVersion(version int64) Option {
+	return func(priv *PrivStore) {
+		priv.version = version
+	}
+}
+
+// writeParams writes the canonical encoding of the parameters, except the MAC,
+// to h.
+func writeParams(h hash.Hash, params *pb.Params) {
+	var buf [8]byte
+	for _, n := range []int32{
+		params.GetTableLen(),
+		params.GetMaxOutputBytes(),
+		params.GetRowBytes(),
+		params.GetTagBytes(),
+		int32(len(params.GetSalt())),
+	} {
+		binary.BigEndian.PutUint32(buf[:4], uint32(n))
+		h.Write(buf[:4])
+	}
+	h.Write(params.GetSalt())
+	var pad byte
+	if params.GetPad() {
+		pad = 1
+	}
+	h.Write([]byte{pad})
+	for _, n := range []int32{
+		int32(params.GetOutputPadding()),
+		params.GetPaddedOutputBytes(),
+	} {
+		binary.BigEndian.PutUint32(buf[:4], uint32(n))
+		h.Write(buf[:4])
+	}
+	for _, n := range []int64{params.GetVersion(), params.GetCreated()} {
+		binary.BigEndian.PutUint64(buf[:], uint64(n))
+		h.Write(buf[:])
+	}
+	// The key schedule is omitted for legacy stores, whose parameters were
+	// authenticated before key schedules were supported.
+	if params.GetKeySchedule() != pb.KeySchedule_LEGACY_SPLIT {
+		binary.BigEndian.PutUint32(buf[:4], uint32(params.GetKeySchedule()))
+		h.Write(buf[:4])
+	}
+}
+
+// paramsMac computes the MAC of the parameters under the key derived for this
+// purpose from the store key. (See deriveKeys().)
+func paramsMac(key []byte, params *pb.Params) []byte {
+	mac := hmac.New(sha256.New, key)
+	writeParams(mac, params)
+	return mac.Sum(nil)
+}
+
+// setVersion sets the version, creation time, and MAC of the store from params.
+// The version and creation time are not authenticated without a MAC, so they
+// are ignored.
+func (priv *PrivStore) setVersion(params *pb.Params) {
+	if len(params.GetMac()) == 0 {
+		return
+	}
+	priv.version = params.GetVersion()
+	priv.created = params.GetCreated()
+	priv.mac = params.GetMac()
+}
+
+// checkVersion checks the MAC of params under key and that the version is at
+// least minVersion. Every store with a key schedule other than LEGACY_SPLIT has a
+// MAC, so if it is missing, then the parameters were modified. Legacy parameters
+// without a MAC are accepted only if minVersion <= 0, in which case their
+// version is taken to be 0.
+func checkVersion(key []byte, params *pb.Params, minVersion int64) error {
+	if len(params.GetMac()) == 0 {
+		if params.GetKeySchedule() != pb.KeySchedule_LEGACY_SPLIT {
+			return ErrorBadParams
+		} else if minVersion > 0 {
+			return ErrorStaleStore
+		}
+		return nil
+	}
+	if !hmac.Equal(params.GetMac(), paramsMac(key, params)) {
+		return ErrorBadParams
+	}
+	if params.GetVersion() < minVersion {
+		return ErrorStaleStore
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2017, Christopher Patton
+// All rights reserved.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cjpatton/store/pb"
+)
+
+func TestVersion(t *testing.T) {
+	K := GenerateKey()
+	pub, priv, err := NewStore(K, goodM, WithVersion(2))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	params := pub.GetProto().GetDict().GetParams()
+	if params.GetVersion() != 2 {
+		t.Errorf("params.GetVersion() = %d, expected 2", params.GetVersion())
+	}
+	if created := time.Unix(params.GetCreated(), 0); time.Since(created) > time.Minute {
+		t.Errorf("params.GetCreated() = %s, expected about now", created)
+	}
+	for _, minVersion := range []int64{0, 1, 2} {
+		priv2, err := NewPrivStore(K, params, minVersion)
+		if err != nil {
+			t.Fatalf("NewPrivStore(%d) fails: %s", minVersion, err)
+		}
+		checkStore(t, pub, priv2, goodM)
+		priv2.Close()
+	}
+	if _, err = NewPrivStore(K, params, 3); err != ErrorStaleStore {
+		t.Errorf("NewPrivStore(3) returns %v, expected %q", err, ErrorStaleStore)
+	}
+
+	// The parameters survive serialization of the store.
+	pub2 := NewPubStoreFromProto(pub.GetProto())
+	defer pub2.Close()
+	if _, err = NewPrivStore(K, pub2.GetProto().GetDict().GetParams(), 2); err != nil {
+		t.Errorf("NewPrivStore() fails for the deserialized store: %s", err)
+	}
+
+	// The parameters are authenticated under the store key.
+	if _, err = NewPrivStore(GenerateKey(), params, 0); err != ErrorBadParams {
+		t.Errorf("NewPrivStore() returns %v for the wrong key, expected %q", err, ErrorBadParams)
+	}
+	params.Version = 3
+	if _, err = NewPrivStore(K, params, 3); err != ErrorBadParams {
+		t.Errorf("NewPrivStore() returns %v for a modified version, expected %q", err, ErrorBadParams)
+	}
+
+	// The MAC can't be stripped.
+	params.Mac = nil
+	for _, minVersion := range []int64{0, 1} {
+		if _, err = NewPrivStore(K, params, minVersion); err != ErrorBadParams {
+			t.Errorf("NewPrivStore(%d) returns %v without a MAC, expected %q", minVersion, err, ErrorBadParams)
+		}
+	}
+
+	// Legacy stores without a MAC have version 0.
+	params.KeySchedule = pb.KeySchedule_LEGACY_SPLIT
+	if _, err = NewPrivStore(K, params, 1); err != ErrorStaleStore {
+		t.Errorf("NewPrivStore() returns %v for a legacy store, expected %q", err, ErrorStaleStore)
+	}
+	if priv, err := NewPrivStore(K, params, 0); err != nil {
+		t.Errorf("NewPrivStore() fails for a legacy store: %s", err)
+	} else {
+		priv.Close()
+	}
+}
+
+func TestVersionRollback(t *testing.T) {
+	K := GenerateKey()
+	old, oldPriv, err := NewStore(K, goodM, WithVersion(1))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer old.Close()
+	defer oldPriv.Close()
+	pub, priv, err := NewStore(K, map[string]string{"hip": "burger"}, WithVersion(2))
+	if err != nil {
+		t.Fatalf("NewStore() fails: %s", err)
+	}
+	defer pub.Close()
+	defer priv.Close()
+
+	// The client requires the new version, so it rejects the old parameters.
+	if _, err = NewPrivStore(K, old.GetProto().GetDict().GetParams(), 2); err != ErrorStaleStore {
+		t.Errorf("NewPrivStore() returns %v for the old store, expected %q", err, ErrorStaleStore)
+	}
+
+	// The shares of the old store are useless with the new parameters.
+	priv2, err := NewPrivStore(K, pub.GetProto().GetDict().GetParams(), 2)
+	if err != nil {
+		t.Fatalf("NewPrivStore() fails: %s", err)
+	}
+	defer priv2.Close()
+	if output, err := priv2.Get(pub, "hip"); err != nil || output != "burger" {
+		t.Errorf("priv2.Get(pub) = (%q, %v), expected (\"burger\", nil)", output, err)
+	}
+	if _, err := priv2.Get(old, "hip"); err == nil {
+		t.Error("priv2.Get(old) succeeds, expected failure")
+	}
+
+	// The version is bound to the commitment.
+	priv2.SetCommitment(pub.Commitment())
+	if _, err := priv2.Get(old, "hip"); err != ErrorBadProof {
+		t.Errorf("priv2.Get(old) returns %v with a commitment, expected %q", err, ErrorBadProof)
+	}
+}